@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/slzatz/vimango/rawmode"
 	"github.com/slzatz/vimango/terminal"
@@ -43,11 +44,17 @@ type App struct {
 	kittyPlace      bool   // true if terminal supports Unicode placeholders
 	kittyTextSizing bool   // true if terminal supports OSC 66 text sizing (kitty 0.40.0+ only)
 	// kittyRelative bool // Reserved for future side-by-side image support (relative placements)
+	imageProtocol      imageProtocol // how the preview draws images; nil if it can't
+	graphics           terminalGraphics // what probing the terminal found out about its graphics
 	showImages         bool   // true if inline images should be displayed
 	showImageInfo      bool   // true if Google Drive folder/filename should be displayed above images
 	imageScale         int    // image width in columns (default: 45)
 	imageCacheMaxWidth int    // max pixel width for cached Google Drive images (default: 800)
 	preferencesPath    string // path to preferences.json file
+	theme              *Theme // colors of the organizer, editor and status bars; see theme.go
+	themeChoice        string // theme chosen with :theme, kept in preferences.json
+	mouse              bool       // the terminal reports mouse events; see mouse.go
+	mouseState         mouseState // earlier mouse events
 	origTermCfg        []byte // original terminal configuration
 }
 
@@ -72,6 +79,7 @@ func CreateApp() *App {
 		keyErrors:      make(chan error, 1),
 		Run:            true,
 		kitty:          kitty, // default to false
+		theme:          defaultTheme(),
 	}
 }
 
@@ -149,6 +157,7 @@ func (a *App) LoadPreferences(path string) Preferences {
 		ImageScale:         45,
 		EdPct:              60,
 		ImageCacheMaxWidth: 800,
+		Mouse:              true,
 	}
 
 	// Try to read file
@@ -158,8 +167,8 @@ func (a *App) LoadPreferences(path string) Preferences {
 		return defaults
 	}
 
-	// Try to parse JSON
-	var prefs Preferences
+	// Try to parse JSON; settings the file doesn't have keep their defaults
+	prefs := defaults
 	if err := json.Unmarshal(b, &prefs); err != nil {
 		// Invalid JSON - use defaults
 		return defaults
@@ -191,6 +200,8 @@ func (a *App) SavePreferences() error {
 		ImageScale:         a.imageScale,
 		EdPct:              a.Screen.edPct,
 		ImageCacheMaxWidth: a.imageCacheMaxWidth,
+		Theme:              a.themeChoice,
+		Mouse:              a.mouse,
 	}
 
 	// Marshal to JSON with indentation
@@ -217,6 +228,7 @@ func (a *App) signalHandler() {
 	err := a.Screen.GetWindowSize()
 	if err != nil {
 		//SafeExit(fmt.Errorf("couldn't get window size: %v", err))
+		a.restoreTerminal()
 		os.Exit(1)
 	}
 	a.moveDividerPct(a.Screen.edPct) // should change to Screen
@@ -552,6 +564,33 @@ func (a *App) MigrateToUUID() error {
 	return nil
 }
 
+// MigrateUndoHistory creates the undo_history table in databases created
+// before persistent undo was added
+func (a *App) MigrateUndoHistory() error {
+	if _, err := a.Database.MainDB.Exec(undoHistorySchema); err != nil {
+		return fmt.Errorf("failed to create undo_history table: %v", err)
+	}
+	return nil
+}
+
+// MigrateRecovery creates the recovery table in databases created before
+// autosave was added
+func (a *App) MigrateRecovery() error {
+	if _, err := a.Database.MainDB.Exec(recoverySchema); err != nil {
+		return fmt.Errorf("failed to create recovery table: %v", err)
+	}
+	return nil
+}
+
+// MigrateAttachments creates the attachment table in databases created
+// before attachments were added
+func (a *App) MigrateAttachments() error {
+	if _, err := a.Database.MainDB.Exec(attachmentSchema); err != nil {
+		return fmt.Errorf("failed to create attachment table: %v", err)
+	}
+	return nil
+}
+
 // InitApp initializes the application components
 func (a *App) InitApp() {
 
@@ -592,6 +631,10 @@ func (a *App) InitApp() {
 	a.Organizer.marked_entries = make(map[int]struct{})
 	a.Organizer.vbuf = vim.NewBuffer(0)
 	vim.SetCurrentBuffer(a.Organizer.vbuf)
+
+	if globalImageCache != nil && a.Config.Images.CacheMaxMB > 0 {
+		globalImageCache.SetMaxBytes(int64(a.Config.Images.CacheMaxMB) << 20)
+	}
 }
 
 func (a *App) LoadInitialData() {
@@ -636,7 +679,7 @@ func (a *App) returnCursor() {
 	if a.Session.editorMode {
 		ae := a.Session.activeEditor
 		switch ae.mode {
-		case PREVIEW, SPELLING, VIEW_LOG:
+		case PREVIEW, SPELLING, VIEW_LOG, UNDO_TREE, OUTLINE:
 			// we don't need to position cursor and don't want cursor visible
 			fmt.Print(ab.String())
 			return
@@ -665,10 +708,15 @@ func (a *App) returnCursor() {
 }
 
 func (a *App) Cleanup() {
+	// keep the unsaved changes of any open editors for the next start
+	a.autosave()
+
 	// Stop async render manager
 	if a.RenderManager != nil {
 		a.RenderManager.Stop()
 	}
+	a.Session.stopLsps()
+	removeRunDirs()
 
 	if a.Database.MainDB != nil {
 		a.Database.MainDB.Close()
@@ -685,13 +733,21 @@ func (a *App) Cleanup() {
 func (a *App) quitApp() {
 	fmt.Print("\x1b[2J\x1b[H") //clears the screen and sends cursor home
 
-	if err := rawmode.Restore(a.origTermCfg); err != nil {
+	if err := a.restoreTerminal(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: disabling raw mode: %s\r\n", err)
 		os.Exit(1)
 	}
 	os.Exit(0)
 }
 
+// restoreTerminal gives the terminal back the way vimango found it, with
+// mouse reporting off and raw mode undone; every exit after raw mode is
+// enabled goes through it
+func (a *App) restoreTerminal() error {
+	terminal.DisableMouse()
+	return rawmode.Restore(a.origTermCfg)
+}
+
 func (a *App) startKeyReader() {
 	go func() {
 		for {
@@ -715,6 +771,11 @@ func (a *App) MainLoop() {
 	org := a.Organizer
 	a.startKeyReader()
 
+	var autosave <-chan time.Time
+	if interval := a.autosaveInterval(); interval > 0 {
+		autosave = time.NewTicker(interval).C
+	}
+
 	if a.HasNotifications() {
 		a.processNotifications(org)
 		a.returnCursor()
@@ -723,6 +784,11 @@ func (a *App) MainLoop() {
 	for a.Run {
 		select {
 		case key := <-a.keyEvents:
+			if key.Special == terminal.KeyMouse {
+				a.handleMouse(key.Mouse)
+				a.returnCursor()
+				continue
+			}
 			var k int
 			if key.Regular != 0 {
 				k = int(key.Regular)
@@ -775,6 +841,8 @@ func (a *App) MainLoop() {
 			}
 		case <-a.notificationCh:
 			a.processNotificationsWithRedraw(org)
+		case <-autosave:
+			a.autosave()
 		}
 		a.returnCursor()
 	}
@@ -835,6 +903,33 @@ func (a *App) processNotificationsWithRedraw(org *Organizer) {
 			continue
 		}
 
+		// :compile, :run or :test produced output or finished
+		if notification == runNotification {
+			if r := a.Session.run; r != nil {
+				// cleared first so output that arrives while drawing
+				// queues another notification
+				r.pending.Store(false)
+				if a.Session.editorMode {
+					r.draw()
+				}
+				r.complete()
+			}
+			continue
+		}
+
+		// Language server started or published diagnostics
+		if notification == lspNotification {
+			if a.Session.editorMode {
+				ae := a.Session.activeEditor
+				ae.lspSync()
+				if ae.mode == NORMAL || ae.mode == INSERT {
+					ae.drawText()
+					ae.drawStatusBar()
+				}
+			}
+			continue
+		}
+
 		// Handle regular notifications (research results, etc.)
 		org.drawNotice(notification)
 		org.altRowoff = 0
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"github.com/slzatz/vimango/vim"
+)
+
+// Files attached to notes are stored in the attachment table, which is
+// synchronized like entries, so a note's local screenshots go wherever the
+// note goes. Notes refer to them as attach:<uuid>, e.g. ![plot](attach:...),
+// and :attach <path> stores a file and inserts that reference.
+
+// attachmentScheme is the url prefix of an attachment reference
+const attachmentScheme = "attach:"
+
+// maxAttachmentBytes is the largest file :attach will store
+const maxAttachmentBytes = 20 << 20
+
+// attachmentRegex finds markdown images and links to attachments
+var attachmentRegex = regexp.MustCompile(`(!?)\[([^\]]*)\]\((attach:[0-9a-fA-F-]+)\)`)
+
+// attachmentID returns the uuid of an attach: url
+func attachmentID(url string) (string, bool) {
+	if !strings.HasPrefix(url, attachmentScheme) {
+		return "", false
+	}
+	id := strings.TrimPrefix(url, attachmentScheme)
+	if _, err := uuid.Parse(id); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// loadAttachmentImage decodes an attached image, scaled to fit maxW x maxH
+func loadAttachmentImage(id string, maxW, maxH int) (image.Image, error) {
+	_, data, err := app.Database.readAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := decodeImageWithOrientation(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if img.Bounds().Dx() > maxW || img.Bounds().Dy() > maxH {
+		img = imaging.Fit(img, maxW, maxH, imaging.Lanczos)
+	}
+	return img, nil
+}
+
+// embedAttachments replaces attach: urls with data URIs for the webview
+// and goldmark PDF export
+func embedAttachments(markdown string) string {
+	return attachmentRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		m := attachmentRegex.FindStringSubmatch(match)
+		id, ok := attachmentID(m[3])
+		if !ok {
+			return match
+		}
+		mimeType, data, err := app.Database.readAttachment(id)
+		if err != nil {
+			return match
+		}
+		return fmt.Sprintf("%s[%s](data:%s;base64,%s)", m[1], m[2], mimeType, base64.StdEncoding.EncodeToString(data))
+	})
+}
+
+// dataImageRegex finds markdown images whose urls are base64 data URIs
+var dataImageRegex = regexp.MustCompile(`(!)\[([^\]]*)\]\(data:(image/[\w.+-]+);base64,([^)]+)\)`)
+
+// attachmentsToFiles writes the attachments and data URI images markdown
+// refers to into a temporary directory and replaces their urls with the
+// files' paths, for renderers that only read images from files; cleanup
+// removes the files
+func attachmentsToFiles(markdown string) (string, func()) {
+	cleanup := func() {}
+	if !attachmentRegex.MatchString(markdown) && !dataImageRegex.MatchString(markdown) {
+		return markdown, cleanup
+	}
+	dir, err := os.MkdirTemp("", "vimango-attach")
+	if err != nil {
+		return markdown, cleanup
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	// writeFile returns the path of data written to dir, or "" on error
+	writeFile := func(name, mimeType string, data []byte) string {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return ""
+		}
+		return path
+	}
+
+	markdown = attachmentRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		m := attachmentRegex.FindStringSubmatch(match)
+		id, ok := attachmentID(m[3])
+		if !ok {
+			return match
+		}
+		mimeType, data, err := app.Database.readAttachment(id)
+		if err != nil {
+			return match
+		}
+		path := writeFile(id, mimeType, data)
+		if path == "" {
+			return match
+		}
+		return fmt.Sprintf("%s[%s](%s)", m[1], m[2], path)
+	})
+
+	markdown = dataImageRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		m := dataImageRegex.FindStringSubmatch(match)
+		data, err := base64.StdEncoding.DecodeString(m[4])
+		if err != nil {
+			return match
+		}
+		path := writeFile(hashBytes(data), m[3], data)
+		if path == "" {
+			return match
+		}
+		return fmt.Sprintf("%s[%s](%s)", m[1], m[2], path)
+	})
+	return markdown, cleanup
+}
+
+// attachmentMarkdown returns the markdown that shows an attachment: an
+// image for images, else a link
+func attachmentMarkdown(name, mimeType, id string) string {
+	link := fmt.Sprintf("[%s](%s%s)", name, attachmentScheme, id)
+	if strings.HasPrefix(mimeType, "image/") {
+		return "!" + link
+	}
+	return link
+}
+
+// detectMime returns the mime type of a file from its contents, or its
+// name if the contents don't say
+func detectMime(path string, data []byte) string {
+	mimeType := http.DetectContentType(data)
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); byExt != "" {
+			return byExt
+		}
+	}
+	if IsHEICData(data) {
+		return "image/heic"
+	}
+	if IsAVIFData(data) {
+		return "image/avif"
+	}
+	return mimeType
+}
+
+// attach stores a file as an attachment and inserts a reference to it at
+// the cursor (:attach <path>)
+func (e *Editor) attach() {
+	pos := strings.Index(e.command_line, " ")
+	if pos == -1 {
+		e.ShowMessage(BR, "You need to provide a filename")
+		return
+	}
+	path := strings.TrimSpace(e.command_line[pos+1:])
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		e.ShowMessage(BR, "%v", err)
+		return
+	}
+	if info.Size() > maxAttachmentBytes {
+		e.ShowMessage(BR, "%s is larger than %d MB", path, maxAttachmentBytes>>20)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.ShowMessage(BR, "%v", err)
+		return
+	}
+
+	mimeType := detectMime(path, data)
+	id, err := e.Database.insertAttachment(mimeType, data)
+	if err != nil {
+		e.ShowMessage(BR, "Error storing attachment: %v", err)
+		return
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	e.insertAtCursor(attachmentMarkdown(name, mimeType, id))
+	e.ShowMessage(BR, "Attached %s (%s, %d KB)", filepath.Base(path), mimeType, len(data)>>10)
+}
+
+// insertAtCursor inserts text into the note at the cursor
+func (e *Editor) insertAtCursor(text string) {
+	pos := vim.GetCursorPosition()
+	row, col := pos[0]-1, pos[1]
+	if row < 0 || row >= len(e.ss) {
+		return
+	}
+	ss := append([]string(nil), e.ss...)
+	line := ss[row]
+	if col > len(line) {
+		col = len(line)
+	}
+	ss[row] = line[:col] + text + line[col:]
+	e.vbuf.SetLines(0, -1, ss)
+	e.ss = e.vbuf.Lines()
+	vim.SetCursorPosition(row+1, col+len(text))
+}
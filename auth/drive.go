@@ -1,6 +1,7 @@
 package auth // In a file like auth/drive.go
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -46,6 +47,20 @@ func GetDriveService() (*drive.Service, error) {
 	return srv, nil
 }
 
+// UploadFile creates a file in the Drive folder folderID (or My Drive if
+// folderID is empty) and returns the new file's id.
+func UploadFile(srv *drive.Service, name, mimeType string, data []byte, folderID string) (string, error) {
+	f := &drive.File{Name: name, MimeType: mimeType}
+	if folderID != "" {
+		f.Parents = []string{folderID}
+	}
+	created, err := srv.Files.Create(f).Media(bytes.NewReader(data)).Fields("id").Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to upload %s: %w", name, err)
+	}
+	return created.Id, nil
+}
+
 // getClient retrieves a token, saves it, and returns the generated client.
 func getClient(config *oauth2.Config) *http.Client {
 	// The file token.json stores the user's access and refresh tokens, and is
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// blocksProtocol draws images into the preview text with upper half block
+// characters, each cell showing two pixels: the top one in the foreground
+// color and the bottom one in the background color. It needs nothing from
+// the terminal but color, so it works in tmux without passthrough and over
+// plain ssh, and since the image is text the preview scrolls it like text.
+type blocksProtocol struct {
+	trueColor bool // 24-bit color; else 256 colors with dithering
+}
+
+func newBlocksProtocol() *blocksProtocol {
+	colorterm := strings.ToLower(os.Getenv("COLORTERM"))
+	return &blocksProtocol{trueColor: colorterm == "truecolor" || colorterm == "24bit"}
+}
+
+func (p *blocksProtocol) String() string { return "blocks" }
+
+// blockImage is an image drawn as preview text
+type blockImage struct {
+	fingerprint string
+	text        string
+	cols, rows  int
+	fit         int // imageColumns when it was drawn
+}
+
+var (
+	blockImages           = make(map[uint32]*blockImage)
+	blockImageIDs         = make(map[string]uint32)
+	blockImageNext uint32 = 1
+	blockImageMux  sync.Mutex
+)
+
+func (p *blocksProtocol) beginRender() {}
+
+func (p *blocksProtocol) cached(url string, attrs imageAttrs) bool {
+	blockImageMux.Lock()
+	defer blockImageMux.Unlock()
+	bi := blockImages[blockImageIDs[imageKey(url, attrs)]]
+	return bi != nil && bi.fit == imageColumns(app.Screen.totaleditorcols-PREVIEW_RIGHT_PADDING)
+}
+
+func (p *blocksProtocol) transmit(prep *preparedImage, attrs imageAttrs, maxCols int) (uint32, int, int) {
+	if prep == nil || prep.err != nil || prep.imgW == 0 || prep.imgH == 0 {
+		return 0, 0, 0
+	}
+	// a cell is about twice as tall as it is wide and holds two pixels
+	cols, rows := imageCells(prep.imgW, prep.imgH, attrs, maxCols, 0.5)
+
+	key := imageKey(prep.url, attrs)
+	blockImageMux.Lock()
+	id, ok := blockImageIDs[key]
+	if !ok {
+		id = blockImageNext
+		blockImageNext++
+		blockImageIDs[key] = id
+	}
+	bi := blockImages[id]
+	blockImageMux.Unlock()
+	if bi != nil && bi.cols == cols && bi.rows == rows && bi.fingerprint == prep.cachedFingerprint {
+		return id, cols, rows
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(prep.data))
+	if err != nil {
+		return 0, 0, 0
+	}
+	scaled := imaging.Resize(img, cols, rows*2, imaging.Lanczos)
+	bi = &blockImage{
+		fingerprint: prep.cachedFingerprint,
+		text:        p.draw(scaled),
+		cols:        cols,
+		rows:        rows,
+		fit:         imageColumns(maxCols),
+	}
+	blockImageMux.Lock()
+	blockImages[id] = bi
+	blockImageMux.Unlock()
+	return id, cols, rows
+}
+
+func (p *blocksProtocol) placeholder(id uint32, cols, rows int) string {
+	blockImageMux.Lock()
+	defer blockImageMux.Unlock()
+	bi := blockImages[id]
+	if bi == nil {
+		return ""
+	}
+	return bi.text
+}
+
+// draw returns img as rows of half blocks, two pixel rows to a line
+func (p *blocksProtocol) draw(img *image.NRGBA) string {
+	bounds := img.Bounds()
+	var indexed *image.Paletted
+	if !p.trueColor {
+		indexed = image.NewPaletted(bounds, xterm256Palette)
+		draw.FloydSteinberg.Draw(indexed, bounds, img, bounds.Min)
+	}
+	colorAt := func(x, y int) (string, bool) {
+		if y >= bounds.Max.Y || img.NRGBAAt(x, y).A < 128 {
+			return "", false
+		}
+		if indexed != nil {
+			return fmt.Sprintf("5;%d", 16+int(indexed.ColorIndexAt(x, y))), true
+		}
+		c := img.NRGBAAt(x, y)
+		return fmt.Sprintf("2;%d;%d;%d", c.R, c.G, c.B), true
+	}
+
+	var b strings.Builder
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top, hasTop := colorAt(x, y)
+			bottom, hasBottom := colorAt(x, y+1)
+			switch {
+			case hasTop && hasBottom:
+				fmt.Fprintf(&b, "\x1b[38;%s;48;%sm▀", top, bottom)
+			case hasTop:
+				fmt.Fprintf(&b, "\x1b[49;38;%sm▀", top)
+			case hasBottom:
+				fmt.Fprintf(&b, "\x1b[49;38;%sm▄", bottom)
+			default:
+				b.WriteString("\x1b[0m ")
+			}
+		}
+		b.WriteString("\x1b[0m\n")
+	}
+	return b.String()
+}
+
+// xterm256Palette is the color cube and gray ramp of the 256 color palette,
+// colors 16 to 255
+var xterm256Palette = func() color.Palette {
+	levels := []uint8{0, 95, 135, 175, 215, 255}
+	var p color.Palette
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				p = append(p, color.RGBA{r, g, b, 0xff})
+			}
+		}
+	}
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + 10*i)
+		p = append(p, color.RGBA{v, v, v, 0xff})
+	}
+	return p
+}()
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestBlocksDraw(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.SetNRGBA(0, 1, color.NRGBA{0, 0, 255, 255})
+	img.SetNRGBA(1, 1, color.NRGBA{0, 255, 0, 255})
+	img.SetNRGBA(0, 2, color.NRGBA{255, 255, 255, 255})
+
+	p := &blocksProtocol{trueColor: true}
+	want := "\x1b[38;2;255;0;0;48;2;0;0;255m▀" + // both pixels
+		"\x1b[49;38;2;0;255;0m▄" + // only the bottom one
+		"\x1b[0m\n" +
+		"\x1b[49;38;2;255;255;255m▀" + // the odd last row
+		"\x1b[0m " + // transparent
+		"\x1b[0m\n"
+	if got := p.draw(img); got != want {
+		t.Errorf("draw = %q, want %q", got, want)
+	}
+
+	p = &blocksProtocol{}
+	got := p.draw(img)
+	for _, want := range []string{"\x1b[38;5;196;48;5;21m▀", "\x1b[49;38;5;46m▄", "\x1b[49;38;5;231m▀"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("draw with 256 colors = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestBlocksTransmit(t *testing.T) {
+	saved := app
+	t.Cleanup(func() { app = saved })
+	app = &App{imageScale: 10}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(40, 20)); err != nil {
+		t.Fatal(err)
+	}
+	prep := &preparedImage{url: "blocks-test.png", data: buf.Bytes(), imgW: 40, imgH: 20, cachedFingerprint: "a"}
+	p := &blocksProtocol{trueColor: true}
+
+	id, cols, rows := p.transmit(prep, imageAttrs{}, 80)
+	if id == 0 || cols != 10 || rows != 2 {
+		t.Fatalf("transmit = %d, %d, %d, want an id, 10, 2", id, cols, rows)
+	}
+	text := p.placeholder(id, cols, rows)
+	if lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n"); len(lines) != rows {
+		t.Errorf("placeholder has %d lines, want %d", len(lines), rows)
+	}
+	if again, _, _ := p.transmit(prep, imageAttrs{}, 80); again != id {
+		t.Errorf("transmitting the image again gave id %d, want %d", again, id)
+	}
+	if sized, cols, _ := p.transmit(prep, imageAttrs{cols: 4}, 80); sized == id || cols != 4 {
+		t.Errorf("transmitting it 4 columns wide = id %d, %d columns, want a new id and 4 columns", sized, cols)
+	}
+	if id, _, _ := p.transmit(&preparedImage{url: "empty.png"}, imageAttrs{}, 80); id != 0 {
+		t.Errorf("transmitting an empty image gave id %d, want 0", id)
+	}
+	if got := p.placeholder(0, 1, 1); got != "" {
+		t.Errorf("placeholder(0) = %q, want \"\"", got)
+	}
+}
@@ -327,6 +327,29 @@ ALTER TABLE ONLY public.sync
     ADD CONSTRAINT sync_pkey PRIMARY KEY (machine);
 
 
+--
+-- Name: attachment; Type: TABLE; Schema: public; Owner: slzatz
+--
+
+CREATE TABLE public.attachment (
+    uuid text NOT NULL,
+    mime text NOT NULL,
+    bytes bytea NOT NULL,
+    hash text NOT NULL,
+    created timestamp without time zone DEFAULT now()
+);
+
+
+ALTER TABLE public.attachment OWNER TO slzatz;
+
+--
+-- Name: attachment attachment_pkey; Type: CONSTRAINT; Schema: public; Owner: slzatz
+--
+
+ALTER TABLE ONLY public.attachment
+    ADD CONSTRAINT attachment_pkey PRIMARY KEY (uuid);
+
+
 --
 -- PostgreSQL database dump complete
 --
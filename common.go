@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/slzatz/vimango/terminal"
 )
 
 type Location int
@@ -55,8 +57,9 @@ type dbConfig struct {
 	} `json:"sqlite3"`
 
 	Options struct {
-		Type  string `json:"type"`
-		Title string `json:"title"`
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Autosave int    `json:"autosave"` // seconds between autosaves of unsaved notes (default 30, -1 is off)
 	} `json:"options"`
 
 	Chroma struct {
@@ -70,13 +73,36 @@ type dbConfig struct {
 	Glamour struct {
 		Style string `json:"style"`
 	} `json:"glamour"`
+
+	Images struct {
+		Protocol         string `json:"protocol"`           // auto, kitty, sixel, iterm2, blocks or none (overridden by VIMANGO_IMAGE_PROTOCOL)
+		CacheMaxMB       int    `json:"cache_max_mb"`       // size of the disk image cache (default 200)
+		PasteTo          string `json:"paste_to"`           // where :pasteimage stores images: attachment (default) or gdrive
+		PasteDriveFolder string `json:"paste_drive_folder"` // Drive folder id for paste_to gdrive; empty is My Drive
+		PasteCommand     string `json:"paste_command"`      // command that writes the clipboard image to stdout; empty tries wl-paste, xclip, pngpaste
+	} `json:"images"`
+
+	RenderCache struct {
+		Entries int  `json:"entries"` // rendered previews kept in memory (default 200)
+		Persist bool `json:"persist"` // keep previews of notes without images on disk between runs
+	} `json:"render_cache"`
+
+	Runners map[string]RunnerConfig `json:"runners,omitempty"` // by language; see defaultRunners
+
+	Spell struct {
+		Lang     string            `json:"lang"`     // dictionary for notes that don't choose one, e.g. en_US
+		Dir      string            `json:"dir"`      // where the hunspell .aff and .dic files are
+		Contexts map[string]string `json:"contexts"` // dictionary by context title
+	} `json:"spell"`
 }
 
 // Preferences holds user UI preferences that persist across sessions
 type Preferences struct {
-	ImageScale         int `json:"image_scale"`           // Image width in columns (10-100)
-	EdPct              int `json:"ed_pct"`                // Editor percentage (1-99)
-	ImageCacheMaxWidth int `json:"image_cache_max_width"` // Max pixel width for cached images (default 800)
+	ImageScale         int    `json:"image_scale"`           // Image width in columns (10-100)
+	EdPct              int    `json:"ed_pct"`                // Editor percentage (1-99)
+	ImageCacheMaxWidth int    `json:"image_cache_max_width"` // Max pixel width for cached images (default 800)
+	Theme              string `json:"theme"`                 // theme chosen with :theme
+	Mouse              bool   `json:"mouse"`                 // report mouse events (default true); :mouse toggles it
 }
 
 // validateGlamourStyle checks if a glamour style file exists and returns an error if not.
@@ -103,11 +129,17 @@ func validateGlamourStyle() error {
 }
 
 // getGlamourStylePath returns the path to the glamour style file with fallback logic:
-// 1. Try configured style from config.json
-// 2. Try default.json
+// 1. Use the theme's style (a file or one of glamour's built-in styles)
+// 2. Try configured style from config.json
+// 3. Try default.json
 // This function assumes validateGlamourStyle() has already been called at startup.
 func getGlamourStylePath() string {
-	// Try config value first
+	// The theme's style comes first
+	if app.theme != nil && app.theme.Glamour != "" {
+		return app.theme.Glamour
+	}
+
+	// Then the config value
 	if app.Config != nil && app.Config.Glamour.Style != "" {
 		if _, err := os.Stat(app.Config.Glamour.Style); err == nil {
 			return app.Config.Glamour.Style
@@ -150,12 +182,14 @@ var termcodes = map[int]string{
 	DEL_KEY:     "<del>",
 	PAGE_UP:     "<pageup>",
 	PAGE_DOWN:   "<pagedown>",
+	SHIFT_TAB:   "<s-tab>",
 }
 
+// Lsps are the language servers for the values in Languages
 var Lsps = map[string]string{
-	"go":  "gopls",
-	"cpp": "clangd",
-	"py":  "python-language-server",
+	"go":     "gopls",
+	"cpp":    "clangd",
+	"python": "pyls", // python-language-server
 }
 
 type Mode int
@@ -173,7 +207,10 @@ const (
 	//FIND            // only organizer mode
 	PREVIEW         // only editor mode - for previewing markdown
 	VIEW_LOG        // only in editor mode - for debug viewing of vim message hx
-	SPELLING        // this mode recognizes 'z='
+	SPELLING        // only in editor mode - browsing :spellreport
+	UNDO_TREE       // only in editor mode - browsing undo states
+	OUTLINE         // browsing a note's headings
+	RECOVERY        // only in organizer mode - reviewing autosaved changes
 	NAVIGATE_NOTICE // only in organizer mode
 	HELP            // organizer and editor mode
 	CONTAINER       // overlay for choosing folder/context
@@ -191,6 +228,10 @@ var modeMap = map[int]Mode{
 	257: NORMAL_BUSY, // just about any keystroke when in NORMAL mode
 }
 
+// curswant reported by vim after $ (MAXCOL); a block extended with $
+// covers the end of every line
+const vimMaxCol = "2147483647"
+
 // v -> 118; V -> 86; ctrl-v -> 22
 var visualModeMap = map[int]Mode{
 	22:  VISUAL_BLOCK,
@@ -363,7 +404,7 @@ const (
 	PAGE_UP
 	PAGE_DOWN
 	NOP
-	SHIFT_TAB
+	SHIFT_TAB = terminal.KeyShiftTab
 )
 
 func (m Mode) String() string {
@@ -381,6 +422,9 @@ func (m Mode) String() string {
 		"PREVIEW",
 		"VIEW LOG",
 		"SPELLING",
+		"UNDO TREE",
+		"OUTLINE",
+		"RECOVERY",
 		"NAVIGATE_NOTICE",
 		"HELP",
 		"CONTAINER",
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -99,6 +100,64 @@ func (db *Database) keywordList() map[string]struct{} {
 	}
 	return keywords
 }
+
+// taskTitles returns the titles of notes containing s, most recently
+// modified first; used to complete [[links]]
+func (db *Database) taskTitles(s string, max int) []string {
+	rows, err := db.MainDB.Query("SELECT title FROM task WHERE deleted=False AND title LIKE ? "+
+		"ORDER BY modified DESC LIMIT ?;", "%"+s+"%", max)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var titles []string
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err == nil {
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// ftsVocab returns the terms in the full-text index that start with prefix,
+// the ones found in the most notes first. The fts5vocab table is created in
+// the temp schema so the fts database file isn't changed; temp tables belong
+// to a single connection so both statements run on the same one.
+func (db *Database) ftsVocab(prefix string, max int) []string {
+	if db.FtsDB == nil || prefix == "" {
+		return nil
+	}
+	ctx := context.Background()
+	conn, err := db.FtsDB.Conn(ctx)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "CREATE VIRTUAL TABLE IF NOT EXISTS temp.fts_vocab USING fts5vocab(main, fts, row);")
+	if err != nil {
+		return nil
+	}
+	prefix = strings.ToLower(prefix)
+	rows, err := conn.QueryContext(ctx, "SELECT term FROM temp.fts_vocab WHERE term > ? AND term < ? "+
+		"ORDER BY doc DESC LIMIT ?;", prefix, prefix+"\U0010FFFF", max)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err == nil {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
 func (db *Database) toggleStar(id int, state bool, table string) error {
 	s := fmt.Sprintf("UPDATE %s SET star=?, modified=datetime('now') WHERE id=?;",
 		table)
@@ -315,6 +374,30 @@ func (db *Database) insertSyncEntry(title, note string) {
 	}
 }
 
+// notesByTitle returns the ids and notes of the undeleted entries with
+// the title in the order every device agrees on: by server id, with the
+// entries not synced yet last
+func (db *Database) notesByTitle(title string) ([]int, []string, error) {
+	rows, err := db.MainDB.Query("SELECT id, note FROM task WHERE title=? AND deleted=False "+
+		"ORDER BY tid IS NULL OR tid < 1, tid, id;", title)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	var notes []string
+	for rows.Next() {
+		var id int
+		var note sql.NullString
+		if err := rows.Scan(&id, &note); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		notes = append(notes, note.String)
+	}
+	return ids, notes, rows.Err()
+}
+
 func (db *Database) readNoteIntoString(id int) string {
 	if id == -1 {
 		return "" // id given to new and unsaved entries
@@ -330,6 +413,130 @@ func (db *Database) readNoteIntoString(id int) string {
 	return note.String
 }
 
+// allNotes returns the notes of all entries, including deleted ones
+func (db *Database) allNotes() ([]string, error) {
+	rows, err := db.MainDB.Query("SELECT note FROM task WHERE note IS NOT NULL;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []string
+	for rows.Next() {
+		var note string
+		if err := rows.Scan(&note); err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// saveUndoHistory stores the editor undo history for a note; an empty
+// history removes any stored one
+func (db *Database) saveUndoHistory(id int, engine string, history []byte) error {
+	if len(history) == 0 {
+		_, err := db.MainDB.Exec("DELETE FROM undo_history WHERE task_id=?;", id)
+		return err
+	}
+	_, err := db.MainDB.Exec("INSERT INTO undo_history (task_id, engine, history, modified) "+
+		"VALUES (?, ?, ?, datetime('now')) "+
+		"ON CONFLICT(task_id) DO UPDATE SET engine=excluded.engine, history=excluded.history, modified=excluded.modified;",
+		id, engine, history)
+	return err
+}
+
+// readUndoHistory returns the stored undo history for a note if it was
+// written by the given vim implementation
+func (db *Database) readUndoHistory(id int, engine string) []byte {
+	var history []byte
+	err := db.MainDB.QueryRow("SELECT history FROM undo_history WHERE task_id=? AND engine=?;", id, engine).Scan(&history)
+	if err != nil {
+		return nil
+	}
+	return history
+}
+
+// saveRecovery stores the autosaved text of a note with unsaved changes
+func (db *Database) saveRecovery(id int, note string) error {
+	_, err := db.MainDB.Exec("INSERT INTO recovery (task_id, note, modified) "+
+		"VALUES (?, ?, datetime('now')) "+
+		"ON CONFLICT(task_id) DO UPDATE SET note=excluded.note, modified=excluded.modified;",
+		id, note)
+	return err
+}
+
+// deleteRecovery removes a note's autosaved text
+func (db *Database) deleteRecovery(id int) error {
+	_, err := db.MainDB.Exec("DELETE FROM recovery WHERE task_id=?;", id)
+	return err
+}
+
+// insertAttachment stores a file attached to a note and returns its uuid;
+// a file that is already attached isn't stored again
+func (db *Database) insertAttachment(mime string, data []byte) (string, error) {
+	hash := hashBytes(data)
+	var id string
+	err := db.MainDB.QueryRow("SELECT uuid FROM attachment WHERE hash=?;", hash).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+	id = generateUUID()
+	_, err = db.MainDB.Exec("INSERT INTO attachment (uuid, mime, bytes, hash, created) VALUES (?, ?, ?, ?, datetime('now'));",
+		id, mime, data, hash)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// readAttachment returns the mime type and contents of an attachment
+func (db *Database) readAttachment(id string) (string, []byte, error) {
+	var mime string
+	var data []byte
+	err := db.MainDB.QueryRow("SELECT mime, bytes FROM attachment WHERE uuid=?;", id).Scan(&mime, &data)
+	if err == sql.ErrNoRows {
+		return "", nil, fmt.Errorf("no attachment %s", id)
+	}
+	return mime, data, err
+}
+
+type recoveredNote struct {
+	id       int
+	title    string
+	note     string
+	modified string
+}
+
+// recoveredNotes returns the autosaved text that is newer than, and
+// different from, the saved notes; the rest is deleted
+func (db *Database) recoveredNotes() ([]recoveredNote, error) {
+	_, err := db.MainDB.Exec("DELETE FROM recovery WHERE task_id NOT IN " +
+		"(SELECT recovery.task_id FROM recovery JOIN task ON task.id=recovery.task_id " +
+		"WHERE datetime(recovery.modified) > datetime(task.modified) AND recovery.note != COALESCE(task.note, ''));")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.MainDB.Query("SELECT recovery.task_id, task.title, recovery.note, recovery.modified " +
+		"FROM recovery JOIN task ON task.id=recovery.task_id ORDER BY recovery.modified DESC;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notes []recoveredNote
+	for rows.Next() {
+		var r recoveredNote
+		if err := rows.Scan(&r.id, &r.title, &r.note, &r.modified); err != nil {
+			return nil, err
+		}
+		notes = append(notes, r)
+	}
+	return notes, rows.Err()
+}
+
 func (db *Database) readSyncLog(id int) string {
 	row := db.MainDB.QueryRow("SELECT note FROM sync_log WHERE id=?;", id)
 	var note string
@@ -463,9 +670,17 @@ func getTaskKeywordTids(id int) []int {
 		return keyword_tids
 	}
 */
+// ftsHighlightStart and ftsHighlightEnd surround the search terms in the
+// titles of search results; the organizer draws them in the theme's
+// search highlight
+const (
+	ftsHighlightStart = "\x1b[48;5;31m"
+	ftsHighlightEnd   = "\x1b[49m"
+)
+
 func (db *Database) updateFtsTitle(st string, row *Row) error {
 	tid := db.entryTidFromId(row.id)
-	r := db.FtsDB.QueryRow("SELECT highlight(fts, 0, '\x1b[48;5;31m', '\x1b[49m') FROM fts WHERE fts MATCH ? and tid=?;", st, tid)
+	r := db.FtsDB.QueryRow("SELECT highlight(fts, 0, '"+ftsHighlightStart+"', '"+ftsHighlightEnd+"') FROM fts WHERE fts MATCH ? and tid=?;", st, tid)
 	var ftsTitle string
 	err := r.Scan(&ftsTitle)
 	if err != nil {
@@ -476,7 +691,7 @@ func (db *Database) updateFtsTitle(st string, row *Row) error {
 }
 
 func (db *Database) searchEntries(st, sort string, showDeleted, help bool) ([]Row, error) {
-	rows, err := db.FtsDB.Query("SELECT tid, highlight(fts, 0, '\x1b[48;5;31m', '\x1b[49m') "+
+	rows, err := db.FtsDB.Query("SELECT tid, highlight(fts, 0, '"+ftsHighlightStart+"', '"+ftsHighlightEnd+"') "+
 		"FROM fts WHERE fts MATCH ? ORDER BY bm25(fts, 2.0, 1.0, 5.0);", st)
 	if err != nil {
 		return []Row{}, err
@@ -964,16 +1179,9 @@ func (db *Database) copyEntry(sourceID int) (int, error) {
 }
 
 func (db *Database) updateCodeFile(id int, text string) {
-	var filePath string
-	lang := Languages[db.taskContext(id)]
-	if lang == "cpp" {
-		filePath = "/home/slzatz/clangd_examples/test.cpp"
-	} else if lang == "go" {
-		filePath = "/home/slzatz/vmgo_go_code/main.go"
-	} else if lang == "python" {
-		filePath = "/home/slzatz/python_fragments/main.py"
-	} else {
-		app.Organizer.ShowMessage(BL, "I don't recognize %q", db.taskContext(id))
+	filePath, err := codeFile(Languages[db.taskContext(id)])
+	if err != nil {
+		app.Organizer.ShowMessage(BL, "%v", err)
 		return
 	}
 
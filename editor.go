@@ -45,6 +45,31 @@ type Editor struct {
 		list  []string
 		index int
 	}
+	undoStates      []interfaces.UndoState // states shown by :undotree
+	undoIndex       int                    // selected row in :undotree
+	undoSavedSeq    int                    // undo state when the note was last written
+	searchHighlight bool                   // highlight matches of the last search (hlsearch)
+	searchMatches   struct {               // matches cached by pattern and buffer tick
+		pattern string
+		tick    int
+		matches []interfaces.SearchMatch
+	}
+	completion            completion                    // insert-mode completion menu
+	lspLang               string                        // language of a code note with a language server
+	lspPath               string                        // file the note is synced to the server as
+	lspVersion            int                           // document version last sent to the server
+	lspTick               int                           // buffer tick when the note was last sent
+	filterKeys            string                        // keys typed so far of a !{motion} filter
+	visualRows            [2]int                        // rows of the last visual selection, for '<,'>
+	exRange               bool                          // the command line started with '<,'>
+	heldKey               string                        // [, ] or z waiting to see if it starts an editor command
+	charArg               bool                          // vim takes the next key as the argument of f, t, r ...
+	outlineIndex          int                           // selected heading in :outline
+	folds                 map[int]int                   // closed folds, first row -> last row
+	spellWords            []Position                    // misspelled words listed by :spellreport
+	spellIndex            int                           // selected word in :spellreport
+	foldRows              int                           // number of rows when the folds were last synced
+	autosaved             string                        // text last written to the recovery table
 	normalCmds            map[string]func(*Editor, int) // map of normal commands
 	exCmds                map[string]func(*Editor)      // map of ex commands
 	commandRegistry       *CommandRegistry[func(*Editor)]
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"image/color"
-	"io"
 	"os"
 	"os/exec"
 	"regexp"
@@ -35,12 +33,36 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 	})
 
 	registry.Register("compile", (*Editor).compile, CommandInfo{
-		Aliases:     []string{"compile", "run"},
-		Description: "Compile current code file",
+		Description: "Build the current code note with its language's runner",
 		Usage:       "compile",
-		Category:    "File Operations",
+		Category:    "Code",
 		Examples:    []string{":compile"},
 	})
+	registry.Register("run", (*Editor).run, CommandInfo{
+		Description: "Build and run the current code note; arguments are passed to the program",
+		Usage:       "run [args]",
+		Category:    "Code",
+		Examples:    []string{":run", ":run -n 10"},
+	})
+	registry.Register("test", (*Editor).test, CommandInfo{
+		Description: "Run the tests for the current code note",
+		Usage:       "test [args]",
+		Category:    "Code",
+		Examples:    []string{":test", ":test -run TestParse"},
+	})
+	registry.Register("runblock", (*Editor).runBlock, CommandInfo{
+		Aliases:     []string{"rb"},
+		Description: "Run the fenced code block under the cursor and put its output below it",
+		Usage:       "runblock",
+		Category:    "Code",
+		Examples:    []string{":runblock", ":rb"},
+	})
+	registry.Register("runall", (*Editor).runAllBlocks, CommandInfo{
+		Description: "Run every fenced code block in the note in order, stopping at the first failure",
+		Usage:       "runall",
+		Category:    "Code",
+		Examples:    []string{":runall"},
+	})
 	registry.Register("writeall", (*Editor).writeAll, CommandInfo{
 		Aliases:     []string{"wa"},
 		Description: "Save all open notes",
@@ -51,10 +73,10 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 
 	registry.Register("read", (*Editor).readFile, CommandInfo{
 		Aliases:     []string{"r"},
-		Description: "Read contents from file into current note",
-		Usage:       "read <filename>",
+		Description: "Read contents from file, or the output of a shell command, into current note",
+		Usage:       "read <filename> | read !<command>",
 		Category:    "File Operations",
-		Examples:    []string{":read todo.txt", ":r /tmp/notes.md"},
+		Examples:    []string{":read todo.txt", ":r /tmp/notes.md", ":r !date"},
 	})
 
 	registry.Register("save", (*Editor).saveNoteToFile, CommandInfo{
@@ -65,6 +87,21 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 		Examples:    []string{":save backup.txt", ":savefile /tmp/note.md"},
 	})
 
+	registry.Register("attach", (*Editor).attach, CommandInfo{
+		Description: "Store a file in the database and insert a reference to it (attach:<uuid>) at the cursor",
+		Usage:       "attach <path>",
+		Category:    "File Operations",
+		Examples:    []string{":attach ~/Pictures/screenshot.png", ":attach diagram.svg"},
+	})
+
+	registry.Register("pasteimage", (*Editor).pasteImage, CommandInfo{
+		Aliases:     []string{"pi"},
+		Description: "Insert the clipboard image at the cursor, stored as an attachment or uploaded to Google Drive (images.paste_to)",
+		Usage:       "pasteimage",
+		Category:    "File Operations",
+		Examples:    []string{":pasteimage", ":pi"},
+	})
+
 	// Editing commands
 	registry.Register("syntax", (*Editor).syntax, CommandInfo{
 		Description: "Set syntax highlighting for current note",
@@ -81,6 +118,20 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 		Examples:    []string{":number", ":num"},
 	})
 
+	registry.Register("mouse", (*Editor).toggleMouse, CommandInfo{
+		Description: "Turn the mouse on or off; off leaves text selection to the terminal",
+		Usage:       "mouse [on|off]",
+		Category:    "Editing",
+		Examples:    []string{":mouse", ":mouse off"},
+	})
+
+	registry.Register("theme", (*Editor).setTheme, CommandInfo{
+		Description: "Show the theme or switch to another (default, mono or a file in themes/)",
+		Usage:       "theme [name]",
+		Category:    "Editing",
+		Examples:    []string{":theme", ":theme mono", ":theme light"},
+	})
+
 	registry.Register("paste", (*Editor).paste, CommandInfo{
 		//Aliases:     []string{"num"},
 		Description: "Autoindent off - good for pasting content",
@@ -96,6 +147,68 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 		Category:    "Editing",
 		Examples:    []string{":nopaste"},
 	})
+	registry.Register("table", (*Editor).table, CommandInfo{
+		Aliases:     []string{"tbl"},
+		Description: "Align or edit the markdown table under the cursor; csv makes one from comma or tab separated lines",
+		Usage:       "table [align|addrow|delrow|addcol|delcol|left|right|sort [column] [desc]|csv]",
+		Category:    "Editing",
+		Examples:    []string{":table", ":table addcol", ":table sort 2 desc", ":'<,'>table csv"},
+	})
+	registry.Register("earlier", (*Editor).earlier, CommandInfo{
+		Aliases:     []string{"ea"},
+		Description: "Go back in the undo history by count or time",
+		Usage:       "earlier [N | N{s,m,h,d}]",
+		Category:    "Editing",
+		Examples:    []string{":earlier 3", ":earlier 10m", ":ea 1h"},
+	})
+
+	registry.Register("later", (*Editor).later, CommandInfo{
+		Aliases:     []string{"lat"},
+		Description: "Go forward in the undo history by count or time",
+		Usage:       "later [N | N{s,m,h,d}]",
+		Category:    "Editing",
+		Examples:    []string{":later 3", ":later 10m", ":lat 1h"},
+	})
+
+	registry.Register("undotree", (*Editor).undoTree, CommandInfo{
+		Aliases:     []string{"undolist"},
+		Description: "Browse the note's undo history and jump to a state",
+		Usage:       "undotree",
+		Category:    "Editing",
+		Examples:    []string{":undotree", ":undolist"},
+	})
+
+	registry.Register("spellreport", (*Editor).spellReport, CommandInfo{
+		Aliases:     []string{"spr"},
+		Description: "List the note's misspelled words and go to one",
+		Usage:       "spellreport",
+		Category:    "Editing",
+		Examples:    []string{":spellreport", ":spr"},
+	})
+
+	registry.Register("spelllang", (*Editor).setSpellLang, CommandInfo{
+		Aliases:     []string{"spl"},
+		Description: "Show the note's spelling dictionary or set it for this note",
+		Usage:       "spelllang [lang]",
+		Category:    "Editing",
+		Examples:    []string{":spelllang", ":spelllang de_DE"},
+	})
+
+	registry.Register("outline", (*Editor).outline, CommandInfo{
+		Aliases:     []string{"ol"},
+		Description: "Browse the note's headings; promote, demote or move sections",
+		Usage:       "outline",
+		Category:    "Navigation",
+		Examples:    []string{":outline", ":ol"},
+	})
+
+	registry.Register("nohlsearch", (*Editor).noHighlightSearch, CommandInfo{
+		Aliases:     []string{"noh"},
+		Description: "Stop highlighting search matches until the next search",
+		Usage:       "nohlsearch",
+		Category:    "Editing",
+		Examples:    []string{":nohlsearch", ":noh"},
+	})
 	/*
 		registry.Register("fmt", (*Editor).goFormat, CommandInfo{
 			Name:        "fmt",
@@ -104,15 +217,6 @@ func (a *App) setEditorExCmds(editor *Editor) map[string]func(*Editor) {
 			Category:    "Editing",
 			Examples:    []string{":fmt"},
 		})
-
-			registry.Register("run", (*Editor).run, CommandInfo{
-				Name:        "run",
-				Aliases:     []string{"r"},
-				Description: "Execute current note as code",
-				Usage:       "run",
-				Category:    "Editing",
-				Examples:    []string{":run", ":r"},
-			})
 	*/
 
 	// Layout commands
@@ -382,6 +486,11 @@ func (e *Editor) writeNote() {
 		return
 	}
 	e.ShowMessage(BL, "Updated note and fts entry for entry %d", e.id) //////
+	e.saveUndoHistory()
+	e.discardRecovery()
+	if personalWords.isNote(e.id) {
+		personalWords.loaded = false // the word list was edited directly
+	}
 
 	//explicitly writes note to set isModified to false
 	//vim.Execute("w")
@@ -484,197 +593,6 @@ func (e *Editor) resize() {
 }
 */
 
-func (e *Editor) compile() {
-
-	var dir, filePath string
-	var args []string
-	var cmd *exec.Cmd
-	var cmd0 *exec.Cmd
-	lang := Languages[e.Database.taskContext(e.id)]
-	dir = "/home/slzatz/vimango_" + lang + "_code/"
-	filePath = dir + "main.go"
-	// Recursively create directories
-	err := os.MkdirAll(dir, os.ModePerm) // os.ModePerm is 0777
-	if err != nil {
-		e.ShowMessage(BR, "Error creating directory %s: %v", dir, err)
-		return
-	}
-	f, err := os.Create(filePath)
-	if err != nil {
-		e.ShowMessage(BR, "Error creating file %s: %v", filePath, err)
-		return
-	}
-	defer f.Close()
-	_, err = f.WriteString(strings.Join(e.ss, "\n"))
-	if err != nil {
-		e.ShowMessage(BR, "Error writing file %s: %v", filePath, err)
-		return
-	}
-	e.ShowMessage(BR, "Code file written to %s", filePath)
-	if lang == "cpp" {
-		dir = "/home/slzatz/clangd_examples/"
-		cmd = exec.Command("make")
-	} else if lang == "go" {
-		cmd0 = exec.Command("go", "mod", "tidy")
-
-		// 2. Set the working directory to where your main.go and go.mod live
-		cmd0.Dir = dir
-
-		// 3. Execute and capture both Standard Output and Standard Error
-		// This is helpful because 'go mod tidy' prints its progress to Stderr
-		output, err := cmd0.CombinedOutput()
-		if err != nil {
-			e.ShowMessage(BR, "go mod tidy failed: %w\nOutput: %s", err, string(output))
-			return
-		}
-		//cmd = exec.Command("go", "run", "main.go")
-		pos := strings.Index(e.command_line, " ")
-		if pos != -1 {
-			args = strings.Split(e.command_line[pos+1:], " ")
-		}
-		args = append([]string{"run", "main.go"}, args...)
-		cmd = exec.Command("go", args...)
-	} else if lang == "python" {
-		e.ShowMessage(BR, "You don't have to compile python")
-		return
-	} else {
-		e.ShowMessage(BR, "I don't recognize %q", e.Database.taskContext(e.id))
-		return
-	}
-	cmd.Dir = dir
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		e.ShowMessage(BR, "Error in compile creating stdout pipe: %v", err)
-		return
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		e.ShowMessage(BR, "Error in compile creating stderr pipe: %v", err)
-		return
-	}
-
-	err = cmd.Start()
-	if err != nil {
-		e.ShowMessage(BR, "Error in compile starting command: %v", err)
-		return
-	}
-
-	buffer_out := bufio.NewReader(stdout)
-	buffer_err := bufio.NewReader(stderr)
-
-	var rows []string
-	rows = append(rows, "## output")
-
-	for {
-		bytes, _, err := buffer_out.ReadLine()
-		if err == io.EOF {
-			break
-		}
-		rows = append(rows, string(bytes))
-	}
-
-	for {
-		bytes, _, err := buffer_err.ReadLine()
-		if err == io.EOF {
-			break
-		}
-		rows = append(rows, string(bytes))
-	}
-	if len(rows) == 1 {
-		rows = append(rows, "The code compiled successfully")
-	}
-
-	//rows = append(rows, "------------------------")
-
-	e.mode = NORMAL //HELP //RUN //// revist this
-	result := strings.Join(rows, "\n")
-	app.Screen.altRowoff = 0
-	app.Screen.drawNotice(result, false, TL) // isMarkdown = false
-	e.command_line = ""
-}
-
-/*
-func (e *Editor) run() {
-
-	var args []string
-	//var combinedArgs []string
-	var name string
-	var dir string
-	var cmd *exec.Cmd
-
-	pos := strings.Index(e.command_line, " ")
-	if pos != -1 {
-		args = strings.Split(e.command_line[pos+1:], " ")
-	}
-
-	lang := Languages[e.Database.taskContext(e.id)]
-	if lang == "go" {
-		dir = "/home/slzatz/vmgo_go_code/"
-		name = "go"
-		args = append([]string{"run", "main.go"}, args...)
-	} else if lang == "python" {
-		dir = "/home/slzatz/vmgo_python_code/"
-		name = "python"
-		args = append([]string{"main.py"}, args...)
-	} else {
-		e.ShowMessage(BR, "I don't recognize %q", e.Database.taskContext(e.id))
-		return
-	}
-	cmd = exec.Command(name, args...)
-	cmd.Dir = dir
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		e.ShowMessage(BR, "Error in run creating stdout pipe: %v", err)
-		return
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		e.ShowMessage(BR, "Error in run creating stderr pipe: %v", err)
-		return
-	}
-
-	err = cmd.Start()
-	if err != nil {
-		e.ShowMessage(BR, "Error in run starting command: %v", err)
-		return
-	}
-
-	buffer_out := bufio.NewReader(stdout)
-	buffer_err := bufio.NewReader(stderr)
-
-	var rows []string
-	rows = append(rows, "------------------------")
-
-	for {
-		bytes, _, err := buffer_out.ReadLine()
-		if err == io.EOF {
-			break
-		}
-		rows = append(rows, string(bytes))
-	}
-
-	for {
-		bytes, _, err := buffer_err.ReadLine()
-		if err == io.EOF {
-			break
-		}
-		rows = append(rows, string(bytes))
-	}
-
-	rows = append(rows, "------------------------")
-
-	op := e.output
-	op.rowOffset = 0
-	op.rows = rows
-	op.drawText()
-	// no need to call drawFrame or drawStatusBar
-}
-*/
-
 func (e *Editor) syntax() {
 	e.highlightSyntax = !e.highlightSyntax
 	if e.highlightSyntax {
@@ -716,6 +634,10 @@ func (e *Editor) quitActions() {
 			e.ShowMessage(BR, "Error in updateNote for entry with id %d: %v", e.id, err)
 		}
 		e.ShowMessage(BL, "Updated note and fts entry for entry %d", e.id) //////
+		e.saveUndoHistory()
+		if personalWords.isNote(e.id) {
+			personalWords.loaded = false
+		}
 
 	} else if cmd == "q!" || cmd == "quit!" {
 		// do nothing = allow editor to be closed
@@ -728,6 +650,8 @@ func (e *Editor) quitActions() {
 		return
 	}
 
+	e.discardRecovery()
+	e.lspClose()
 	vim.ExecuteCommand("bw") // wipout the buffer
 
 	if len(e.Session.Editors) == 1 {
@@ -773,6 +697,7 @@ func (e *Editor) quitAll() {
 			editorsToKeep = append(editorsToKeep, ed)
 		} else {
 			// Clean up the vim buffer for editors we're closing
+			ed.discardRecovery()
 			vim.SetCurrentBuffer(ed.vbuf)
 			vim.ExecuteCommand("bw") // wipeout buffer
 		}
@@ -901,7 +826,9 @@ func (e *Editor) createPDF() {
 		TextColor: mdtopdf.Color{Red: 0, Green: 0, Blue: 0},
 		FillColor: mdtopdf.Color{Red: 255, Green: 255, Blue: 255}}
 
-	content := strings.Join(e.ss, "\n")
+	// mdtopdf reads images from files and doesn't know image attributes
+	content, cleanup := attachmentsToFiles(imageAttrsForPDF(strings.Join(e.ss, "\n")))
+	defer cleanup()
 	err := pf.Process([]byte(content))
 	if err != nil {
 		e.ShowMessage(BL, "pdf error:%v", err)
@@ -926,8 +853,9 @@ func (e *Editor) createPDFGoldmark() {
 	}
 	filename := e.command_line[pos+1:]
 
-	// Get markdown content from editor
-	content := strings.Join(e.ss, "\n")
+	// Get markdown content from editor, with images that have size or
+	// alignment attributes drawn at their size
+	content := imageAttrsForPDF(strings.Join(e.ss, "\n"))
 
 	// Pre-process markdown to handle Google Drive images
 	processedMarkdown, err := preprocessMarkdownImages(content)
@@ -1011,7 +939,9 @@ func (e *Editor) printDocument() {
 			e.ShowMessage(BR, "Error creating pdf from code: %v", err)
 		}
 	} else {
-		content := strings.Join(e.ss, "\n")
+		// mdtopdf reads images from files and doesn't know image attributes
+		content, cleanup := attachmentsToFiles(imageAttrsForPDF(strings.Join(e.ss, "\n")))
+		defer cleanup()
 
 		params := mdtopdf.PdfRendererParams{
 			Orientation: "",
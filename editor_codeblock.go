@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Fenced code blocks in markdown notes can be run notebook-style with
+// :runblock and :runall. A block is written to the directory of its
+// language's runner (see runner.go) and run with the runner's build and run
+// commands; the output goes into an ```output block directly below the
+// block, replacing the one left by the previous run.
+
+// fenceLanguages maps the info string of a fenced block to a runner
+var fenceLanguages = map[string]string{
+	"go":     "go",
+	"golang": "go",
+	"python": "python",
+	"py":     "python",
+	"cpp":    "cpp",
+	"c++":    "cpp",
+	"sh":     "sh",
+	"bash":   "sh",
+	"shell":  "sh",
+}
+
+const outputInfo = "output"
+
+// fencedBlock is a fenced code block; start and end are the rows of its
+// opening and closing fences
+type fencedBlock struct {
+	info       string
+	start, end int
+}
+
+// fenceOpen returns the fence and info string if row opens a fenced block
+func fenceOpen(row string) (fence, info string, ok bool) {
+	trimmed := strings.TrimLeft(row, " ")
+	if len(row)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return "", "", false
+	}
+	c := trimmed[0]
+	if c != '`' && c != '~' {
+		return "", "", false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == c {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+	info = strings.TrimSpace(trimmed[n:])
+	if c == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	if i := strings.IndexAny(info, " \t{"); i != -1 {
+		info = info[:i]
+	}
+	return trimmed[:n], strings.ToLower(info), true
+}
+
+// fenceCloses reports whether row closes a block opened with fence
+func fenceCloses(row, fence string) bool {
+	trimmed := strings.TrimSpace(row)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// fencedBlocks returns the closed fenced blocks in rows
+func fencedBlocks(rows []string) []fencedBlock {
+	var blocks []fencedBlock
+	for i := 0; i < len(rows); i++ {
+		fence, info, ok := fenceOpen(rows[i])
+		if !ok {
+			continue
+		}
+		for j := i + 1; j < len(rows); j++ {
+			if fenceCloses(rows[j], fence) {
+				blocks = append(blocks, fencedBlock{info, i, j})
+				i = j
+				break
+			}
+		}
+	}
+	return blocks
+}
+
+// runnableBlocks returns the blocks that have a runner
+func runnableBlocks(rows []string) []fencedBlock {
+	var runnable []fencedBlock
+	for _, b := range fencedBlocks(rows) {
+		if _, ok := fenceLanguages[b.info]; ok {
+			runnable = append(runnable, b)
+		}
+	}
+	return runnable
+}
+
+// outputRows returns the rows of the output block directly below b, or
+// b.end+1, b.end+1 if there isn't one
+func outputRows(rows []string, b fencedBlock) (start, end int) {
+	for _, o := range fencedBlocks(rows[b.end+1:]) {
+		if o.start == 0 && o.info == outputInfo {
+			return b.end + 1, b.end + 1 + o.end + 1
+		}
+		break
+	}
+	return b.end + 1, b.end + 1
+}
+
+// runBlock runs the fenced block under the cursor (:runblock)
+func (e *Editor) runBlock() {
+	defer func() {
+		e.mode = NORMAL
+		e.command_line = ""
+	}()
+	blocks := runnableBlocks(e.ss)
+	for i, b := range blocks {
+		if e.fr >= b.start && e.fr <= b.end {
+			e.startBlock(i, false)
+			return
+		}
+	}
+	e.ShowMessage(BR, "The cursor isn't in a fenced code block that can be run")
+}
+
+// runAllBlocks runs every fenced block in the note in order, stopping at
+// the first that fails (:runall)
+func (e *Editor) runAllBlocks() {
+	defer func() {
+		e.mode = NORMAL
+		e.command_line = ""
+	}()
+	if len(runnableBlocks(e.ss)) == 0 {
+		e.ShowMessage(BR, "The note has no fenced code blocks that can be run")
+		return
+	}
+	e.startBlock(0, true)
+}
+
+// startBlock runs the index-th runnable block and, if all is set, the ones
+// after it as each finishes
+func (e *Editor) startBlock(index int, all bool) {
+	if r := e.Session.run; r != nil && !r.finished() {
+		e.ShowMessage(BR, "%q is still running; Ctrl-C stops it", r.title)
+		return
+	}
+	blocks := runnableBlocks(e.ss)
+	if index >= len(blocks) {
+		return
+	}
+	b := blocks[index]
+	source := strings.Join(e.ss[b.start+1:b.end], "\n") + "\n"
+	r, err := startRunner(fenceLanguages[b.info], blockRunDir, "run", source, "")
+	if err != nil {
+		e.ShowMessage(BR, "Block %d: %v", index+1, err)
+		return
+	}
+	r.title = fmt.Sprintf("block %d (%s)", index+1, b.info)
+	r.then = func(r *codeRun) {
+		if !slices.Contains(e.Session.Editors, e) {
+			return
+		}
+		e.insertBlockOutput(index, r)
+		if !all {
+			return
+		}
+		if !r.ok {
+			e.ShowMessage(BR, "Block %d failed %s; not running the rest", index+1, r.status)
+			return
+		}
+		if index+1 < len(runnableBlocks(e.ss)) {
+			e.startBlock(index+1, true)
+		} else {
+			e.ShowMessage(BR, "Ran all %d blocks", index+1)
+		}
+	}
+	e.Session.run = r
+	if app.Session.editorMode {
+		r.draw()
+	}
+	e.ShowMessage(BR, "Running block %d (Ctrl-C stops it)", index+1)
+}
+
+// insertBlockOutput puts the output of the index-th block's run below it
+func (e *Editor) insertBlockOutput(index int, r *codeRun) {
+	e.ss = e.vbuf.Lines()
+	blocks := runnableBlocks(e.ss)
+	if index >= len(blocks) {
+		e.ShowMessage(BR, "Block %d is gone; its output wasn't inserted", index+1)
+		return
+	}
+	b := blocks[index]
+
+	out := r.output()
+	if !r.ok {
+		out = append(out, r.status)
+	}
+	// a fence longer than any run of backticks in the output
+	fence := "```"
+	for strings.Contains(strings.Join(out, "\n"), fence) {
+		fence += "`"
+	}
+	lines := append([]string{fence + outputInfo}, out...)
+	lines = append(lines, fence)
+
+	start, end := outputRows(e.ss, b)
+	ss := make([]string, 0, len(e.ss)+len(lines)-(end-start))
+	ss = append(ss, e.ss[:start]...)
+	ss = append(ss, lines...)
+	ss = append(ss, e.ss[end:]...)
+
+	active := e == e.Session.activeEditor
+	pos := vim.GetCursorPosition()
+	e.vbuf.SetLines(0, -1, ss)
+	e.ss = e.vbuf.Lines()
+	if active && app.Session.editorMode {
+		// keep the cursor on the same text
+		row := pos[0] - 1
+		if row >= end {
+			row += len(lines) - (end - start)
+		} else if row >= start {
+			row = b.end
+		}
+		if row >= len(e.ss) {
+			row = len(e.ss) - 1
+		}
+		if row < 0 {
+			row = 0
+		}
+		col := pos[1]
+		if col > len(e.ss[row]) {
+			col = len(e.ss[row])
+		}
+		vim.SetCursorPosition(row+1, col)
+		e.fr = row
+		e.fc = utf8.RuneCountInString(e.ss[row][:col])
+		e.scroll()
+		e.drawText()
+		e.drawStatusBar()
+	}
+}
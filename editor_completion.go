@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Insert-mode completion. Ctrl-N/Ctrl-P complete the word before the cursor
+// from the language server of a code note or else from the current note,
+// the other open notes and the full-text index vocabulary; typing [[
+// completes note titles and #word completes keywords. Candidates are typed
+// into vim as ordinary insert-mode keys so undo and the buffer tick behave
+// as if the user had typed them.
+
+type completionKind int
+
+const (
+	completeWords completionKind = iota
+	completeTitles
+	completeKeywords
+)
+
+const (
+	completionMenuHeight = 8
+	completionMaxItems   = 50
+)
+
+var completionWordRe = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+type completion struct {
+	active   bool
+	kind     completionKind
+	row      int    // buffer row being completed
+	start    int    // byte column where the completed text starts
+	query    string // what the user typed from start
+	inserted string // what is currently in the buffer from start to the cursor
+	items    []string
+	index    int // selected item; -1 when only the query is shown
+	top      int // first item shown in the menu
+}
+
+// InsertModeKeyHandler intercepts the keys that drive the completion menu;
+// everything else goes to vim
+func (e *Editor) InsertModeKeyHandler(c int) (redraw, exit bool) {
+	if !e.completion.active {
+		switch c {
+		case ctrlKey('n'):
+			return e.startWordCompletion(1), true
+		case ctrlKey('p'):
+			return e.startWordCompletion(-1), true
+		case '\t':
+			// Tab and Shift-Tab move between the cells of a markdown table
+			if e.tableTab(1) {
+				return true, true
+			}
+		case SHIFT_TAB:
+			if e.tableTab(-1) {
+				return true, true
+			}
+		}
+		return false, false
+	}
+
+	switch c {
+	case ctrlKey('n'), ARROW_DOWN:
+		e.selectCompletion(1)
+		return true, true
+	case ctrlKey('p'), ARROW_UP:
+		e.selectCompletion(-1)
+		return true, true
+	case ctrlKey('y'):
+		e.acceptCompletion()
+		return true, true
+	case '\r':
+		if e.completion.index >= 0 {
+			e.acceptCompletion()
+			return true, true
+		}
+	case ctrlKey('e'):
+		e.replaceCompletion(e.completion.query)
+		e.closeCompletion()
+		return true, true
+	}
+
+	// typing after a candidate has been picked keeps it; while nothing is
+	// picked titles and keywords are filtered by the text being typed
+	if e.completion.kind == completeWords || e.completion.index >= 0 {
+		e.closeCompletion()
+	}
+	return false, false
+}
+
+// updateCompletion runs after vim has handled an insert-mode key. It opens
+// the title or keyword menu when [[ or #word starts and refilters it as the
+// query is typed.
+func (e *Editor) updateCompletion(c int) bool {
+	if e.mode != INSERT {
+		if e.completion.active {
+			e.closeCompletion()
+			return true
+		}
+		return false
+	}
+	pos := vim.GetCursorPosition()
+	row, col := pos[0]-1, pos[1]
+	if row < 0 || row >= len(e.ss) || col > len(e.ss[row]) {
+		return false
+	}
+	line := e.ss[row]
+
+	if e.completion.active {
+		if row != e.completion.row || col < e.completion.start {
+			e.closeCompletion()
+			return true
+		}
+		e.completion.query = line[e.completion.start:col]
+		e.completion.inserted = e.completion.query
+		e.completion.items = e.completionItems(e.completion.kind, e.completion.query)
+		e.completion.index = -1
+		e.completion.top = 0
+		if len(e.completion.items) == 0 {
+			e.closeCompletion()
+		}
+		return true
+	}
+
+	switch {
+	case c == '[' && strings.HasSuffix(line[:col], "[["):
+		return e.openCompletion(completeTitles, row, col)
+	case c == '#' && col >= 2:
+		// a # at the start of a line is a heading, not a keyword
+		r, _ := utf8.DecodeLastRuneInString(line[:col-1])
+		if unicode.IsSpace(r) {
+			return e.openCompletion(completeKeywords, row, col)
+		}
+	}
+	return false
+}
+
+// startWordCompletion completes the word before the cursor and selects the
+// first candidate found searching forward (dir 1) or backward (dir -1)
+func (e *Editor) startWordCompletion(dir int) bool {
+	pos := vim.GetCursorPosition()
+	row, col := pos[0]-1, pos[1]
+	if row < 0 || row >= len(e.ss) || col > len(e.ss[row]) {
+		return false
+	}
+	line := e.ss[row]
+	start := col
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:start])
+		if !isWordRune(r) {
+			break
+		}
+		start -= size
+	}
+	prefix := line[start:col]
+
+	e.completion = completion{
+		kind:     completeWords,
+		row:      row,
+		start:    start,
+		query:    prefix,
+		inserted: prefix,
+		index:    -1,
+	}
+	// a code note's language server knows better than the words around it
+	e.completion.items = e.lspCompletions(prefix)
+	if len(e.completion.items) == 0 {
+		e.completion.items = e.wordCandidates(prefix, row, col, dir)
+	}
+	if len(e.completion.items) == 0 {
+		e.ShowMessage(BR, "No completions for %q", prefix)
+		return false
+	}
+	e.completion.active = true
+	e.selectCompletion(1)
+	return true
+}
+
+// openCompletion shows every title or keyword for the text typed after col
+func (e *Editor) openCompletion(kind completionKind, row, col int) bool {
+	items := e.completionItems(kind, "")
+	if len(items) == 0 {
+		return false
+	}
+	e.completion = completion{
+		active: true,
+		kind:   kind,
+		row:    row,
+		start:  col,
+		items:  items,
+		index:  -1,
+	}
+	return true
+}
+
+func (e *Editor) completionItems(kind completionKind, query string) []string {
+	switch kind {
+	case completeTitles:
+		return e.Database.taskTitles(query, completionMaxItems)
+	case completeKeywords:
+		var items []string
+		q := strings.ToLower(query)
+		for k := range e.Database.keywordList() {
+			if strings.HasPrefix(strings.ToLower(k), q) {
+				items = append(items, k)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return strings.ToLower(items[i]) < strings.ToLower(items[j]) })
+		return items
+	}
+	return nil
+}
+
+// wordCandidates collects the words starting with prefix: first from the
+// current note, nearest to the cursor in the search direction, then from
+// the other open notes and finally from the full-text index vocabulary
+func (e *Editor) wordCandidates(prefix string, row, col, dir int) []string {
+	seen := map[string]struct{}{}
+	var items []string
+	add := func(text string, reverse bool) {
+		words := completionWordRe.FindAllString(text, -1)
+		for i := range words {
+			w := words[i]
+			if reverse {
+				w = words[len(words)-1-i]
+			}
+			if len(items) >= completionMaxItems || len(w) <= len(prefix) || !strings.HasPrefix(w, prefix) {
+				continue
+			}
+			if _, ok := seen[w]; !ok {
+				seen[w] = struct{}{}
+				items = append(items, w)
+			}
+		}
+	}
+
+	// like vim, search from the cursor to the end of the note and wrap
+	n := len(e.ss)
+	if dir > 0 {
+		add(e.ss[row][col:], false)
+		for i := 1; i < n; i++ {
+			add(e.ss[(row+i)%n], false)
+		}
+		add(e.ss[row][:col], false)
+	} else {
+		add(e.ss[row][:col], true)
+		for i := 1; i < n; i++ {
+			add(e.ss[(row-i+n)%n], true)
+		}
+		add(e.ss[row][col:], true)
+	}
+
+	for _, ed := range e.Session.Editors {
+		if ed != e {
+			for _, l := range ed.ss {
+				add(l, false)
+			}
+		}
+	}
+
+	// index terms are lower case so keep the case the user typed
+	lower := strings.ToLower(prefix)
+	for _, term := range e.Database.ftsVocab(prefix, completionMaxItems) {
+		if strings.HasPrefix(term, lower) {
+			add(prefix+term[len(lower):], false)
+		}
+	}
+	return items
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// selectCompletion moves the selection and puts the selected item in the
+// buffer; moving past either end goes back to what was typed
+func (e *Editor) selectCompletion(step int) {
+	cp := &e.completion
+	n := len(cp.items)
+	cp.index += step
+	switch {
+	case cp.index >= n:
+		cp.index = -1
+	case cp.index < -1:
+		cp.index = n - 1
+	}
+	if cp.index == -1 {
+		e.replaceCompletion(cp.query)
+		return
+	}
+	if cp.index < cp.top {
+		cp.top = cp.index
+	} else if cp.index >= cp.top+completionMenuHeight {
+		cp.top = cp.index - completionMenuHeight + 1
+	}
+	e.replaceCompletion(cp.items[cp.index])
+}
+
+// acceptCompletion keeps the selected item and closes the menu; a title
+// also gets the closing brackets of its link
+func (e *Editor) acceptCompletion() {
+	if e.completion.kind == completeTitles && e.completion.index >= 0 {
+		row := e.completion.row
+		end := e.completion.start + len(e.completion.inserted)
+		if row < len(e.ss) && end <= len(e.ss[row]) && !strings.HasPrefix(e.ss[row][end:], "]]") {
+			e.replaceCompletion(e.completion.inserted + "]]")
+		}
+	}
+	e.closeCompletion()
+}
+
+// replaceCompletion changes the text between the start of the completion
+// and the cursor to s. Only the part that differs is deleted and retyped,
+// so the prefix typed before Ctrl-N is never backspaced over.
+func (e *Editor) replaceCompletion(s string) {
+	cur := e.completion.inserted
+	keep := 0
+	for keep < len(cur) && keep < len(s) {
+		r1, size := utf8.DecodeRuneInString(cur[keep:])
+		r2, _ := utf8.DecodeRuneInString(s[keep:])
+		if r1 != r2 {
+			break
+		}
+		keep += size
+	}
+	for i := utf8.RuneCountInString(cur[keep:]); i > 0; i-- {
+		vim.SendKey("<bs>")
+	}
+	for _, r := range s[keep:] {
+		vim.SendInput(string(r))
+	}
+	e.completion.inserted = s
+	e.syncCompletionCursor()
+}
+
+func (e *Editor) syncCompletionCursor() {
+	e.ss = e.vbuf.Lines()
+	if len(e.ss) == 0 {
+		e.ss = []string{""}
+	}
+	pos := vim.GetCursorPosition()
+	e.fr = pos[0] - 1
+	if e.fr < 0 {
+		e.fr = 0
+	}
+	if e.fr >= len(e.ss) {
+		e.fr = len(e.ss) - 1
+	}
+	if pos[1] > len(e.ss[e.fr]) {
+		pos[1] = len(e.ss[e.fr])
+	}
+	e.fc = utf8.RuneCountInString(e.ss[e.fr][:pos[1]])
+	e.bufferTick = e.vbuf.GetLastChangedTick()
+}
+
+func (e *Editor) closeCompletion() {
+	e.completion = completion{}
+	e.redraw = true
+}
+
+// drawCompletionMenu draws the candidates as a popup under the text being
+// completed, or above it when there isn't room below
+func (e *Editor) drawCompletionMenu() {
+	cp := &e.completion
+	if !cp.active || len(cp.items) == 0 || cp.row >= len(e.ss) {
+		return
+	}
+	height := len(cp.items) - cp.top
+	if height > completionMenuHeight {
+		height = completionMenuHeight
+	}
+	width := 0
+	for _, item := range cp.items[cp.top : cp.top+height] {
+		if w := utf8.RuneCountInString(item); w > width {
+			width = w
+		}
+	}
+	textcols := e.screencols - e.left_margin_offset
+	if width > textcols/2 {
+		width = textcols / 2
+	}
+	if width < 1 {
+		return // no room for the menu
+	}
+
+	y := e.cy + 1
+	if y+height > e.screenlines && e.cy >= height {
+		y = e.cy - height
+	}
+	start := cp.start
+	if start > len(e.ss[cp.row]) {
+		start = len(e.ss[cp.row])
+	}
+	x := e.getScreenXFromRowColWW(cp.row, start)
+	if x+width+2 > textcols {
+		x = textcols - width - 2
+	}
+	if x < 0 {
+		x = 0
+	}
+
+	var ab strings.Builder
+	for i := 0; i < height && y+i < e.screenlines; i++ {
+		n := cp.top + i
+		item := []rune(cp.items[n])
+		if len(item) > width {
+			item = append(item[:width-1], '…')
+		}
+		if n == cp.index {
+			ab.WriteString(app.theme.UI.MenuSelected)
+		} else {
+			ab.WriteString(app.theme.UI.Menu)
+		}
+		fmt.Fprintf(&ab, "\x1b[%d;%dH %s%s ", y+i+e.top_margin, x+e.left_margin+e.left_margin_offset+1,
+			string(item), strings.Repeat(" ", width-len(item)))
+		ab.WriteString(RESET)
+	}
+	if cp.index >= 0 {
+		e.ShowMessage(BR, "match %d of %d", cp.index+1, len(cp.items))
+	}
+	fmt.Print(ab.String())
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Shell filtering is done here rather than by vim so it works the same with
+// libvim and govim: !{motion} and :{range}!cmd replace lines with the
+// output of cmd given them as input, :r !cmd inserts the output of cmd
+// below a line and :!cmd just shows it. A filter that fails leaves the
+// buffer unchanged; anything it writes to stderr is shown in the notice.
+
+const (
+	filterTimeout = 30 * time.Second
+	visualRange   = "'<,'>"
+)
+
+// keys that need another key to complete the motion after !
+const filterPrefixKeys = "gfFtTia'`[]z"
+
+// filterMotionKey handles the keys of !{motion}. The motion is made in
+// linewise visual mode so that text objects work too; once it is complete
+// the rows it covers become the range of a :{range}! command line.
+func (e *Editor) filterMotionKey(c int) (redraw, skip bool) {
+	if e.filterKeys == "" {
+		e.filterKeys = "!"
+		vim.SendInput("V")
+		return false, true
+	}
+
+	// !! filters the current line
+	if c != '!' || e.filterKeys != "!" {
+		// the motion typed so far, without a count
+		motion := strings.TrimLeft(e.filterKeys[1:], "0123456789")
+		e.filterKeys += string(rune(c))
+		if z, found := termcodes[c]; found {
+			vim.SendKey(z)
+		} else {
+			vim.SendInput(string(rune(c)))
+		}
+		if motion == "" && c >= '1' && c <= '9' || motion == "" && c == '0' && len(e.filterKeys) > 2 {
+			return false, true
+		}
+		if motion == "" && strings.ContainsRune(filterPrefixKeys, rune(c)) {
+			return false, true
+		}
+	}
+
+	r := vim.GetVisualRange()
+	start, end := r[0][0]-1, r[1][0]-1
+	if start > end {
+		start, end = end, start
+	}
+	e.startFilterCommand(start, end)
+	return true, true
+}
+
+// startFilterCommand leaves the cursor on start and opens the command line
+// with the range start to end ready for a filter command
+func (e *Editor) startFilterCommand(start, end int) {
+	e.filterKeys = ""
+	vim.SendKey("<esc>")
+	e.ss = e.vbuf.Lines()
+	if start < 0 {
+		start = 0
+	}
+	if end >= len(e.ss) {
+		end = len(e.ss) - 1
+	}
+	vim.SetCursorPosition(start+1, 0)
+	e.fr, e.fc = start, 0
+
+	e.command = ""
+	e.command_line = "."
+	if end > start {
+		e.command_line = fmt.Sprintf(".,.+%d", end-start)
+	}
+	e.command_line += "!"
+	e.mode = EX_COMMAND
+	e.tabCompletion.index = 0
+	e.tabCompletion.list = nil
+	e.ShowMessage(BR, ":%s", e.command_line)
+}
+
+// visualCommandLine opens the command line from visual mode with the
+// selected rows as the range, like vim does
+func (e *Editor) visualCommandLine() {
+	r := vim.GetVisualRange()
+	start, end := r[0][0]-1, r[1][0]-1
+	if start > end {
+		start, end = end, start
+	}
+	e.visualRows = [2]int{start, end}
+	vim.SendKey("<esc>")
+	e.command = ""
+	e.command_line = visualRange
+	e.mode = EX_COMMAND
+	e.tabCompletion.index = 0
+	e.tabCompletion.list = nil
+	e.ShowMessage(BR, ":%s", e.command_line)
+}
+
+var (
+	lineAddress = regexp.MustCompile(`^(\d+|\.|\$|'<|'>)?([+-]\d*)*`)
+	lineOffset  = regexp.MustCompile(`[+-]\d*`)
+	readCommand = regexp.MustCompile(`^(r|read)\s*!`)
+)
+
+// parseAddress returns the row of the line address at the start of s and
+// the rest of s; ok is false if there is no address
+func (e *Editor) parseAddress(s string) (row int, rest string, ok bool) {
+	m := lineAddress.FindString(s)
+	if m == "" {
+		return 0, s, false
+	}
+	rest = s[len(m):]
+	row = e.fr
+	base := m
+	if i := strings.IndexAny(m, "+-"); i != -1 {
+		base = m[:i]
+	}
+	switch base {
+	case "", ".":
+	case "$":
+		row = len(e.ss) - 1
+	case "'<":
+		row = e.visualRows[0]
+	case "'>":
+		row = e.visualRows[1]
+	default:
+		n, _ := strconv.Atoi(base)
+		row = n - 1
+	}
+	for _, off := range lineOffset.FindAllString(m[len(base):], -1) {
+		n := 1
+		if len(off) > 1 {
+			n, _ = strconv.Atoi(off[1:])
+		}
+		if off[0] == '-' {
+			n = -n
+		}
+		row += n
+	}
+	return row, rest, true
+}
+
+// parseRange returns the rows of the range at the start of s ("%", one
+// address or two separated by a comma) and the rest of s
+func (e *Editor) parseRange(s string) (start, end int, rest string, ok bool) {
+	if strings.HasPrefix(s, "%") {
+		return 0, len(e.ss) - 1, s[1:], true
+	}
+	start, rest, ok = e.parseAddress(s)
+	if !ok {
+		return 0, 0, s, false
+	}
+	end = start
+	if strings.HasPrefix(rest, ",") {
+		if end, rest, ok = e.parseAddress(rest[1:]); !ok {
+			return 0, 0, s, false
+		}
+	}
+	if start > end {
+		start, end = end, start
+	}
+	return start, end, rest, true
+}
+
+// shellExCommand handles :{range}!cmd, :!cmd and :r !cmd, reporting
+// whether the command line was one of them
+func (e *Editor) shellExCommand() bool {
+	start, end, rest, hasRange := e.parseRange(e.command_line)
+	switch {
+	case strings.HasPrefix(rest, "!"):
+		cmdline := strings.TrimSpace(rest[1:])
+		if cmdline == "" {
+			e.ShowMessage(BR, "You need to provide a command")
+			return true
+		}
+		if hasRange {
+			e.filterLines(start, end, cmdline)
+		} else {
+			e.showCommandOutput(cmdline)
+		}
+		return true
+	case readCommand.MatchString(rest):
+		cmdline := strings.TrimSpace(rest[strings.Index(rest, "!")+1:])
+		if cmdline == "" {
+			e.ShowMessage(BR, "You need to provide a command")
+			return true
+		}
+		if !hasRange {
+			end = e.fr
+		}
+		e.readCommandOutput(end, cmdline)
+		return true
+	}
+	return false
+}
+
+// filterLines replaces rows start to end with the output of cmdline given
+// them as input
+func (e *Editor) filterLines(start, end int, cmdline string) {
+	if start < 0 || end >= len(e.ss) {
+		e.ShowMessage(BR, "Invalid range")
+		return
+	}
+	input := strings.Join(e.ss[start:end+1], "\n") + "\n"
+	out, ok := e.shellOutput(cmdline, input)
+	if !ok {
+		return
+	}
+	e.replaceLines(start, end+1, out)
+	if n := end - start + 1; len(out) == n {
+		e.ShowMessage(BR, "%d lines filtered", n)
+	} else {
+		e.ShowMessage(BR, "%d lines filtered into %d", n, len(out))
+	}
+}
+
+// readCommandOutput inserts the output of cmdline below row (above the
+// first row if row is -1)
+func (e *Editor) readCommandOutput(row int, cmdline string) {
+	if row < -1 || row >= len(e.ss) {
+		e.ShowMessage(BR, "Invalid range")
+		return
+	}
+	out, ok := e.shellOutput(cmdline, "")
+	if !ok || len(out) == 0 {
+		return
+	}
+	e.replaceLines(row+1, row+1, out)
+	e.ShowMessage(BR, "%d lines read from %q", len(out), cmdline)
+}
+
+// showCommandOutput shows the output of cmdline in the notice
+func (e *Editor) showCommandOutput(cmdline string) {
+	out, ok := e.shellOutput(cmdline, "")
+	if !ok {
+		return
+	}
+	if len(out) == 0 {
+		e.ShowMessage(BR, "%q produced no output", cmdline)
+		return
+	}
+	app.Screen.altRowoff = 0
+	app.Screen.drawNotice(strings.Join(out, "\n"), false, TL)
+}
+
+// shellOutput runs cmdline with input on stdin and returns the lines it
+// wrote to stdout; ok is false, with stderr shown, if it failed
+func (e *Editor) shellOutput(cmdline, input string) (lines []string, ok bool) {
+	cmd := shellCommand(cmdline)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		e.ShowMessage(BR, "Error starting %q: %v", cmdline, err)
+		return nil, false
+	}
+	timer := time.AfterFunc(filterTimeout, func() { killProcess(cmd) })
+	err := cmd.Wait()
+	timedOut := !timer.Stop()
+
+	msg := strings.TrimRight(stderr.String(), "\n")
+	if msg != "" {
+		app.Screen.altRowoff = 0
+		app.Screen.drawNotice(msg, false, TL)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case timedOut:
+		e.ShowMessage(BR, "%q timed out after %s; the note is unchanged", cmdline, filterTimeout)
+		return nil, false
+	case errors.As(err, &exitErr):
+		e.ShowMessage(BR, "%q exited with status %d; the note is unchanged", cmdline, exitErr.ExitCode())
+		return nil, false
+	case err != nil:
+		e.ShowMessage(BR, "Error running %q: %v", cmdline, err)
+		return nil, false
+	}
+	if stdout.Len() == 0 {
+		return nil, true
+	}
+	return strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n"), true
+}
+
+// replaceLines replaces rows start up to end with lines and leaves the
+// cursor on the first of them
+func (e *Editor) replaceLines(start, end int, lines []string) {
+	ss := make([]string, 0, len(e.ss)-(end-start)+len(lines))
+	ss = append(ss, e.ss[:start]...)
+	ss = append(ss, lines...)
+	ss = append(ss, e.ss[end:]...)
+	if len(ss) == 0 {
+		ss = []string{""}
+	}
+	e.vbuf.SetLines(0, -1, ss)
+	e.ss = e.vbuf.Lines()
+
+	row := start
+	if row >= len(e.ss) {
+		row = len(e.ss) - 1
+	}
+	vim.SetCursorPosition(row+1, 0)
+	e.fr, e.fc = row, 0
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Folding is done by the editor rather than vim: zc, zo, za, zM and zR
+// close and open markdown sections, fenced code blocks and list items with
+// nested items. A closed fold is drawn as one summary line and the rows it
+// hides take no screen lines, so the cursor steps over them. Editing on a
+// fold's summary line in insert mode opens the fold.
+
+var listItem = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s`)
+
+// foldRegions returns the rows that can be folded as start -> end
+func foldRegions(rows []string) map[int]int {
+	regions := make(map[int]int)
+	headings := markdownHeadings(rows)
+	for i, h := range headings {
+		if end := sectionEnd(headings, i, len(rows)); end > h.row {
+			regions[h.row] = end
+		}
+	}
+	inFence := make([]bool, len(rows))
+	for _, b := range fencedBlocks(rows) {
+		regions[b.start] = b.end
+		for r := b.start; r <= b.end; r++ {
+			inFence[r] = true
+		}
+	}
+	for i, row := range rows {
+		m := listItem.FindStringSubmatch(row)
+		if m == nil || inFence[i] {
+			continue
+		}
+		// an item's nested rows are the ones indented past its marker
+		last := i
+		for j := i + 1; j < len(rows); j++ {
+			if strings.TrimSpace(rows[j]) == "" {
+				continue
+			}
+			if indentation(rows[j]) <= len(m[1]) {
+				break
+			}
+			last = j
+		}
+		if last > i {
+			regions[i] = last
+		}
+	}
+	return regions
+}
+
+func indentation(row string) int {
+	return len(row) - len(strings.TrimLeft(strings.ReplaceAll(row, "\t", "    "), " "))
+}
+
+// hiddenRow reports whether row r is inside a closed fold
+func (e *Editor) hiddenRow(r int) bool {
+	for start, end := range e.folds {
+		if r > start && r <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// foldedRow reports whether row r is drawn as a fold's summary line or
+// hidden by a fold
+func (e *Editor) foldedRow(r int) bool {
+	if len(e.folds) == 0 {
+		return false
+	}
+	_, closed := e.folds[r]
+	return closed || e.hiddenRow(r)
+}
+
+// foldText is the summary line drawn for the closed fold starting at row
+func (e *Editor) foldText(row int) string {
+	text := strings.TrimRight(strings.ReplaceAll(e.ss[row], "\t", "    "), " ")
+	text = fmt.Sprintf("%s ··· %d lines", text, e.folds[row]-row)
+	if width := e.screencols - e.left_margin_offset; utf8.RuneCountInString(text) > width {
+		text = string([]rune(text)[:width])
+	}
+	return app.theme.UI.Fold + text + RESET
+}
+
+// syncFolds keeps closed folds on the rows they were made for as lines
+// are added or deleted above them and drops the ones that no longer start
+// a foldable region
+func (e *Editor) syncFolds() {
+	if len(e.folds) == 0 {
+		return
+	}
+	folds := e.folds
+	if delta := len(e.ss) - e.foldRows; delta != 0 {
+		folds = make(map[int]int)
+		for start, end := range e.folds {
+			if start > e.fr || delta > 0 && start == e.fr {
+				start, end = start+delta, end+delta
+			}
+			folds[start] = end
+		}
+	}
+	regions := foldRegions(e.ss)
+	e.folds = make(map[int]int)
+	for start := range folds {
+		if end, ok := regions[start]; ok {
+			e.folds[start] = end
+		}
+	}
+	e.foldRows = len(e.ss)
+}
+
+// closeFold closes the innermost open fold around the cursor (zc)
+func (e *Editor) closeFold(_ int) {
+	e.syncFolds()
+	start, end := -1, -1
+	for s, en := range foldRegions(e.ss) {
+		if _, closed := e.folds[s]; closed || s > e.fr || en < e.fr {
+			continue
+		}
+		if s > start {
+			start, end = s, en
+		}
+	}
+	if start == -1 {
+		e.ShowMessage(BR, "No fold found")
+		return
+	}
+	if e.folds == nil {
+		e.folds = make(map[int]int)
+	}
+	e.folds[start] = end
+	e.foldRows = len(e.ss)
+	vim.SetCursorPosition(start+1, 0)
+}
+
+// openFold opens the closed fold at the cursor (zo)
+func (e *Editor) openFold(_ int) {
+	e.syncFolds()
+	if _, closed := e.folds[e.fr]; !closed {
+		e.ShowMessage(BR, "No closed fold at the cursor")
+		return
+	}
+	delete(e.folds, e.fr)
+}
+
+// toggleFold opens the closed fold at the cursor or closes the one
+// around it (za)
+func (e *Editor) toggleFold(c int) {
+	e.syncFolds()
+	if _, closed := e.folds[e.fr]; closed {
+		e.openFold(c)
+	} else {
+		e.closeFold(c)
+	}
+}
+
+// closeAllFolds closes every fold in the note (zM)
+func (e *Editor) closeAllFolds(_ int) {
+	e.folds = foldRegions(e.ss)
+	e.foldRows = len(e.ss)
+	if len(e.folds) == 0 {
+		e.ShowMessage(BR, "The note has nothing to fold")
+		return
+	}
+	// leave the cursor on the summary line of the fold it ended up in
+	row := e.fr
+	for start, end := range e.folds {
+		if row > start && row <= end && !e.hiddenRow(start) {
+			row = start
+		}
+	}
+	vim.SetCursorPosition(row+1, 0)
+}
+
+// openAllFolds opens every fold in the note (zR)
+func (e *Editor) openAllFolds(_ int) {
+	e.folds = nil
+}
+
+// skipFolds moves the cursor off rows hidden by closed folds after it was
+// moved from prevRow: down past a fold from its summary line, up onto the
+// summary line from below and otherwise, as after a search, the fold is
+// opened. It reports whether the cursor or the folds changed.
+func (e *Editor) skipFolds(prevRow int) bool {
+	if len(e.folds) == 0 {
+		return false
+	}
+	e.syncFolds()
+	changed := false
+	if _, closed := e.folds[e.fr]; closed && e.mode == INSERT {
+		delete(e.folds, e.fr)
+		changed = true
+	}
+	for {
+		start, end := -1, -1
+		for s, en := range e.folds {
+			if e.fr > s && e.fr <= en && (start == -1 || s < start) {
+				start, end = s, en
+			}
+		}
+		if start == -1 {
+			return changed
+		}
+		changed = true
+		row := start
+		switch {
+		case e.mode == INSERT || prevRow > start && prevRow <= end:
+			delete(e.folds, start)
+			continue
+		case prevRow == start && end+1 < len(e.ss):
+			row = end + 1
+		case prevRow == start, prevRow > end:
+		default:
+			delete(e.folds, start)
+			continue
+		}
+		vim.SetCursorPosition(row+1, 0)
+		e.fr, e.fc = row, 0
+	}
+}
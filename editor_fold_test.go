@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldRegions(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		want map[int]int
+	}{
+		{
+			name: "nested headings and a code block",
+			rows: outlineNote,
+			want: map[int]int{0: 10, 2: 7, 4: 6, 8: 10},
+		},
+		{
+			name: "heading sections end at the next heading of the same level",
+			rows: []string{"# A", "## B", "b", "## C", "c", "# D", "d"},
+			want: map[int]int{0: 4, 1: 2, 3: 4, 5: 6},
+		},
+		{
+			name: "nested list items",
+			rows: []string{"- a", "  - b", "", "  - c", "- d", "1. e", "   more"},
+			want: map[int]int{0: 3, 5: 6},
+		},
+		{
+			name: "lists and headings in a code block",
+			rows: []string{"```go", "- x", "  - y", "# z", "```", "after"},
+			want: map[int]int{0: 4},
+		},
+		{
+			name: "nothing to fold",
+			rows: []string{"one", "two", "# last"},
+			want: map[int]int{},
+		},
+	}
+	for _, tt := range tests {
+		if got := foldRegions(tt.rows); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: foldRegions = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/slzatz/vimango/lsp"
+	"github.com/slzatz/vimango/vim"
+)
+
+// Language servers for notes in the code folder. One server runs per
+// language for the whole session and the note is synced to it as the file
+// it is written to by updateCodeFile. The server is started in the
+// background; everything else happens on the main loop, which hears about
+// new diagnostics through an app notification.
+
+// lspNotification asks the main loop to sync and redraw the active editor
+const lspNotification = "_LSP_UPDATE_"
+
+// lspServer is a running (or failed) language server
+type lspServer struct {
+	client *lsp.Client
+	err    error
+}
+
+// startLsp starts the language server for a code note if one isn't
+// already running
+func (e *Editor) startLsp() {
+	if e.Database.taskFolder(e.id) != "code" {
+		return
+	}
+	lang := Languages[e.Database.taskContext(e.id)]
+	command, ok := Lsps[lang]
+	if !ok {
+		return
+	}
+	path, err := codeFile(lang)
+	if err != nil {
+		return
+	}
+	e.lspLang = lang
+	e.lspPath = path
+
+	sess := e.Session
+	sess.lspMux.Lock()
+	defer sess.lspMux.Unlock()
+	if sess.lsps == nil {
+		sess.lsps = make(map[string]*lspServer)
+	}
+	if _, ok := sess.lsps[lang]; ok {
+		return
+	}
+	server := &lspServer{}
+	sess.lsps[lang] = server
+
+	go func() {
+		client, err := lsp.Start(command, nil, filepath.Dir(path), func(string) {
+			app.addNotification(lspNotification)
+		})
+		sess.lspMux.Lock()
+		server.client, server.err = client, err
+		sess.lspMux.Unlock()
+		app.addNotification(lspNotification)
+	}()
+}
+
+// lspClient returns the editor's language server client or nil if the
+// note has none or it isn't running yet
+func (e *Editor) lspClient() *lsp.Client {
+	if e.lspLang == "" {
+		return nil
+	}
+	e.Session.lspMux.Lock()
+	defer e.Session.lspMux.Unlock()
+	server, ok := e.Session.lsps[e.lspLang]
+	if !ok || server.client == nil {
+		return nil
+	}
+	select {
+	case <-server.client.Done():
+		return nil
+	default:
+	}
+	return server.client
+}
+
+// lspReady returns the client for commands the user invoked, explaining
+// why there isn't one
+func (e *Editor) lspReady() *lsp.Client {
+	if e.lspLang == "" {
+		e.ShowMessage(BR, "Language server features are only available for notes in the code folder")
+		return nil
+	}
+	c := e.lspClient()
+	if c == nil {
+		e.Session.lspMux.Lock()
+		server := e.Session.lsps[e.lspLang]
+		e.Session.lspMux.Unlock()
+		if server != nil && server.err != nil {
+			e.ShowMessage(BR, "%s language server failed to start: %v", e.lspLang, server.err)
+		} else {
+			e.ShowMessage(BR, "%s language server isn't running", e.lspLang)
+		}
+		return nil
+	}
+	e.lspSync()
+	return c
+}
+
+// lspSync sends the note to the server if it has changed since it was last
+// sent or another note of the same language was sent after it
+func (e *Editor) lspSync() {
+	c := e.lspClient()
+	if c == nil {
+		return
+	}
+	tick := e.vbuf.GetLastChangedTick()
+	if !c.IsOpen(e.lspPath) {
+		if err := c.DidOpen(e.lspPath, e.lspLang, e.bufferToString()); err == nil {
+			e.lspVersion = c.Version(e.lspPath)
+			e.lspTick = tick
+		}
+		return
+	}
+	if e.lspVersion == c.Version(e.lspPath) && e.lspTick == tick {
+		return
+	}
+	if version, err := c.DidChange(e.lspPath, e.bufferToString()); err == nil {
+		e.lspVersion = version
+		e.lspTick = tick
+	}
+}
+
+// lspClose closes the note's document when its editor is closed
+func (e *Editor) lspClose() {
+	c := e.lspClient()
+	if c == nil || e.lspVersion != c.Version(e.lspPath) {
+		return
+	}
+	c.DidClose(e.lspPath)
+}
+
+// stopLsps shuts down every language server when the app exits
+func (s *Session) stopLsps() {
+	s.lspMux.Lock()
+	defer s.lspMux.Unlock()
+	for _, server := range s.lsps {
+		if server.client != nil {
+			server.client.Shutdown()
+		}
+	}
+	s.lsps = nil
+}
+
+// lspPosition returns the cursor position as the server counts it
+func (e *Editor) lspPosition() (lsp.Position, bool) {
+	pos := vim.GetCursorPosition()
+	row := pos[0] - 1
+	if row < 0 || row >= len(e.ss) {
+		return lsp.Position{}, false
+	}
+	return lsp.Position{Line: row, Character: lsp.UTF16Col(e.ss[row], pos[1])}, true
+}
+
+// lspHover shows the server's documentation for the symbol under the cursor (K)
+func (e *Editor) lspHover(_ int) {
+	c := e.lspReady()
+	if c == nil {
+		return
+	}
+	pos, ok := e.lspPosition()
+	if !ok {
+		return
+	}
+	text, err := c.Hover(e.lspPath, pos)
+	if err != nil {
+		e.ShowMessage(BR, "Hover failed: %v", err)
+		return
+	}
+	if strings.TrimSpace(text) == "" {
+		e.ShowMessage(BR, "No information for the word under the cursor")
+		return
+	}
+	app.Screen.altRowoff = 0
+	app.Screen.drawNotice(text, true, TL)
+}
+
+// lspDefinition moves the cursor to the definition of the symbol under it
+// (Ctrl-]); definitions outside the note are reported, not opened
+func (e *Editor) lspDefinition(_ int) {
+	c := e.lspReady()
+	if c == nil {
+		return
+	}
+	pos, ok := e.lspPosition()
+	if !ok {
+		return
+	}
+	locs, err := c.Definition(e.lspPath, pos)
+	if err != nil {
+		e.ShowMessage(BR, "Definition failed: %v", err)
+		return
+	}
+	if len(locs) == 0 {
+		e.ShowMessage(BR, "No definition found")
+		return
+	}
+	loc := locs[0]
+	start := loc.Range.Start
+	if loc.URI != lsp.PathToURI(e.lspPath) {
+		e.ShowMessage(BR, "Defined in %s:%d", lsp.URIToPath(loc.URI), start.Line+1)
+		return
+	}
+	if start.Line >= len(e.ss) {
+		return
+	}
+	vim.SetCursorPosition(start.Line+1, lsp.ByteCol(e.ss[start.Line], start.Character))
+}
+
+// lspCompletions returns the server's completions that extend prefix
+func (e *Editor) lspCompletions(prefix string) []string {
+	c := e.lspClient()
+	if c == nil {
+		return nil
+	}
+	e.lspSync()
+	pos, ok := e.lspPosition()
+	if !ok {
+		return nil
+	}
+	items, err := c.Completion(e.lspPath, pos)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	var words []string
+	for _, item := range items {
+		w := item.Text()
+		if _, ok := seen[w]; ok || len(w) <= len(prefix) || !strings.HasPrefix(w, prefix) {
+			continue
+		}
+		seen[w] = struct{}{}
+		words = append(words, w)
+		if len(words) == completionMaxItems {
+			break
+		}
+	}
+	return words
+}
+
+// drawDiagnostics marks rows with diagnostics in the line number gutter and
+// shows the most severe message after the end of the row
+func (e *Editor) drawDiagnostics(pab *strings.Builder) {
+	c := e.lspClient()
+	if c == nil || e.lspVersion != c.Version(e.lspPath) {
+		return
+	}
+	diags := c.Diagnostics(e.lspPath)
+	if len(diags) == 0 {
+		return
+	}
+
+	worst := make(map[int]lsp.Diagnostic)
+	for _, d := range diags {
+		r := d.Range.Start.Line
+		if w, ok := worst[r]; !ok || severity(d) < severity(w) {
+			worst[r] = d
+		}
+	}
+
+	textcols := e.screencols - e.left_margin_offset
+	for r, d := range worst {
+		if r < e.firstVisibleRow || r >= len(e.ss) || e.foldedRow(r) {
+			continue
+		}
+		y := e.getScreenYFromRowColWW(r, 0) - e.lineOffset
+		if y < 0 || y >= e.screenlines {
+			continue
+		}
+		color, mark := diagnosticStyle(severity(d))
+		if e.left_margin_offset > 0 {
+			fmt.Fprintf(pab, "\x1b[%d;%dH%s%s%s%s", y+e.top_margin, e.left_margin+e.left_margin_offset, app.theme.UI.LineNumbers, color, mark, RESET)
+		}
+
+		lastY := y + e.getLinesInRowWW(r) - 1
+		x := e.getScreenXFromRowColWW(r, len(e.ss[r])) + 2
+		room := textcols - x
+		if lastY >= e.screenlines || room < 8 {
+			continue
+		}
+		msg := d.Message
+		if i := strings.IndexByte(msg, '\n'); i != -1 {
+			msg = msg[:i]
+		}
+		if runes := []rune(msg); len(runes) > room {
+			msg = string(runes[:room-1]) + "…"
+		}
+		fmt.Fprintf(pab, "\x1b[%d;%dH%s%s%s", lastY+e.top_margin, x+e.left_margin+e.left_margin_offset+1, color, msg, RESET)
+	}
+}
+
+// severity treats a diagnostic without one as an error, as the spec suggests
+func severity(d lsp.Diagnostic) int {
+	if d.Severity == 0 {
+		return lsp.SeverityError
+	}
+	return d.Severity
+}
+
+func diagnosticStyle(severity int) (color, mark string) {
+	ui := app.theme.UI
+	switch severity {
+	case lsp.SeverityError:
+		return ui.DiagError, "E"
+	case lsp.SeverityWarning:
+		return ui.DiagWarning, "W"
+	case lsp.SeverityInformation:
+		return ui.DiagInfo, "I"
+	}
+	return ui.DiagHint, "H"
+}
@@ -41,7 +41,7 @@ func (e *Editor) drawHighlightedBraces() {
 	}
 
 	pos := vim.GetMatchingPair()
-	if pos == [2]int{0, 0} {
+	if pos == [2]int{0, 0} || e.foldedRow(pos[0]-1) || e.foldedRow(e.fr) {
 		return
 	}
 
@@ -53,11 +53,11 @@ func (e *Editor) drawHighlightedBraces() {
 	match := e.ss[pos[0]-1][pos[1]]
 
 	x := e.getScreenXFromRowColWW(pos[0]-1, pos[1]) + e.left_margin + e.left_margin_offset + 1
-	fmt.Printf("\x1b[%d;%dH\x1b[48;5;237m%s", y+e.top_margin, x, string(match))
+	fmt.Printf("\x1b[%d;%dH%s%s", y+e.top_margin, x, app.theme.UI.VisualSelection, string(match))
 
 	x = e.getScreenXFromRowColWW(e.fr, e.fc-back) + e.left_margin + e.left_margin_offset + 1
 	y = e.getScreenYFromRowColWW(e.fr, e.fc-back) + e.top_margin - e.lineOffset // added line offset 12-25-2019
-	fmt.Printf("\x1b[%d;%dH\x1b[48;5;237m%s\x1b[0m", y, x, string(b))
+	fmt.Printf("\x1b[%d;%dH%s%s\x1b[0m", y, x, app.theme.UI.VisualSelection, string(b))
 	return
 }
 
@@ -84,6 +84,9 @@ func (e *Editor) bufferToString() string {
 }
 
 func (e *Editor) getScreenXFromRowColWW(r, c int) int {
+	if e.foldedRow(r) {
+		return 0
+	}
 	row := e.ss[r]
 	row = strings.ReplaceAll(row, "\t", "$$$$")
 	tabCount := strings.Count(e.ss[r][:c], "\t")
@@ -124,6 +127,12 @@ func (e *Editor) getScreenYFromRowColWW(r, c int) int {
 }
 
 func (e *Editor) getLinesInRowWW(r int) int {
+	if e.foldedRow(r) {
+		if e.hiddenRow(r) {
+			return 0
+		}
+		return 1
+	}
 	row := e.ss[r]
 	row = strings.ReplaceAll(row, "\t", "$$$$")
 	width := e.screencols - e.left_margin_offset
@@ -152,6 +161,9 @@ func (e *Editor) getLinesInRowWW(r int) int {
 }
 
 func (e *Editor) getLineInRowWW(r, c int) int {
+	if e.foldedRow(r) {
+		return 1
+	}
 	row := e.ss[r]
 	tabCount := strings.Count(row[:c], "\t")
 	if tabCount > 0 {
@@ -187,6 +199,7 @@ func (e *Editor) getLineInRowWW(r, c int) int {
 
 func (e *Editor) drawText() {
 	var ab strings.Builder
+	e.syncFolds()
 
 	fmt.Fprintf(&ab, "\x1b[?25l\x1b[%d;%dH", e.top_margin, e.left_margin+1)
 	// \x1b[NC moves cursor forward by n columns
@@ -205,11 +218,14 @@ func (e *Editor) drawText() {
 	if e.highlightPositions != nil {
 		e.drawHighlights(&ab)
 	}
+	e.drawSearchMatches(&ab)
+	e.drawDiagnostics(&ab)
 	if e.mode == VISUAL {
 		e.drawVisual(&ab)
 	}
 	fmt.Print(ab.String())
 	e.drawHighlightedBraces() //has to come after drawing rows
+	e.drawCompletionMenu()
 }
 
 func (e *Editor) drawVisual(pab *strings.Builder) {
@@ -224,9 +240,9 @@ func (e *Editor) drawVisual(pab *strings.Builder) {
 		y := e.getScreenYFromRowColWW(startRow, 0) - e.lineOffset
 
 		if y >= 0 {
-			fmt.Fprintf(pab, "\x1b[%d;%dH\x1b[48;5;237m", y+e.top_margin, x) //244
+			fmt.Fprintf(pab, "\x1b[%d;%dH%s", y+e.top_margin, x, app.theme.UI.VisualSelection) //244
 		} else {
-			fmt.Fprintf(pab, "\x1b[%d;%dH\x1b[48;5;237m", e.top_margin, x)
+			fmt.Fprintf(pab, "\x1b[%d;%dH%s", e.top_margin, x, app.theme.UI.VisualSelection)
 		}
 
 		for n := 0; n < (endRow - startRow + 1); n++ { //++n
@@ -262,7 +278,7 @@ func (e *Editor) drawVisual(pab *strings.Builder) {
 		x := e.getScreenXFromRowColWW(startRow, startCol) + e.left_margin + e.left_margin_offset + 1
 		y := e.getScreenYFromRowColWW(startRow, startCol) + e.top_margin - e.lineOffset // - 1
 
-		pab.WriteString("\x1b[48;5;237m")
+		pab.WriteString(app.theme.UI.VisualSelection)
 		for n := 0; n < numrows; n++ {
 			// i think would check here to see if a row has multiple lines (ie wraps)
 			if n == 0 {
@@ -300,36 +316,61 @@ func (e *Editor) drawVisual(pab *strings.Builder) {
 	}
 
 	if e.vmode == VISUAL_BLOCK {
-
-		var left, right int
-		if e.highlight[1][1] > e.highlight[0][1] {
-			right, left = e.highlight[1][1], e.highlight[0][1]
-		} else {
-			left, right = e.highlight[1][1], e.highlight[0][1]
+		left, right := e.highlight[0][1], e.highlight[1][1]
+		if left > right {
+			left, right = right, left
+		}
+		startRow, endRow := e.highlight[0][0]-1, e.highlight[1][0]-1
+		if startRow > endRow {
+			startRow, endRow = endRow, startRow
+		}
+		// after $ the block extends to the end of every line
+		toEnd := vim.EvaluateExpression("winsaveview().curswant") == vimMaxCol
+
+		pab.WriteString(app.theme.UI.VisualSelection)
+		for r := startRow; r <= endRow && r < len(e.ss); r++ {
+			end := len(e.ss[r])
+			if !toEnd && right+1 < end {
+				end = right + 1
+			}
+			if left < end {
+				e.drawHighlightedSpan(pab, r, left, end)
+			}
 		}
-		x := e.getScreenXFromRowColWW(e.highlight[0][0]-1, left) + e.left_margin + e.left_margin_offset + 1 //-1
-		y := e.getScreenYFromRowColWW(e.highlight[0][0]-1, left) + e.top_margin - e.lineOffset
-		//sess.showOrgMessage("highlight = %v, right = %v, left = %v, x = %v, y = %v", e.highlight, right, left, x, y)
+	}
 
-		pab.WriteString("\x1b[48;5;237m")
-		for n := 0; n < (e.highlight[1][0] - e.highlight[0][0] + 1); n++ {
-			fmt.Fprintf(pab, "\x1b[%d;%dH", y+n, x)
-			row := e.ss[e.highlight[0][0]+n-1]
-			rowLen := len(row)
+	pab.WriteString(RESET)
+}
 
-			if rowLen == 0 || rowLen < left {
-				continue
+// drawHighlightedSpan writes row r's bytes [start, end) in place; a span
+// can wrap so each screen line it touches is positioned separately
+func (e *Editor) drawHighlightedSpan(pab *strings.Builder, r, start, end int) {
+	if e.foldedRow(r) {
+		return
+	}
+	row := e.ss[r]
+	base := e.getScreenYFromRowColWW(r, 0) - e.lineOffset
+	prevY := -1
+	for c := start; c < end; {
+		ch, size := utf8.DecodeRuneInString(row[c:])
+		y := base + e.getLineInRowWW(r, c) - 1
+		if y >= e.screenlines {
+			return
+		}
+		if y >= 0 {
+			if y != prevY {
+				x := e.getScreenXFromRowColWW(r, c) + e.left_margin + e.left_margin_offset + 1
+				fmt.Fprintf(pab, "\x1b[%d;%dH", y+e.top_margin, x)
+				prevY = y
 			}
-
-			if rowLen < right+1 {
-				pab.WriteString(row[left:rowLen])
+			if ch == '\t' {
+				pab.WriteString("    ")
 			} else {
-				pab.WriteString(row[left : right+1])
+				pab.WriteRune(ch)
 			}
 		}
+		c += size
 	}
-
-	pab.WriteString(RESET)
 }
 
 func (e *Editor) getLineCharCountWW(r, line int) int {
@@ -384,17 +425,26 @@ func (e *Editor) drawPlainRows(pab *strings.Builder) {
 		var numCols strings.Builder
 		// below draws the line number 'rectangle'
 		// can be drawm to pab or &numCols
-		fmt.Fprintf(&numCols, "\x1b[2*x\x1b[%d;%d;%d;%d;48;5;235$r\x1b[*x",
+		fmt.Fprintf(&numCols, "\x1b[2*x\x1b[%d;%d;%d;%d;%s$r\x1b[*x",
 			e.top_margin,
 			e.left_margin,
 			e.top_margin+e.screenlines,
-			e.left_margin+e.left_margin_offset)
+			e.left_margin+e.left_margin_offset,
+			sgrParams(app.theme.UI.LineNumbers))
 		fmt.Fprintf(&numCols, "\x1b[?25l\x1b[%d;%dH", e.top_margin, e.left_margin+1)
 
 		s = fmt.Sprintf("\x1b[%dC", e.left_margin_offset) + "%s" + lf_ret
 		for n := e.firstVisibleRow; n < len(nnote); n++ {
+			if e.foldedRow(n) {
+				if !e.hiddenRow(n) {
+					fmt.Fprintf(&numCols, "%s%3d \x1b[0m", app.theme.UI.LineNumbers, n+1)
+					fmt.Fprintf(pab, s, e.foldText(n))
+					numCols.WriteString(lf_ret)
+				}
+				continue
+			}
 			row := nnote[n]
-			fmt.Fprintf(&numCols, "\x1b[48;5;235m\x1b[38;5;245m%3d \x1b[49m", n+1)
+			fmt.Fprintf(&numCols, "%s%3d \x1b[0m", app.theme.UI.LineNumbers, n+1)
 			line := strings.Split(row, "\t")
 			for i := 0; i < len(line); i++ {
 				fmt.Fprintf(pab, s, line[i])
@@ -405,6 +455,12 @@ func (e *Editor) drawPlainRows(pab *strings.Builder) {
 	} else {
 		s = "%s" + lf_ret
 		for n := e.firstVisibleRow; n < len(nnote); n++ {
+			if e.foldedRow(n) {
+				if !e.hiddenRow(n) {
+					fmt.Fprintf(pab, s, e.foldText(n))
+				}
+				continue
+			}
 			row := nnote[n]
 			line := strings.Split(row, "\t")
 			for i := 0; i < len(line); i++ {
@@ -444,17 +500,26 @@ func (e *Editor) drawCodeRows(pab *strings.Builder) {
 		var numCols strings.Builder
 		// below draws the line number 'rectangle'
 		// cam be drawm to pab or &numCols
-		fmt.Fprintf(&numCols, "\x1b[2*x\x1b[%d;%d;%d;%d;48;5;235$r\x1b[*x",
+		fmt.Fprintf(&numCols, "\x1b[2*x\x1b[%d;%d;%d;%d;%s$r\x1b[*x",
 			e.top_margin,
 			e.left_margin,
 			e.top_margin+e.screenlines,
-			e.left_margin+e.left_margin_offset)
+			e.left_margin+e.left_margin_offset,
+			sgrParams(app.theme.UI.LineNumbers))
 		fmt.Fprintf(&numCols, "\x1b[?25l\x1b[%d;%dH", e.top_margin, e.left_margin+1)
 
 		s := fmt.Sprintf("\x1b[%dC", e.left_margin_offset) + "%s" + lf_ret
 		for n := e.firstVisibleRow; n < len(nnote); n++ {
+			if e.foldedRow(n) {
+				if !e.hiddenRow(n) {
+					fmt.Fprintf(&numCols, "%s%3d \x1b[0m", app.theme.UI.LineNumbers, n+1)
+					fmt.Fprintf(pab, s, e.foldText(n))
+					numCols.WriteString(lf_ret)
+				}
+				continue
+			}
 			row := nnote[n]
-			fmt.Fprintf(&numCols, "\x1b[48;5;235m\x1b[38;5;245m%3d \x1b[49m", n+1)
+			fmt.Fprintf(&numCols, "%s%3d \x1b[0m", app.theme.UI.LineNumbers, n+1)
 			line := strings.Split(row, "\t")
 			for i := 0; i < len(line); i++ {
 				fmt.Fprintf(pab, s, line[i])
@@ -465,6 +530,12 @@ func (e *Editor) drawCodeRows(pab *strings.Builder) {
 	} else {
 		s := "%s" + lf_ret
 		for n := e.firstVisibleRow; n < len(nnote); n++ {
+			if e.foldedRow(n) {
+				if !e.hiddenRow(n) {
+					fmt.Fprintf(pab, s, e.foldText(n))
+				}
+				continue
+			}
 			row := nnote[n]
 			line := strings.Split(row, "\t")
 			for i := 0; i < len(line); i++ {
@@ -475,22 +546,14 @@ func (e *Editor) drawCodeRows(pab *strings.Builder) {
 }
 
 func (e *Editor) highlightMispelledWords() {
-	if !IsSpellCheckAvailable() {
-		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage())
+	lang, _ := e.spellLang()
+	if !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage(lang))
 		return
 	}
 
-	e.highlightPositions = nil
 	curPos := vim.GetCursorPosition()
-	for i, line := range e.ss {
-		wordPositions := GetWordPositionsSingleLine(line)
-		for _, wp := range wordPositions {
-			if CheckSpelling(wp.Word) {
-				continue
-			}
-			e.highlightPositions = append(e.highlightPositions, Position{i, wp.Start, wp.End + 1})
-		}
-	}
+	e.highlightPositions = e.misspellings(lang)
 
 	vim.SetCursorPosition(curPos[0], curPos[1]) //return cursor to where it was
 }
@@ -505,10 +568,10 @@ func (e *Editor) drawHighlights(pab *strings.Builder) {
 	}
 	for _, p := range e.highlightPositions {
 		row := e.ss[p.rowNum]
-		if p.start < 0 || p.end > len(row) {
+		if p.start < 0 || p.end > len(row) || e.foldedRow(p.rowNum) {
 			continue // skip if out of bounds
 		}
-		chars := "\x1b[48;5;31m" + string(row[p.start:p.end]) + "\x1b[0m"
+		chars := app.theme.UI.SearchHighlight + string(row[p.start:p.end]) + "\x1b[0m"
 		start := utf8.RuneCountInString(row[:p.start])
 		y := e.getScreenYFromRowColWW(p.rowNum, start) + e.top_margin - e.lineOffset          // - 1
 		x := e.getScreenXFromRowColWW(p.rowNum, start) + e.left_margin + e.left_margin_offset // - 1
@@ -622,6 +685,18 @@ func (e *Editor) generateWWStringFromBuffer() string {
 
 		row := strings.ReplaceAll(e.ss[filerow], "\t", "    ")
 
+		// a closed fold is one line; the rows it hides are kept (unwrapped)
+		// so rows still line up and highlighting sees them, but take no lines
+		if e.foldedRow(filerow) {
+			ab.WriteString(row)
+			ab.WriteString("\n")
+			if !e.hiddenRow(filerow) {
+				y++
+			}
+			filerow++
+			continue
+		}
+
 		if len(row) == 0 {
 			ab.WriteString("\n")
 			filerow++
@@ -667,7 +742,7 @@ func (e *Editor) drawStatusBar() {
 	//erase from start of an Editor's status bar to the end of the Editor's status bar
 	fmt.Fprintf(&ab, "\x1b[%dX", e.screencols)
 
-	ab.WriteString("\x1b[7m ") //switches to inverted colors
+	ab.WriteString(app.theme.UI.StatusBar + " ")
 	title := e.title
 	if len(title) > 30 {
 		title = title[:30]
@@ -693,16 +768,16 @@ func (e *Editor) drawFrame() {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", e.top_margin-1+j, e.left_margin+e.screencols+1)
 		// below x = 0x78 vertical line (q = 0x71 is horizontal) 37 = white; 1m = bold (note
 		// only need one 'm'
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 	}
 
 	//'T' corner = w or right top corner = k
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", e.top_margin-1, e.left_margin+e.screencols+1)
 
 	if e.left_margin+e.screencols > e.Screen.screenCols-4 {
-		ab.WriteString("\x1b[37;1mk") //draw corner
+		ab.WriteString(app.theme.UI.Border + "k") //draw corner
 	} else {
-		ab.WriteString("\x1b[37;1mw")
+		ab.WriteString(app.theme.UI.Border + "w")
 	}
 
 	//exit line drawing mode
@@ -29,6 +29,63 @@ func (a *App) setEditorNormalCmds(editor *Editor) map[string]func(*Editor, int)
 		Examples:    []string{"Ctrl-L - Switch to next editor"},
 	})
 
+	registry.Register("]]", (*Editor).nextHeading, CommandInfo{
+		Name:        "]]",
+		Description: "Move to the next markdown heading",
+		Usage:       "]]",
+		Category:    "Movement",
+		Examples:    []string{"]] - Go to the next heading"},
+	})
+
+	registry.Register("[[", (*Editor).previousHeading, CommandInfo{
+		Name:        "[[",
+		Description: "Move to the previous markdown heading",
+		Usage:       "[[",
+		Category:    "Movement",
+		Examples:    []string{"[[ - Go to the previous heading"},
+	})
+
+	// Folding commands
+	registry.Register("zc", (*Editor).closeFold, CommandInfo{
+		Name:        "zc",
+		Description: "Close the fold around the cursor",
+		Usage:       "zc",
+		Category:    "Folding",
+		Examples:    []string{"zc - Close the innermost section, code block or list item"},
+	})
+
+	registry.Register("zo", (*Editor).openFold, CommandInfo{
+		Name:        "zo",
+		Description: "Open the closed fold at the cursor",
+		Usage:       "zo",
+		Category:    "Folding",
+		Examples:    []string{"zo - Open the fold under the cursor"},
+	})
+
+	registry.Register("za", (*Editor).toggleFold, CommandInfo{
+		Name:        "za",
+		Description: "Open or close the fold at the cursor",
+		Usage:       "za",
+		Category:    "Folding",
+		Examples:    []string{"za - Toggle the fold under the cursor"},
+	})
+
+	registry.Register("zM", (*Editor).closeAllFolds, CommandInfo{
+		Name:        "zM",
+		Description: "Close every fold in the note",
+		Usage:       "zM",
+		Category:    "Folding",
+		Examples:    []string{"zM - Fold all sections, code blocks and lists"},
+	})
+
+	registry.Register("zR", (*Editor).openAllFolds, CommandInfo{
+		Name:        "zR",
+		Description: "Open every fold in the note",
+		Usage:       "zR",
+		Category:    "Folding",
+		Examples:    []string{"zR - Unfold everything"},
+	})
+
 	// Text Editing commands
 	registry.Register(string(ctrlKey('b')), (*Editor).decorateWord, CommandInfo{
 		Name:        keyToDisplayName(string(ctrlKey('b'))),
@@ -192,6 +249,64 @@ func (a *App) setEditorNormalCmds(editor *Editor) map[string]func(*Editor, int)
 		Examples:    []string{"<leader>su - Get spelling suggestions"},
 	})
 
+	registry.Register("zg", (*Editor).spellGood, CommandInfo{
+		Name:        "zg",
+		Description: "Add the word under the cursor to the personal word list",
+		Usage:       "zg",
+		Category:    "Utility",
+		Examples:    []string{"zg - Accept the word as correctly spelled"},
+	})
+
+	registry.Register("zw", (*Editor).spellWrong, CommandInfo{
+		Name:        "zw",
+		Description: "Mark the word under the cursor as misspelled in the personal word list",
+		Usage:       "zw",
+		Category:    "Utility",
+		Examples:    []string{"zw - Treat the word as misspelled"},
+	})
+
+	registry.Register("zug", (*Editor).spellUndo, CommandInfo{
+		Name:        "zug",
+		Aliases:     []string{"zuw"},
+		Description: "Remove the word under the cursor from the personal word list",
+		Usage:       "zug",
+		Category:    "Utility",
+		Examples:    []string{"zug - Undo zg or zw for the word"},
+	})
+
+	registry.Register("]s", (*Editor).nextMisspelling, CommandInfo{
+		Name:        "]s",
+		Description: "Move to the next misspelled word",
+		Usage:       "]s",
+		Category:    "Utility",
+		Examples:    []string{"]s - Go to the next misspelled word"},
+	})
+
+	registry.Register("[s", (*Editor).previousMisspelling, CommandInfo{
+		Name:        "[s",
+		Description: "Move to the previous misspelled word",
+		Usage:       "[s",
+		Category:    "Utility",
+		Examples:    []string{"[s - Go to the previous misspelled word"},
+	})
+
+	// Language server commands (notes in the code folder)
+	registry.Register("K", (*Editor).lspHover, CommandInfo{
+		Name:        "K",
+		Description: "Show language server documentation for the word under the cursor",
+		Usage:       "K",
+		Category:    "Code",
+		Examples:    []string{"K - Show the type and docs of the symbol under the cursor"},
+	})
+
+	registry.Register("\x1d", (*Editor).lspDefinition, CommandInfo{
+		Name:        "Ctrl-]",
+		Description: "Jump to the definition of the symbol under the cursor",
+		Usage:       "Ctrl-]",
+		Category:    "Code",
+		Examples:    []string{"Ctrl-] - Go to where the symbol under the cursor is defined"},
+	})
+
 	// System commands
 	registry.Register(string(ctrlKey('z')), (*Editor).switchImplementation, CommandInfo{
 		Name:        keyToDisplayName(string(ctrlKey('z'))),
@@ -423,7 +538,7 @@ func (e *Editor) showMarkdownPreview(_ int) {
 		return
 	}
 	//note := e.generateWWStringFromBuffer2()
-	note := strings.Join(e.vbuf.Lines(), "\n")
+	note := stripImageAttrs(strings.Join(e.vbuf.Lines(), "\n"))
 	r, _ := glamour.NewTermRenderer(
 		glamour.WithStylePath(getGlamourStylePath()),
 		glamour.WithWordWrap(0),
@@ -503,8 +618,8 @@ func (e *Editor) readGoTemplate(_ int) {
 
 func (e *Editor) spellingCheck(_ int) {
 	/* Really need to look at this and decide if there will be a spellcheck flag in NORMAL mode */
-	if !IsSpellCheckAvailable() {
-		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage())
+	if lang, _ := e.spellLang(); !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage(lang))
 		return
 	}
 
@@ -516,8 +631,9 @@ func (e *Editor) spellingCheck(_ int) {
 }
 
 func (e *Editor) spellSuggest(_ int) {
-	if !IsSpellCheckAvailable() {
-		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage())
+	lang, _ := e.spellLang()
+	if !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, ShowSpellCheckNotAvailableMessage(lang))
 		return
 	}
 
@@ -525,12 +641,12 @@ func (e *Editor) spellSuggest(_ int) {
 	w, _, _ := GetWordAtIndex(e.ss[curPos[0]-1], curPos[1])
 	//w := vim.EvaluateExpression("expand('<cword>')")
 
-	if CheckSpelling(w) {
+	if CheckSpelling(lang, w) {
 		e.ShowMessage(BR, "%q is spelled correctly", w)
 		return
 	}
 
-	suggestions := GetSpellingSuggestions(w)
+	suggestions := GetSpellingSuggestions(lang, w)
 	e.ShowMessage(BR, "%q -> %s", w, strings.Join(suggestions, "|"))
 }
 
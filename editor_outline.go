@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// The outline lists a note's markdown headings. In the editor :outline
+// shows it in place of the note: j/k select a heading, <cr> goes to it,
+// </> promote or demote it along with its subheadings and K/J move its
+// whole section up or down. ]] and [[ move between headings in normal
+// mode. Headings are found by parsing the note with goldmark, as the
+// webview does, so # lines in fenced code aren't taken for headings.
+
+// heading is a markdown heading; row is the row where its text starts and
+// for a setext heading underline is the row of the === or --- below it
+type heading struct {
+	level     int
+	text      string
+	row       int
+	underline int
+}
+
+// markdownHeadings returns the headings of a note in order
+func markdownHeadings(rows []string) []heading {
+	src := []byte(strings.Join(rows, "\n"))
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(src))
+	var headings []heading
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		lines := h.Lines()
+		if lines.Len() == 0 {
+			return ast.WalkSkipChildren, nil
+		}
+		first := lines.At(0)
+		var words []string
+		for i := 0; i < lines.Len(); i++ {
+			seg := lines.At(i)
+			words = append(words, strings.TrimSpace(string(seg.Value(src))))
+		}
+		hd := heading{
+			level:     h.Level,
+			text:      strings.Join(words, " "),
+			row:       bytes.Count(src[:first.Start], []byte("\n")),
+			underline: -1,
+		}
+		last := lines.At(lines.Len() - 1)
+		if u := bytes.Count(src[:last.Start], []byte("\n")) + 1; u < len(rows) && setextUnderline(rows[u]) {
+			hd.underline = u
+		}
+		headings = append(headings, hd)
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// setextUnderline reports whether row is the === or --- below a setext
+// heading
+func setextUnderline(row string) bool {
+	row = strings.TrimSpace(row)
+	return row != "" && (strings.Trim(row, "=") == "" || strings.Trim(row, "-") == "")
+}
+
+// sectionEnd returns the last row of the section of headings[i], which
+// runs until the next heading of the same or a higher level
+func sectionEnd(headings []heading, i, rows int) int {
+	for _, h := range headings[i+1:] {
+		if h.level <= headings[i].level {
+			return h.row - 1
+		}
+	}
+	return rows - 1
+}
+
+// subtree returns the index just past the last subheading of headings[i]
+func subtree(headings []heading, i int) int {
+	j := i + 1
+	for j < len(headings) && headings[j].level > headings[i].level {
+		j++
+	}
+	return j
+}
+
+// headingAt returns the index of the heading whose section the row is in,
+// or -1 if it is before the first heading
+func headingAt(headings []heading, row int) int {
+	index := -1
+	for i, h := range headings {
+		if h.row > row {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// nextHeading moves the cursor to the next heading (]])
+func (e *Editor) nextHeading(_ int) {
+	for _, h := range markdownHeadings(e.ss) {
+		if h.row > e.fr {
+			vim.SetCursorPosition(h.row+1, 0)
+			return
+		}
+	}
+	e.ShowMessage(BR, "No heading below the cursor")
+}
+
+// previousHeading moves the cursor to the previous heading ([[)
+func (e *Editor) previousHeading(_ int) {
+	headings := markdownHeadings(e.ss)
+	for i := len(headings) - 1; i >= 0; i-- {
+		if headings[i].row < e.fr {
+			vim.SetCursorPosition(headings[i].row+1, 0)
+			return
+		}
+	}
+	e.ShowMessage(BR, "No heading above the cursor")
+}
+
+// outline shows the note's headings (:outline)
+func (e *Editor) outline() {
+	headings := markdownHeadings(e.ss)
+	if len(headings) == 0 {
+		e.ShowMessage(BR, "The note has no headings")
+		return
+	}
+	e.outlineIndex = max(headingAt(headings, e.fr), 0)
+	e.mode = OUTLINE
+	e.previewLineOffset = 0
+	e.drawOutline()
+	e.ShowMessage(BR, "j/k select, <cr> go to heading, </> promote/demote, K/J move section, q quit")
+}
+
+func (e *Editor) drawOutline() {
+	headings := markdownHeadings(e.ss)
+	if e.outlineIndex >= len(headings) {
+		e.outlineIndex = len(headings) - 1
+	}
+	e.overlay = outlineRows(headings, e.outlineIndex, e.screencols)
+	if e.outlineIndex < e.previewLineOffset {
+		e.previewLineOffset = e.outlineIndex
+	} else if e.outlineIndex >= e.previewLineOffset+e.screenlines {
+		e.previewLineOffset = e.outlineIndex - e.screenlines + 1
+	}
+	e.drawOverlay()
+}
+
+// outlineRows returns the headings indented by level with the selected one
+// in reverse video
+func outlineRows(headings []heading, selected, width int) []string {
+	rows := make([]string, len(headings))
+	for i, h := range headings {
+		row := strings.Repeat("  ", h.level-1) + strings.Repeat("#", h.level) + " " + h.text
+		if utf8.RuneCountInString(row) > width {
+			row = string([]rune(row)[:width])
+		}
+		if i == selected {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// case OUTLINE:
+func (e *Editor) OutlineModeKeyHandler(c int) (redraw, skip bool) {
+	headings := markdownHeadings(e.ss)
+	if len(headings) == 0 {
+		e.mode = NORMAL
+		return true, true
+	}
+	switch c {
+	case ARROW_DOWN, 'j':
+		if e.outlineIndex < len(headings)-1 {
+			e.outlineIndex++
+		}
+	case ARROW_UP, 'k':
+		if e.outlineIndex > 0 {
+			e.outlineIndex--
+		}
+	case '\r':
+		vim.SetCursorPosition(headings[e.outlineIndex].row+1, 0)
+		e.mode = NORMAL
+		e.ss = e.vbuf.Lines()
+		e.fr, e.fc = headings[e.outlineIndex].row, 0
+		e.ShowMessage(BR, "")
+		return true, true
+	case '<', 'h':
+		e.changeHeadingLevel(headings, e.outlineIndex, -1)
+	case '>', 'l':
+		e.changeHeadingLevel(headings, e.outlineIndex, 1)
+	case 'K':
+		e.moveSection(headings, e.outlineIndex, -1)
+	case 'J':
+		e.moveSection(headings, e.outlineIndex, 1)
+	case 'q':
+		e.mode = NORMAL
+		e.ShowMessage(BR, "")
+		return true, true
+	default:
+		return false, true
+	}
+	e.drawOutline()
+	return false, true
+}
+
+// changeHeadingLevel promotes (by -1) or demotes (by 1) headings[i] and
+// its subheadings; setext headings become ATX headings
+func (e *Editor) changeHeadingLevel(headings []heading, i, by int) {
+	end := subtree(headings, i)
+	for _, h := range headings[i:end] {
+		if h.level+by < 1 || h.level+by > 6 {
+			e.ShowMessage(BR, "Headings can't go above level 1 or below level 6")
+			return
+		}
+	}
+	ss := append([]string(nil), e.ss...)
+	var drop []int
+	for _, h := range headings[i:end] {
+		prefix := strings.Repeat("#", h.level+by) + " "
+		if h.underline != -1 {
+			ss[h.row] = prefix + h.text
+			for row := h.row + 1; row <= h.underline; row++ {
+				drop = append(drop, row)
+			}
+			continue
+		}
+		row := strings.TrimLeft(ss[h.row], " ")
+		if !strings.HasPrefix(row, "#") {
+			// a heading inside a quote or list is left alone
+			continue
+		}
+		ss[h.row] = prefix + strings.TrimLeft(strings.TrimLeft(row, "#"), " \t")
+	}
+	// remove setext underlines (and any heading lines after the first) from
+	// the bottom up so rows stay valid
+	for k := len(drop) - 1; k >= 0; k-- {
+		ss = append(ss[:drop[k]], ss[drop[k]+1:]...)
+	}
+	e.setOutlineLines(ss)
+	if by < 0 {
+		e.ShowMessage(BR, "Promoted %d heading(s)", end-i)
+	} else {
+		e.ShowMessage(BR, "Demoted %d heading(s)", end-i)
+	}
+}
+
+// moveSection swaps the section of headings[i] with the section of the
+// previous (dir -1) or next (dir 1) heading at the same level
+func (e *Editor) moveSection(headings []heading, i, dir int) {
+	level := headings[i].level
+	other := -1
+	if dir < 0 {
+		for j := i - 1; j >= 0 && headings[j].level >= level; j-- {
+			if headings[j].level == level {
+				other = j
+				break
+			}
+		}
+	} else {
+		j := subtree(headings, i)
+		if j < len(headings) && headings[j].level == level {
+			other = j
+		}
+	}
+	if other == -1 {
+		e.ShowMessage(BR, "No section to swap with at this level")
+		return
+	}
+	first, second := i, other
+	if dir < 0 {
+		first, second = other, i
+	}
+	aStart, bStart := headings[first].row, headings[second].row
+	bEnd := sectionEnd(headings, second, len(e.ss))
+
+	ss := make([]string, 0, len(e.ss))
+	ss = append(ss, e.ss[:aStart]...)
+	ss = append(ss, e.ss[bStart:bEnd+1]...)
+	ss = append(ss, e.ss[aStart:bStart]...)
+	ss = append(ss, e.ss[bEnd+1:]...)
+	e.setOutlineLines(ss)
+
+	// keep the moved section selected
+	moved := subtree(headings, second) - second
+	if dir < 0 {
+		e.outlineIndex = first
+	} else {
+		e.outlineIndex = first + moved
+	}
+}
+
+func (e *Editor) setOutlineLines(ss []string) {
+	e.vbuf.SetLines(0, -1, ss)
+	e.ss = e.vbuf.Lines()
+	e.bufferTick = e.vbuf.GetLastChangedTick()
+	e.drawStatusBar()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var outlineNote = []string{
+	"# Title",
+	"intro",
+	"## A",
+	"text",
+	"```",
+	"# not a heading",
+	"```",
+	"### A1",
+	"Setext B",
+	"--------",
+	"body",
+	"# Two",
+}
+
+func TestMarkdownHeadings(t *testing.T) {
+	tests := []struct {
+		rows []string
+		want []heading
+	}{
+		{outlineNote, []heading{
+			{level: 1, text: "Title", row: 0, underline: -1},
+			{level: 2, text: "A", row: 2, underline: -1},
+			{level: 3, text: "A1", row: 7, underline: -1},
+			{level: 2, text: "Setext B", row: 8, underline: 9},
+			{level: 1, text: "Two", row: 11, underline: -1},
+		}},
+		{[]string{"text", "", "Line one", "line two", "===", "##  Spaced  ##"}, []heading{
+			{level: 1, text: "Line one line two", row: 2, underline: 4},
+			{level: 2, text: "Spaced", row: 5, underline: -1},
+		}},
+		{[]string{"no headings", "#hashtag"}, nil},
+	}
+	for _, tt := range tests {
+		if got := markdownHeadings(tt.rows); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("markdownHeadings(%q) = %+v, want %+v", tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestSectionEnd(t *testing.T) {
+	headings := markdownHeadings(outlineNote)
+	for i, want := range []int{10, 7, 7, 10, 11} {
+		if got := sectionEnd(headings, i, len(outlineNote)); got != want {
+			t.Errorf("sectionEnd(%q) = %d, want %d", headings[i].text, got, want)
+		}
+	}
+}
+
+func TestSubtree(t *testing.T) {
+	headings := markdownHeadings(outlineNote)
+	for i, want := range []int{4, 3, 3, 4, 5} {
+		if got := subtree(headings, i); got != want {
+			t.Errorf("subtree(%q) = %d, want %d", headings[i].text, got, want)
+		}
+	}
+}
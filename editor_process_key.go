@@ -13,6 +13,12 @@ import (
 // note that bool returned is whether to redraw
 func (e *Editor) editorProcessKey(c int) (redraw bool) {
 
+	// Ctrl-C stops a running :compile, :run or :test
+	if c == ctrlKey('c') && e.Session.run != nil && e.Session.run.stop() {
+		e.ShowMessage(BR, "Stopping %s", e.Session.run.title)
+		return false
+	}
+
 	//No matter what mode you are in an escape puts you in NORMAL mode
 	if c == '\x1b' {
 		vim.SendKey("<esc>")
@@ -24,6 +30,10 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 		e.ShowMessage(BL, "vim mode: %d | e.mode: %s", mode, e.mode) //////Debug
 		e.command = ""
 		e.command_line = ""
+		e.completion = completion{}
+		e.filterKeys = ""
+		e.heldKey = ""
+		e.charArg = false
 		pos := vim.GetCursorPosition() //set screen cx and cy from pos
 		e.fr = pos[0] - 1
 		e.fc = utf8.RuneCountInString(e.ss[e.fr][:pos[1]])
@@ -31,7 +41,7 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 		e.ShowMessage(BR, "%s", prevMode)
 		//return false
 		// INSERT is below because escaping from INSERT needs a redraw if previously in VISUAL BLOCK mode and an s, c or I was typed
-		if prevMode == VISUAL || prevMode == PREVIEW || prevMode == HELP || prevMode == INSERT { //need to redraw to remove highlight or if leaving preview
+		if prevMode == VISUAL || prevMode == PREVIEW || prevMode == HELP || prevMode == INSERT || prevMode == UNDO_TREE || prevMode == OUTLINE || prevMode == SPELLING || prevMode == SEARCH { //need to redraw to remove highlight or if leaving preview
 			//app.Organizer.refreshScreen()
 			return true
 		} else {
@@ -59,6 +69,14 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 		redraw, exit = e.PreviewModeKeyHandler(c)
 	case VIEW_LOG:
 		redraw, exit = e.ViewLogModeKeyHandler(c)
+	case INSERT:
+		redraw, exit = e.InsertModeKeyHandler(c)
+	case UNDO_TREE:
+		redraw, exit = e.UndoTreeModeKeyHandler(c)
+	case OUTLINE:
+		redraw, exit = e.OutlineModeKeyHandler(c)
+	case SPELLING:
+		redraw, exit = e.SpellingModeKeyHandler(c)
 	}
 
 	// if exit true, don't process key any further
@@ -66,6 +84,7 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 		return
 	}
 	// Process the key
+	prevRow := e.fr
 	if z, found := termcodes[c]; found {
 		vim.SendKey(z)
 	} else {
@@ -79,6 +98,10 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 	} else {
 		redraw = false
 	}
+	if e.redraw {
+		e.redraw = false
+		redraw = true
+	}
 	mode := vim.GetCurrentMode()
 	//submode := vim.GetSubMode() added this 9-22-25 but really only for obscure submodes
 
@@ -157,10 +180,31 @@ func (e *Editor) editorProcessKey(c int) (redraw bool) {
 	}
 
 	e.fc = utf8.RuneCountInString(e.ss[e.fr][:pos[1]])
+	if e.skipFolds(prevRow) {
+		redraw = true
+	}
 
+	if (e.mode == INSERT || e.completion.active) && e.updateCompletion(c) {
+		redraw = true
+	}
+	e.lspSync()
 	return
 }
 
+// charArgKeys are the normal mode commands that take the next key as their
+// argument
+const charArgKeys = "fFtTrm'`\"@"
+
+// normalCmdPrefix reports whether keys start a longer normal command
+func (e *Editor) normalCmdPrefix(keys string) bool {
+	for k := range e.normalCmds {
+		if len(k) > len(keys) && strings.HasPrefix(k, keys) {
+			return true
+		}
+	}
+	return false
+}
+
 // case PREVIEW:
 func (e *Editor) PreviewModeKeyHandler(c int) (redraw, exit bool) {
 	switch c {
@@ -210,14 +254,36 @@ func (e *Editor) ViewLogModeKeyHandler(c int) (redraw, skip bool) {
 // case NORMAL, OTHER (257):
 // note that Crtl-A and Ctrl-X are passed through and perform their usual weird function of incrementing and decrementing the number under the cursor and Ctrl-R also works to undo the last undone change.  All other vim built-in Ctrl commands appear to do nothing.
 func (e *Editor) NormalModeKeyHandler(c int) (redraw, skip bool) {
+	if e.filterKeys != "" || c == '!' && e.command != " " {
+		return e.filterMotionKey(c)
+	}
 	//leader := ' ' //vim.GetLeaderKey()
 	if c == ' ' { //should become if c == leader
 		e.command = string(c)
 		return false, true
 	}
-	e.command += string(c)
-
-	if len(e.command) > 0 {
+	// the key after f, t, r, m ... or an operator is vim's, even [, ] or z
+	wantsKey := e.charArg || vim.GetCurrentMode() == 4 // OP_PENDING
+	e.charArg = false
+	switch {
+	case e.heldKey != "":
+		// [[, ]] and the fold commands are ours; anything else goes to vim
+		keys := e.heldKey + string(rune(c))
+		e.heldKey = ""
+		if _, found := e.normalCmds[keys]; found {
+			e.command = keys
+		} else if e.normalCmdPrefix(keys) {
+			e.heldKey = keys
+			return false, true
+		} else {
+			vim.SendInput(keys[:len(keys)-1])
+			e.command = string(rune(c))
+		}
+	case (c == '[' || c == ']' || c == 'z') && !strings.HasPrefix(e.command, " ") && !wantsKey:
+		e.heldKey = string(rune(c))
+		return false, true
+	default:
+		e.command += string(c)
 		if e.command[0] != ' ' { //leader
 			e.command = string(c)
 		}
@@ -246,6 +312,12 @@ func (e *Editor) NormalModeKeyHandler(c int) (redraw, skip bool) {
 		if e.command[0] == ' ' {
 			return false, true // don't process key
 		} else {
+			if strings.ContainsRune("nN*#", rune(c)) {
+				// searching again brings back highlighting turned off by :noh
+				e.searchHighlight = true
+				e.redraw = true
+			}
+			e.charArg = !wantsKey && strings.ContainsRune(charArgKeys, rune(c))
 			return false, false // have vim process key
 		}
 	}
@@ -253,6 +325,10 @@ func (e *Editor) NormalModeKeyHandler(c int) (redraw, skip bool) {
 
 // case VISUAL:
 func (e *Editor) VisualModeKeyHandler(c int) (redraw, skip bool) {
+	if c == ':' {
+		e.visualCommandLine()
+		return true, true
+	}
 	// Special commands in visual mode to do markdown decoration: ctrl-b, e, i
 	if strings.IndexAny(string(c), "\x02\x05\x09") != -1 { // this should define commmands like normalCmds, ie visualCmds
 		e.decorateWordVisual(c)
@@ -277,10 +353,19 @@ func (e *Editor) ExModeKeyHandler(c int) (redraw, skip bool) {
 		//if e.command_line[0] == '%'
 		//if strings.Index(e.command_line, "s/") != -1
 
+		if e.shellExCommand() {
+			e.mode = NORMAL
+			e.command = ""
+			e.command_line = ""
+			return true, true
+		}
+
 		// we want libvim to handle the following Ex-Commands:
+		// commands other than those are given the visual selection as a range
 		use_vim := []string{"s/", "%s/", "g/", "g!/", "v/"}
+		cmdline := strings.TrimPrefix(e.command_line, visualRange)
 		for _, p := range use_vim {
-			if strings.HasPrefix(e.command_line, p) {
+			if strings.HasPrefix(cmdline, p) {
 				if strings.HasSuffix(e.command_line, "/c") {
 					e.ShowMessage(BR, "We don't support [c]onfirm")
 					e.mode = NORMAL
@@ -298,6 +383,8 @@ func (e *Editor) ExModeKeyHandler(c int) (redraw, skip bool) {
 				return true, true
 			}
 		}
+		e.exRange = cmdline != e.command_line
+		e.command_line = cmdline
 
 		var pos int
 		var cmd string
@@ -316,7 +403,7 @@ func (e *Editor) ExModeKeyHandler(c int) (redraw, skip bool) {
 		if cmd0, found := e.exCmds[cmd]; found {
 			cmd0(e)
 			e.command_line = ""
-			if e.mode != HELP {
+			if e.mode != HELP && e.mode != UNDO_TREE && e.mode != OUTLINE && e.mode != SPELLING {
 				e.mode = NORMAL
 			}
 			e.tabCompletion.index = 0
@@ -408,10 +495,21 @@ func (e *Editor) SearchModeKeyHandler(c int) (bool, bool) {
 	if c == DEL_KEY || c == BACKSPACE {
 		if len(e.command_line) > 0 {
 			e.command_line = e.command_line[:len(e.command_line)-1]
+		} else {
+			// backspacing over the prompt leaves search
+			e.redraw = true
+			return false, false
 		}
+	} else if c == '\r' {
+		// vim runs the search; redraw once it has moved the cursor
+		e.searchHighlight = true
+		e.redraw = true
+		return false, false
 	} else {
 		e.command_line += string(c)
 	}
+	// show matches as the pattern is typed (incsearch)
+	e.drawText()
 	e.ShowMessage(BR, "%s%s", e.searchPrefix, e.command_line)
 	return false, false
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// searchHighlightPattern returns the pattern whose matches should be shown:
+// the pattern being typed after / or ? (incsearch), otherwise the last
+// search pattern while hlsearch is on
+func (e *Editor) searchHighlightPattern() string {
+	if e.mode == SEARCH {
+		return e.command_line
+	}
+	if e.searchHighlight {
+		return vim.EvaluateExpression("@/")
+	}
+	return ""
+}
+
+// drawSearchMatches highlights the visible matches of the current search
+func (e *Editor) drawSearchMatches(pab *strings.Builder) {
+	pattern := e.searchHighlightPattern()
+	if pattern == "" {
+		return
+	}
+	tick := e.vbuf.GetLastChangedTick()
+	if pattern != e.searchMatches.pattern || tick != e.searchMatches.tick {
+		e.searchMatches.pattern = pattern
+		e.searchMatches.tick = tick
+		e.searchMatches.matches = vim.GetSearchMatches(pattern)
+	}
+	matches := e.searchMatches.matches
+	if len(matches) == 0 {
+		return
+	}
+
+	pab.WriteString(app.theme.UI.SearchHighlight)
+	for _, m := range matches {
+		r := m.Line - 1
+		if r < e.firstVisibleRow || r >= len(e.ss) || m.End > len(e.ss[r]) || m.Start >= m.End {
+			continue
+		}
+		if e.getScreenYFromRowColWW(r, 0)-e.lineOffset >= e.screenlines {
+			break
+		}
+		e.drawHighlightedSpan(pab, r, m.Start, m.End)
+	}
+	pab.WriteString(RESET)
+}
+
+// noHighlightSearch turns off search highlighting until the next search (:noh)
+func (e *Editor) noHighlightSearch() {
+	e.searchHighlight = false
+	e.drawText()
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Spelling uses the note's dictionary: the one named by a
+// <!-- spelllang: de_DE --> line in the note (set with :spelllang), else
+// the one configured for the note's context, else the config's default.
+// zg adds the word under the cursor to the personal word list, zw marks it
+// wrong and zug/zuw take it out again. ]s and [s move between misspelled
+// words and :spellreport lists them all. Fenced code isn't checked.
+
+var spellLangDirective = regexp.MustCompile(`^<!--\s*spelllang:\s*([\w-]+)\s*-->$`)
+
+// spellLang returns the note's dictionary and where the choice came from
+func (e *Editor) spellLang() (lang, source string) {
+	for _, row := range e.ss {
+		if m := spellLangDirective.FindStringSubmatch(strings.TrimSpace(row)); m != nil {
+			return m[1], "note"
+		}
+	}
+	if app.Config != nil {
+		if lang, ok := app.Config.Spell.Contexts[e.Database.taskContext(e.id)]; ok && lang != "" {
+			return lang, "context"
+		}
+		if app.Config.Spell.Lang != "" {
+			return app.Config.Spell.Lang, "config"
+		}
+	}
+	return defaultSpellLang, "default"
+}
+
+// misspellings returns the misspelled words of the note in order
+func (e *Editor) misspellings(lang string) []Position {
+	skip := make([]bool, len(e.ss))
+	for _, b := range fencedBlocks(e.ss) {
+		for r := b.start; r <= b.end; r++ {
+			skip[r] = true
+		}
+	}
+	var positions []Position
+	for i, line := range e.ss {
+		if skip[i] || spellLangDirective.MatchString(strings.TrimSpace(line)) {
+			continue
+		}
+		for _, wp := range GetWordPositionsSingleLine(line) {
+			if !CheckSpelling(lang, wp.Word) {
+				positions = append(positions, Position{i, wp.Start, wp.End + 1})
+			}
+		}
+	}
+	return positions
+}
+
+// spellWordAtCursor returns the word under the cursor
+func (e *Editor) spellWordAtCursor() string {
+	pos := vim.GetCursorPosition()
+	w, _, _ := GetWordAtIndex(e.ss[pos[0]-1], pos[1])
+	return w
+}
+
+// markWord adds the word under the cursor to the personal word list as
+// good (zg) or wrong (zw)
+func (e *Editor) markWord(good bool) {
+	w := e.spellWordAtCursor()
+	if w == "" {
+		e.ShowMessage(BR, "No word under the cursor")
+		return
+	}
+	if err := personalWords.set(w, good); err != nil {
+		e.ShowMessage(BR, "Error saving the personal word list: %v", err)
+		return
+	}
+	if good {
+		e.ShowMessage(BR, "Added %q to the personal word list", w)
+	} else {
+		e.ShowMessage(BR, "Marked %q as wrong in the personal word list", w)
+	}
+	e.refreshSpellHighlights()
+}
+
+// spellGood adds the word under the cursor as good (zg)
+func (e *Editor) spellGood(_ int) {
+	e.markWord(true)
+}
+
+// spellWrong marks the word under the cursor as wrong (zw)
+func (e *Editor) spellWrong(_ int) {
+	e.markWord(false)
+}
+
+// spellUndo takes the word under the cursor out of the personal word
+// list (zug, zuw)
+func (e *Editor) spellUndo(_ int) {
+	w := e.spellWordAtCursor()
+	if w == "" {
+		e.ShowMessage(BR, "No word under the cursor")
+		return
+	}
+	removed, err := personalWords.remove(w)
+	switch {
+	case err != nil:
+		e.ShowMessage(BR, "Error saving the personal word list: %v", err)
+	case !removed:
+		e.ShowMessage(BR, "%q isn't in the personal word list", w)
+	default:
+		e.ShowMessage(BR, "Removed %q from the personal word list", w)
+		e.refreshSpellHighlights()
+	}
+}
+
+// refreshSpellHighlights redoes the misspelled word highlighting if it is
+// showing
+func (e *Editor) refreshSpellHighlights() {
+	if e.highlightPositions != nil {
+		e.highlightMispelledWords()
+		e.redraw = true
+	}
+}
+
+// nextMisspelling moves to the next misspelled word (]s)
+func (e *Editor) nextMisspelling(_ int) {
+	e.moveToMisspelling(1)
+}
+
+// previousMisspelling moves to the previous misspelled word ([s)
+func (e *Editor) previousMisspelling(_ int) {
+	e.moveToMisspelling(-1)
+}
+
+// moveToMisspelling moves the cursor to the next misspelled word in dir,
+// wrapping around the note
+func (e *Editor) moveToMisspelling(dir int) {
+	lang, _ := e.spellLang()
+	if !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, "%s", ShowSpellCheckNotAvailableMessage(lang))
+		return
+	}
+	words := e.misspellings(lang)
+	if len(words) == 0 {
+		e.ShowMessage(BR, "No misspelled words")
+		return
+	}
+	pos := vim.GetCursorPosition()
+	row, col := pos[0]-1, pos[1]
+	target := -1
+	if dir > 0 {
+		for i, p := range words {
+			if p.rowNum > row || p.rowNum == row && p.start > col {
+				target = i
+				break
+			}
+		}
+		if target == -1 {
+			target = 0
+			e.ShowMessage(BR, "search hit BOTTOM, continuing at TOP")
+		}
+	} else {
+		for i := len(words) - 1; i >= 0; i-- {
+			if p := words[i]; p.rowNum < row || p.rowNum == row && p.start < col {
+				target = i
+				break
+			}
+		}
+		if target == -1 {
+			target = len(words) - 1
+			e.ShowMessage(BR, "search hit TOP, continuing at BOTTOM")
+		}
+	}
+	p := words[target]
+	vim.SetCursorPosition(p.rowNum+1, p.start)
+}
+
+// spellReport lists the note's misspelled words (:spellreport)
+func (e *Editor) spellReport() {
+	lang, _ := e.spellLang()
+	if !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, "%s", ShowSpellCheckNotAvailableMessage(lang))
+		return
+	}
+	e.spellWords = e.misspellings(lang)
+	if len(e.spellWords) == 0 {
+		e.ShowMessage(BR, "No misspelled words (%s)", lang)
+		return
+	}
+	e.spellIndex = 0
+	e.mode = SPELLING
+	e.previewLineOffset = 0
+	e.drawSpellReport()
+}
+
+func (e *Editor) drawSpellReport() {
+	rows := make([]string, len(e.spellWords))
+	for i, p := range e.spellWords {
+		row := fmt.Sprintf("%5d:%-4d %s", p.rowNum+1, p.start+1, e.ss[p.rowNum][p.start:p.end])
+		if utf8.RuneCountInString(row) > e.screencols {
+			row = string([]rune(row)[:e.screencols])
+		}
+		if i == e.spellIndex {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		rows[i] = row
+	}
+	e.overlay = rows
+	if e.spellIndex < e.previewLineOffset {
+		e.previewLineOffset = e.spellIndex
+	} else if e.spellIndex >= e.previewLineOffset+e.screenlines {
+		e.previewLineOffset = e.spellIndex - e.screenlines + 1
+	}
+	e.drawOverlay()
+
+	// suggestions are only looked up for the selected word since hunspell is slow
+	lang, _ := e.spellLang()
+	p := e.spellWords[e.spellIndex]
+	w := e.ss[p.rowNum][p.start:p.end]
+	e.ShowMessage(BR, "%d misspelled (%s) | %s -> %s | j/k select, <cr> go to word, g add word, q quit",
+		len(e.spellWords), lang, w, strings.Join(GetSpellingSuggestions(lang, w), "|"))
+}
+
+// case SPELLING:
+func (e *Editor) SpellingModeKeyHandler(c int) (redraw, skip bool) {
+	switch c {
+	case ARROW_DOWN, 'j':
+		if e.spellIndex < len(e.spellWords)-1 {
+			e.spellIndex++
+		}
+	case ARROW_UP, 'k':
+		if e.spellIndex > 0 {
+			e.spellIndex--
+		}
+	case '\r':
+		p := e.spellWords[e.spellIndex]
+		vim.SetCursorPosition(p.rowNum+1, p.start)
+		e.fr, e.fc = p.rowNum, utf8.RuneCountInString(e.ss[p.rowNum][:p.start])
+		e.mode = NORMAL
+		e.ShowMessage(BR, "")
+		return true, true
+	case 'g':
+		p := e.spellWords[e.spellIndex]
+		w := e.ss[p.rowNum][p.start:p.end]
+		if err := personalWords.set(w, true); err != nil {
+			e.ShowMessage(BR, "Error saving the personal word list: %v", err)
+			return false, true
+		}
+		lang, _ := e.spellLang()
+		e.spellWords = e.misspellings(lang)
+		if len(e.spellWords) == 0 {
+			e.mode = NORMAL
+			e.ShowMessage(BR, "Added %q; no misspelled words left", w)
+			return true, true
+		}
+		if e.spellIndex >= len(e.spellWords) {
+			e.spellIndex = len(e.spellWords) - 1
+		}
+	case 'q':
+		e.mode = NORMAL
+		e.ShowMessage(BR, "")
+		return true, true
+	default:
+		return false, true
+	}
+	e.drawSpellReport()
+	return false, true
+}
+
+// setSpellLang shows the note's dictionary or, given one, records it in
+// the note (:spelllang [lang])
+func (e *Editor) setSpellLang() {
+	fields := strings.Fields(e.command_line)
+	if len(fields) < 2 {
+		lang, source := e.spellLang()
+		e.ShowMessage(BR, "Spelling dictionary: %s (from %s)", lang, source)
+		return
+	}
+	lang := fields[1]
+	if !IsSpellCheckAvailable(lang) {
+		e.ShowMessage(BR, "%s", ShowSpellCheckNotAvailableMessage(lang))
+		return
+	}
+	directive := fmt.Sprintf("<!-- spelllang: %s -->", lang)
+	ss := append([]string(nil), e.ss...)
+	found := false
+	for i, row := range ss {
+		if spellLangDirective.MatchString(strings.TrimSpace(row)) {
+			ss[i], found = directive, true
+			break
+		}
+	}
+	if !found {
+		ss = append(ss, directive)
+	}
+	pos := vim.GetCursorPosition()
+	e.vbuf.SetLines(0, -1, ss)
+	e.ss = e.vbuf.Lines()
+	vim.SetCursorPosition(pos[0], pos[1])
+	e.refreshSpellHighlights()
+	e.ShowMessage(BR, "Spelling dictionary for this note is now %s", lang)
+}
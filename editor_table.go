@@ -0,0 +1,509 @@
+package main
+
+import (
+	"encoding/csv"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+// Markdown tables: :table realigns the table under the cursor and edits its
+// rows and columns, and Tab/Shift-Tab in insert mode move between cells.
+// Columns are padded to the display width of their widest cell so tables
+// with wide characters line up on screen.
+
+// mdTable is a markdown table parsed from rows start to end of the note
+type mdTable struct {
+	start, end int
+	indent     string
+	rows       [][]string // cells of the header and body rows
+	align      []byte     // per column: 'l', 'r', 'c' or 0 for none
+	hasSep     bool       // the header is followed by a separator row
+}
+
+func isTableRow(row string) bool {
+	return strings.HasPrefix(strings.TrimSpace(row), "|")
+}
+
+// splitTableRow returns the cells of a table row, trimmed, splitting on
+// pipes that aren't escaped
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteString(`\|`)
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// separatorAlign returns the alignments of a separator row such as
+// | :--- | ---: | or false if cells isn't one
+func separatorAlign(cells []string) ([]byte, bool) {
+	align := make([]byte, len(cells))
+	for i, c := range cells {
+		d := strings.Trim(c, ":")
+		if d == "" || strings.Trim(d, "-") != "" {
+			return nil, false
+		}
+		switch left, right := c[0] == ':', c[len(c)-1] == ':'; {
+		case left && right:
+			align[i] = 'c'
+		case right:
+			align[i] = 'r'
+		case left:
+			align[i] = 'l'
+		}
+	}
+	return align, true
+}
+
+// tableAt returns the table that includes row
+func tableAt(ss []string, row int) (*mdTable, bool) {
+	if row < 0 || row >= len(ss) || !isTableRow(ss[row]) {
+		return nil, false
+	}
+	t := &mdTable{start: row, end: row}
+	for t.start > 0 && isTableRow(ss[t.start-1]) {
+		t.start--
+	}
+	for t.end < len(ss)-1 && isTableRow(ss[t.end+1]) {
+		t.end++
+	}
+	first := ss[t.start]
+	t.indent = first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	for r := t.start; r <= t.end; r++ {
+		cells := splitTableRow(ss[r])
+		if r == t.start+1 {
+			if align, ok := separatorAlign(cells); ok {
+				t.align = align
+				t.hasSep = true
+				continue
+			}
+		}
+		t.rows = append(t.rows, cells)
+	}
+	t.normalize()
+	return t, true
+}
+
+// normalize gives every row the same number of columns
+func (t *mdTable) normalize() {
+	n := len(t.align)
+	for _, r := range t.rows {
+		if len(r) > n {
+			n = len(r)
+		}
+	}
+	for i, r := range t.rows {
+		for len(r) < n {
+			r = append(r, "")
+		}
+		t.rows[i] = r
+	}
+	for len(t.align) < n {
+		t.align = append(t.align, 0)
+	}
+	t.align = t.align[:n]
+}
+
+func (t *mdTable) columns() int {
+	return len(t.align)
+}
+
+// tableRow returns the index in t.rows of the note's row, or -1 for the
+// separator
+func (t *mdTable) tableRow(row int) int {
+	i := row - t.start
+	if t.hasSep && i >= 1 {
+		if i == 1 {
+			return -1
+		}
+		i--
+	}
+	return i
+}
+
+// noteRow is the inverse of tableRow
+func (t *mdTable) noteRow(i int) int {
+	if t.hasSep && i >= 1 {
+		i++
+	}
+	return t.start + i
+}
+
+// format returns the table's rows with every column padded to the display
+// width of its widest cell
+func (t *mdTable) format() []string {
+	widths := make([]int, t.columns())
+	for i := range widths {
+		widths[i] = 3
+	}
+	for _, r := range t.rows {
+		for i, c := range r {
+			if w := visibleWidth(c); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	line := func(cells []string, cell func(i int, c string) string) string {
+		var sb strings.Builder
+		sb.WriteString(t.indent + "|")
+		for i, c := range cells {
+			sb.WriteString(" " + cell(i, c) + " |")
+		}
+		return sb.String()
+	}
+	pad := func(i int, c string) string {
+		space := widths[i] - visibleWidth(c)
+		switch t.align[i] {
+		case 'r':
+			return strings.Repeat(" ", space) + c
+		case 'c':
+			return strings.Repeat(" ", space/2) + c + strings.Repeat(" ", space-space/2)
+		}
+		return c + strings.Repeat(" ", space)
+	}
+
+	var lines []string
+	for j, r := range t.rows {
+		lines = append(lines, line(r, pad))
+		if j == 0 && t.hasSep {
+			lines = append(lines, line(r, func(i int, _ string) string {
+				dashes := []byte(strings.Repeat("-", widths[i]))
+				if t.align[i] == 'l' || t.align[i] == 'c' {
+					dashes[0] = ':'
+				}
+				if t.align[i] == 'r' || t.align[i] == 'c' {
+					dashes[len(dashes)-1] = ':'
+				}
+				return string(dashes)
+			}))
+		}
+	}
+	return lines
+}
+
+// cellAt returns the column of byte offset col in a table row
+func cellAt(row string, col, columns int) int {
+	trimmed := strings.TrimLeft(row, " \t")
+	offset := len(row) - len(trimmed)
+	pipes := 0
+	for i := 0; i < len(trimmed) && offset+i < col; i++ {
+		if trimmed[i] == '\\' {
+			i++
+			continue
+		}
+		if trimmed[i] == '|' {
+			pipes++
+		}
+	}
+	cell := pipes - 1
+	if cell < 0 {
+		cell = 0
+	}
+	if cell >= columns {
+		cell = columns - 1
+	}
+	return cell
+}
+
+// cellStart returns the byte offset of the text of a cell in a formatted row
+func cellStart(row string, cell int) int {
+	pipes := 0
+	for i := 0; i < len(row); i++ {
+		if row[i] == '\\' {
+			i++
+			continue
+		}
+		if row[i] == '|' {
+			if pipes == cell {
+				start := i + 1
+				for start < len(row) && row[start] == ' ' {
+					start++
+				}
+				if (start == len(row) || row[start] == '|') && i+2 <= len(row) {
+					// an empty cell
+					return i + 2
+				}
+				return start
+			}
+			pipes++
+		}
+	}
+	return len(row)
+}
+
+// currentTable returns the table under the cursor and the row and column of
+// the cell the cursor is in; row is -1 on the separator
+func (e *Editor) currentTable() (t *mdTable, row, col int, ok bool) {
+	t, ok = tableAt(e.ss, e.fr)
+	if !ok {
+		return nil, 0, 0, false
+	}
+	pos := vim.GetCursorPosition()
+	return t, t.tableRow(e.fr), cellAt(e.ss[e.fr], pos[1], t.columns()), true
+}
+
+// writeTable replaces the table's rows in the note with it formatted and
+// puts the cursor at the start of the cell at row, col
+func (e *Editor) writeTable(t *mdTable, row, col int) {
+	lines := t.format()
+	ss := make([]string, 0, len(e.ss)-(t.end-t.start+1)+len(lines))
+	ss = append(ss, e.ss[:t.start]...)
+	ss = append(ss, lines...)
+	ss = append(ss, e.ss[t.end+1:]...)
+	if !slices.Equal(ss, e.ss) {
+		e.vbuf.SetLines(0, -1, ss)
+		e.ss = e.vbuf.Lines()
+	}
+	t.end = t.start + len(lines) - 1
+
+	if row < 0 {
+		row = 0
+	}
+	if row >= len(t.rows) {
+		row = len(t.rows) - 1
+	}
+	if col < 0 {
+		col = 0
+	}
+	if col >= t.columns() {
+		col = t.columns() - 1
+	}
+	r := t.noteRow(row)
+	c := cellStart(e.ss[r], col)
+	vim.SetCursorPosition(r+1, c)
+	e.fr = r
+	e.fc = utf8.RuneCountInString(e.ss[r][:c])
+}
+
+// table dispatches :table <action>
+func (e *Editor) table() {
+	fields := strings.Fields(e.command_line)
+	action := "align"
+	if len(fields) > 1 {
+		action = fields[1]
+	}
+	var args []string
+	if len(fields) > 2 {
+		args = fields[2:]
+	}
+
+	if action == "csv" {
+		e.tableFromCSV()
+		return
+	}
+
+	t, row, col, ok := e.currentTable()
+	if !ok {
+		e.ShowMessage(BR, "The cursor isn't in a markdown table")
+		return
+	}
+	if row < 0 {
+		row = 0
+	}
+	n := t.columns()
+
+	switch action {
+	case "align":
+	case "addrow":
+		// a row added on the header goes at the top of the body
+		t.rows = append(t.rows[:row+1], append([][]string{make([]string, n)}, t.rows[row+1:]...)...)
+		row++
+	case "delrow":
+		if row == 0 && t.hasSep {
+			e.ShowMessage(BR, "The header row can't be deleted")
+			return
+		}
+		if len(t.rows) == 1 {
+			e.ShowMessage(BR, "A table needs at least one row")
+			return
+		}
+		t.rows = append(t.rows[:row], t.rows[row+1:]...)
+	case "addcol":
+		for i, r := range t.rows {
+			t.rows[i] = append(r[:col+1], append([]string{""}, r[col+1:]...)...)
+		}
+		t.align = append(t.align[:col+1], append([]byte{0}, t.align[col+1:]...)...)
+		col++
+	case "delcol":
+		if n == 1 {
+			e.ShowMessage(BR, "A table needs at least one column")
+			return
+		}
+		for i, r := range t.rows {
+			t.rows[i] = append(r[:col], r[col+1:]...)
+		}
+		t.align = append(t.align[:col], t.align[col+1:]...)
+	case "left", "right":
+		other := col - 1
+		if action == "right" {
+			other = col + 1
+		}
+		if other < 0 || other >= n {
+			return
+		}
+		for _, r := range t.rows {
+			r[col], r[other] = r[other], r[col]
+		}
+		t.align[col], t.align[other] = t.align[other], t.align[col]
+		col = other
+	case "sort":
+		if !e.sortTable(t, col, args) {
+			return
+		}
+	default:
+		e.ShowMessage(BR, "Unknown table action %q; use align, addrow, delrow, addcol, delcol, left, right, sort or csv", action)
+		return
+	}
+	e.writeTable(t, row, col)
+}
+
+// sortTable sorts the body rows by col, or the column given as the first
+// argument (counting from 1), numerically if every cell is a number;
+// "desc" reverses the order
+func (e *Editor) sortTable(t *mdTable, col int, args []string) bool {
+	desc := false
+	for _, a := range args {
+		if a == "desc" || a == "!" {
+			desc = true
+		} else if n, err := strconv.Atoi(a); err == nil && n >= 1 && n <= t.columns() {
+			col = n - 1
+		} else {
+			e.ShowMessage(BR, "Usage: table sort [column] [desc]")
+			return false
+		}
+	}
+	body := t.rows
+	if t.hasSep {
+		body = t.rows[1:]
+	}
+	numeric := true
+	for _, r := range body {
+		if _, err := strconv.ParseFloat(r[col], 64); err != nil {
+			numeric = false
+			break
+		}
+	}
+	less := func(a, b string) bool {
+		if numeric {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return x < y
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	}
+	sort.SliceStable(body, func(i, j int) bool {
+		if desc {
+			return less(body[j][col], body[i][col])
+		}
+		return less(body[i][col], body[j][col])
+	})
+	e.ShowMessage(BR, "Sorted by column %d", col+1)
+	return true
+}
+
+// tableFromCSV turns the visual selection, or the paragraph under the
+// cursor, from comma or tab separated values into a table whose first row
+// is the header
+func (e *Editor) tableFromCSV() {
+	start, end := e.fr, e.fr
+	if e.exRange {
+		start, end = e.visualRows[0], e.visualRows[1]
+	} else {
+		for start > 0 && strings.TrimSpace(e.ss[start-1]) != "" {
+			start--
+		}
+		for end < len(e.ss)-1 && strings.TrimSpace(e.ss[end+1]) != "" {
+			end++
+		}
+	}
+	if start < 0 || end >= len(e.ss) || strings.TrimSpace(strings.Join(e.ss[start:end+1], "")) == "" {
+		e.ShowMessage(BR, "There are no values to make a table from")
+		return
+	}
+	t, err := tableFromValues(e.ss[start : end+1])
+	if err != nil {
+		e.ShowMessage(BR, "Error reading values: %v", err)
+		return
+	}
+	t.start, t.end = start, end
+	e.writeTable(t, 0, 0)
+	e.ShowMessage(BR, "Made a table of %d rows and %d columns", len(t.rows), t.columns())
+}
+
+// tableFromValues parses lines of comma or tab separated values into a
+// table whose first row is the header
+func tableFromValues(lines []string) (*mdTable, error) {
+	text := strings.Join(lines, "\n")
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	if strings.Contains(text, "\t") {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	t := &mdTable{hasSep: true}
+	for _, rec := range records {
+		for i := range rec {
+			rec[i] = strings.ReplaceAll(strings.TrimSpace(rec[i]), "|", `\|`)
+		}
+		t.rows = append(t.rows, rec)
+	}
+	t.normalize()
+	return t, nil
+}
+
+// tableTab moves to the next (dir 1) or previous (dir -1) cell of the table
+// under the cursor in insert mode, realigning the table first; Tab in the
+// last cell adds a row. It reports whether the cursor was in a table.
+func (e *Editor) tableTab(dir int) bool {
+	t, row, col, ok := e.currentTable()
+	if !ok {
+		return false
+	}
+	if row < 0 {
+		row = 0
+	}
+	col += dir
+	switch {
+	case col >= t.columns():
+		col = 0
+		row++
+		if row >= len(t.rows) {
+			t.rows = append(t.rows, make([]string, t.columns()))
+		}
+	case col < 0:
+		if row == 0 {
+			col = 0
+		} else {
+			col = t.columns() - 1
+			row--
+		}
+	}
+	e.writeTable(t, row, col)
+	return true
+}
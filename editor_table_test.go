@@ -0,0 +1,207 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTableRow(t *testing.T) {
+	tests := []struct {
+		row  string
+		want []string
+	}{
+		{"| a | b |", []string{"a", "b"}},
+		{"|a|b", []string{"a", "b"}},
+		{"  | x |  ", []string{"x"}},
+		{"| |", []string{""}},
+		{"| a |  | c |", []string{"a", "", "c"}},
+		{`| a \| b | c |`, []string{`a \| b`, "c"}},
+		{`| a | b \|`, []string{"a", `b \|`}},
+	}
+	for _, tt := range tests {
+		if got := splitTableRow(tt.row); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitTableRow(%q) = %q, want %q", tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestSeparatorAlign(t *testing.T) {
+	tests := []struct {
+		cells []string
+		want  []byte
+		ok    bool
+	}{
+		{[]string{":---", "---:", ":-:", "---"}, []byte{'l', 'r', 'c', 0}, true},
+		{[]string{"-"}, []byte{0}, true},
+		{[]string{"---", "abc"}, nil, false},
+		{[]string{":"}, nil, false},
+		{[]string{""}, nil, false},
+		{[]string{"-:-"}, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := separatorAlign(tt.cells)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("separatorAlign(%q) = %v, %v, want %v, %v", tt.cells, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTableAt(t *testing.T) {
+	ss := []string{
+		"text",
+		"  | a | b |",
+		"  |---|--:|",
+		"  | 1 | 2 | 3 |",
+		"",
+	}
+	if _, ok := tableAt(ss, 0); ok {
+		t.Errorf("tableAt(ss, 0) found a table in %q", ss[0])
+	}
+	for _, row := range []int{1, 2, 3} {
+		tbl, ok := tableAt(ss, row)
+		if !ok {
+			t.Fatalf("tableAt(ss, %d) found no table", row)
+		}
+		if tbl.start != 1 || tbl.end != 3 || tbl.indent != "  " || !tbl.hasSep {
+			t.Errorf("tableAt(ss, %d) = start %d end %d indent %q hasSep %v, want 1 3 \"  \" true",
+				row, tbl.start, tbl.end, tbl.indent, tbl.hasSep)
+		}
+		wantRows := [][]string{{"a", "b", ""}, {"1", "2", "3"}}
+		if !reflect.DeepEqual(tbl.rows, wantRows) {
+			t.Errorf("tableAt(ss, %d).rows = %q, want %q", row, tbl.rows, wantRows)
+		}
+		if wantAlign := []byte{0, 'r', 0}; !reflect.DeepEqual(tbl.align, wantAlign) {
+			t.Errorf("tableAt(ss, %d).align = %v, want %v", row, tbl.align, wantAlign)
+		}
+	}
+
+	tbl, _ := tableAt(ss, 1)
+	for row, want := range map[int]int{1: 0, 2: -1, 3: 1} {
+		if got := tbl.tableRow(row); got != want {
+			t.Errorf("tableRow(%d) = %d, want %d", row, got, want)
+		}
+	}
+	for i, want := range map[int]int{0: 1, 1: 3} {
+		if got := tbl.noteRow(i); got != want {
+			t.Errorf("noteRow(%d) = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestTableFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		table mdTable
+		want  []string
+	}{
+		{
+			name: "aligned columns",
+			table: mdTable{
+				rows:   [][]string{{"Name", "Qty"}, {"apple", "10"}},
+				align:  []byte{'l', 'r'},
+				hasSep: true,
+			},
+			want: []string{
+				"| Name  | Qty |",
+				"| :---- | --: |",
+				"| apple |  10 |",
+			},
+		},
+		{
+			name: "centered and unaligned",
+			table: mdTable{
+				rows:   [][]string{{"Fruit", "x"}, {"fig", ""}},
+				align:  []byte{'c', 0},
+				hasSep: true,
+			},
+			want: []string{
+				"| Fruit | x   |",
+				"| :---: | --- |",
+				"|  fig  |     |",
+			},
+		},
+		{
+			name: "wide characters and indent without separator",
+			table: mdTable{
+				indent: "  ",
+				rows:   [][]string{{"日本", "a"}, {"b", "cd"}},
+				align:  []byte{0, 0},
+			},
+			want: []string{
+				"  | 日本 | a   |",
+				"  | b    | cd  |",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.table.format(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: format() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCellAt(t *testing.T) {
+	tests := []struct {
+		row          string
+		col, columns int
+		want         int
+	}{
+		{"| ab | cd |", 0, 2, 0},
+		{"| ab | cd |", 2, 2, 0},
+		{"| ab | cd |", 5, 2, 0},
+		{"| ab | cd |", 7, 2, 1},
+		{"| ab | cd |", 11, 2, 1},
+		{`| a\|b | c |`, 5, 2, 0},
+		{`| a\|b | c |`, 9, 2, 1},
+		{"  | a | b |", 6, 2, 0},
+		{"  | a | b |", 7, 2, 1},
+	}
+	for _, tt := range tests {
+		if got := cellAt(tt.row, tt.col, tt.columns); got != tt.want {
+			t.Errorf("cellAt(%q, %d, %d) = %d, want %d", tt.row, tt.col, tt.columns, got, tt.want)
+		}
+	}
+}
+
+func TestCellStart(t *testing.T) {
+	tests := []struct {
+		row  string
+		cell int
+		want int
+	}{
+		{"| ab | cd |", 0, 2},
+		{"| ab | cd |", 1, 7},
+		{"|     | x |", 0, 2},
+		{`| a\|b | c |`, 1, 9},
+		{"  | a | b |", 1, 8},
+		{"| ab | cd |", 5, 11},
+	}
+	for _, tt := range tests {
+		if got := cellStart(tt.row, tt.cell); got != tt.want {
+			t.Errorf("cellStart(%q, %d) = %d, want %d", tt.row, tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestTableFromValues(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  [][]string
+	}{
+		{[]string{"name,qty", "apple, 10", "pear"}, [][]string{{"name", "qty"}, {"apple", "10"}, {"pear", ""}}},
+		{[]string{"a\tb c", "d\te"}, [][]string{{"a", "b c"}, {"d", "e"}}},
+		{[]string{"a|b,c"}, [][]string{{`a\|b`, "c"}}},
+		{[]string{`"x, y",z`}, [][]string{{"x, y", "z"}}},
+	}
+	for _, tt := range tests {
+		tbl, err := tableFromValues(tt.lines)
+		if err != nil {
+			t.Errorf("tableFromValues(%q) returned error %v", tt.lines, err)
+			continue
+		}
+		if !reflect.DeepEqual(tbl.rows, tt.want) || !tbl.hasSep || tbl.columns() != len(tt.want[0]) {
+			t.Errorf("tableFromValues(%q) = %q (hasSep %v, %d columns), want %q with a separator",
+				tt.lines, tbl.rows, tbl.hasSep, tbl.columns(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/vim"
+	"github.com/slzatz/vimango/vim/interfaces"
+)
+
+// saveUndoHistory stores the buffer's undo history so it survives closing the note
+func (e *Editor) saveUndoHistory() {
+	if e.id <= 0 {
+		return
+	}
+	history, err := e.vbuf.UndoHistory()
+	if err != nil {
+		e.ShowMessage(BR, "Error retrieving undo history: %v", err)
+		return
+	}
+	err = e.Database.saveUndoHistory(e.id, vim.GetActiveImplementation(), history)
+	if err != nil {
+		e.ShowMessage(BR, "Error saving undo history for entry %d: %v", e.id, err)
+		return
+	}
+	e.undoSavedSeq = currentUndoSeq()
+}
+
+// restoreUndoHistory loads the stored undo history for the note. A history
+// saved for different text (e.g. the note was changed by a sync) is ignored.
+func (e *Editor) restoreUndoHistory() {
+	if e.id <= 0 {
+		return
+	}
+	if history := e.Database.readUndoHistory(e.id, vim.GetActiveImplementation()); history != nil {
+		e.vbuf.RestoreUndoHistory(history)
+	}
+	e.undoSavedSeq = currentUndoSeq()
+}
+
+func currentUndoSeq() int {
+	for _, s := range vim.GetUndoStates() {
+		if s.Current {
+			return s.Seq
+		}
+	}
+	return 0
+}
+
+func (e *Editor) earlier() {
+	vim.ExecuteCommand(e.command_line)
+	e.syncAfterUndo()
+}
+
+func (e *Editor) later() {
+	vim.ExecuteCommand(e.command_line)
+	e.syncAfterUndo()
+}
+
+// syncAfterUndo picks up the buffer text and cursor after vim moved
+// through the undo history and redraws the note
+func (e *Editor) syncAfterUndo() {
+	e.ss = e.vbuf.Lines()
+	if len(e.ss) == 0 {
+		e.ss = []string{""}
+	}
+	pos := vim.GetCursorPosition()
+	e.fr = pos[0] - 1
+	if e.fr < 0 {
+		e.fr = 0
+	}
+	if e.fr >= len(e.ss) {
+		e.fr = len(e.ss) - 1
+	}
+	if pos[1] > len(e.ss[e.fr]) {
+		pos[1] = len(e.ss[e.fr])
+	}
+	e.fc = utf8.RuneCountInString(e.ss[e.fr][:pos[1]])
+	e.bufferTick = e.vbuf.GetLastChangedTick()
+	e.drawText()
+	e.drawStatusBar()
+}
+
+// undoTree shows the note's undo states in an overlay; j/k select a state
+// and <cr> moves the buffer to it
+func (e *Editor) undoTree() {
+	e.undoStates = vim.GetUndoStates()
+	if len(e.undoStates) == 0 {
+		e.ShowMessage(BR, "No undo history")
+		return
+	}
+	e.undoIndex = 0
+	for i, s := range e.undoStates {
+		if s.Current {
+			e.undoIndex = i
+		}
+	}
+	e.mode = UNDO_TREE
+	e.previewLineOffset = 0
+	e.drawUndoTree()
+	e.ShowMessage(BR, "j/k select, <cr> go to state, q or <esc> quit")
+}
+
+func (e *Editor) drawUndoTree() {
+	e.overlay = e.undoTreeRows(e.undoStates)
+	if e.undoIndex < e.previewLineOffset {
+		e.previewLineOffset = e.undoIndex
+	} else if e.undoIndex >= e.previewLineOffset+e.screenlines {
+		e.previewLineOffset = e.undoIndex - e.screenlines + 1
+	}
+	e.drawOverlay()
+}
+
+func (e *Editor) undoTreeRows(states []interfaces.UndoState) []string {
+	rows := make([]string, len(states))
+	for i, s := range states {
+		var when string
+		if s.Time.IsZero() {
+			when = "original"
+		} else {
+			when = timeDelta(s.Time.UTC().Format("2006-01-02 15:04:05"))
+		}
+		marker := "  "
+		if s.Current {
+			marker = "> "
+		}
+		row := fmt.Sprintf("%s%s%4d  %s", marker, strings.Repeat("  ", s.Depth), s.Seq, when)
+		if s.Seq == e.undoSavedSeq {
+			row += "  [saved]"
+		}
+		if utf8.RuneCountInString(row) > e.screencols {
+			row = string([]rune(row)[:e.screencols])
+		}
+		if i == e.undoIndex {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// case UNDO_TREE:
+func (e *Editor) UndoTreeModeKeyHandler(c int) (redraw, skip bool) {
+	switch c {
+	case ARROW_DOWN, 'j':
+		if e.undoIndex < len(e.undoStates)-1 {
+			e.undoIndex++
+			e.drawUndoTree()
+		}
+	case ARROW_UP, 'k':
+		if e.undoIndex > 0 {
+			e.undoIndex--
+			e.drawUndoTree()
+		}
+	case '\r':
+		vim.UndoJump(e.undoStates[e.undoIndex].Seq)
+		e.mode = NORMAL
+		e.undoStates = nil
+		e.syncAfterUndo()
+		e.ShowMessage(BR, "")
+		return true, true
+	case 'q':
+		e.mode = NORMAL
+		e.undoStates = nil
+		e.ShowMessage(BR, "")
+		return true, true
+	}
+	return false, true
+}
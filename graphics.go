@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/slzatz/vimango/rawmode"
+)
+
+// Inline images in the preview go through an imageProtocol. kitty's graphics
+// protocol draws an image through Unicode placeholder text, so once the image
+// is transmitted the preview text is all there is to it. Sixel and iTerm2
+// (OSC 1337) images are pixels drawn at the cursor that the terminal forgets
+// as soon as their cells are redrawn, so the preview reserves blank rows for
+// them, each starting with a token naming the image and the row, and
+// drawRenderedNote draws the image rows that are on screen after the text.
+// Drawing only the visible rows is what lets the preview scroll.
+//
+// Terminals without any of these get blocks: the image drawn in the
+// preview text with half-block characters.
+//
+// The protocol is chosen at startup: images.protocol in config.json or
+// $VIMANGO_IMAGE_PROTOCOL (auto, kitty, sixel, iterm2, blocks or none)
+// overrides detection, which prefers kitty, then iTerm2 for the terminals
+// known to support it, then sixel if the terminal's device attributes (DA1)
+// or its XTSMGRAPHICS reply say it has it and then blocks. :imagemode
+// changes it while running.
+
+type imageProtocol interface {
+	String() string
+	// beginRender is called before the images of a render are transmitted
+	beginRender()
+	// cached reports whether the image at url, sized by attrs, can be shown
+	// without loading it
+	cached(url string, attrs imageAttrs) bool
+	// transmit sends a prepared image to the terminal, or keeps it to draw
+	// later, and returns its id and size in cells
+	transmit(prep *preparedImage, attrs imageAttrs, maxCols int) (id uint32, cols, rows int)
+	// placeholder returns the preview text that stands in for an image
+	placeholder(id uint32, cols, rows int) string
+}
+
+// DetectImageProtocol chooses how the preview shows images; it must run in
+// raw mode before the key reader starts since probing reads the terminal's
+// replies from stdin
+func (a *App) DetectImageProtocol() {
+	name := os.Getenv("VIMANGO_IMAGE_PROTOCOL")
+	if name == "" && a.Config != nil {
+		name = a.Config.Images.Protocol
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch {
+	case name == "kitty" || name == "blocks" || name == "none":
+	case (name == "" || name == "auto") && a.kitty && a.kittyPlace:
+	default:
+		a.graphics = probeTerminalGraphics()
+	}
+	a.graphics.kitty, a.graphics.kittyPlace = a.kitty, a.kittyPlace
+
+	if err := a.setImageProtocol(name); err != nil {
+		a.setImageProtocol("auto")
+	}
+}
+
+// setImageProtocol switches the preview to the named image protocol; auto
+// (or "") chooses the best one the terminal has and falls back to blocks
+func (a *App) setImageProtocol(name string) error {
+	caps := a.graphics
+	// only a forced kitty mode overrides what detection found
+	a.kitty, a.kittyPlace = caps.kitty, caps.kittyPlace
+	switch name {
+	case "kitty":
+		a.kitty = true
+		a.kittyPlace = true
+		a.imageProtocol = kittyProtocol{}
+	case "sixel":
+		a.imageProtocol = newCellProtocol(sixelEncoder{colors: caps.colors}, caps)
+	case "iterm2":
+		a.imageProtocol = newCellProtocol(iterm2Encoder{}, caps)
+	case "blocks":
+		a.imageProtocol = newBlocksProtocol()
+	case "none":
+		a.imageProtocol = nil
+	case "", "auto":
+		switch {
+		case a.kitty && a.kittyPlace:
+			a.imageProtocol = kittyProtocol{}
+		case isITerm2Terminal():
+			a.imageProtocol = newCellProtocol(iterm2Encoder{}, caps)
+		case caps.sixel:
+			a.imageProtocol = newCellProtocol(sixelEncoder{colors: caps.colors}, caps)
+		default:
+			a.imageProtocol = newBlocksProtocol()
+		}
+	default:
+		return fmt.Errorf("unknown image mode %q (use auto, kitty, sixel, iterm2, blocks or none)", name)
+	}
+	a.showImages = a.imageProtocol != nil
+	return nil
+}
+
+// isITerm2Terminal reports whether the terminal is one known to draw images
+// sent with iTerm2's OSC 1337 File sequence
+func isITerm2Terminal() bool {
+	V := GetEnvIdentifiers()
+	switch V["TERM_PROGRAM"] {
+	case "iterm.app", "wezterm", "mintty", "warpterminal":
+		return true
+	}
+	return V["LC_TERMINAL"] == "iterm2"
+}
+
+// terminalGraphics is what probing learned about the terminal
+type terminalGraphics struct {
+	kitty        bool // kitty graphics protocol, as DetectKittyCapabilities found it
+	kittyPlace   bool // kitty Unicode placeholders, as DetectKittyCapabilities found it
+	sixel        bool
+	colors       int // sixel color registers; 0 if the terminal didn't say
+	cellW, cellH int // cell size in pixels; 0 if the terminal didn't say
+}
+
+var (
+	da1Reply        = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+	xtsmColorsReply = regexp.MustCompile(`\x1b\[\?1;0;(\d+)S`)
+	xtsmGeometry    = regexp.MustCompile(`\x1b\[\?2;0;(\d+);(\d+)S`)
+	cellSizeReply   = regexp.MustCompile(`\x1b\[6;(\d+);(\d+)t`)
+)
+
+// imageProbeTimeout is how long to wait for the terminal's replies
+const imageProbeTimeout = 500 * time.Millisecond
+
+// probeTerminalGraphics asks the terminal for its sixel color registers and
+// geometry (XTSMGRAPHICS), its cell size in pixels and its device attributes
+func probeTerminalGraphics() terminalGraphics {
+	var caps terminalGraphics
+	reply, err := queryTerminal("\x1b[?1;1;0S\x1b[?2;1;0S\x1b[16t", imageProbeTimeout)
+	if err != nil {
+		return caps
+	}
+	if m := da1Reply.FindSubmatch(reply); m != nil {
+		for _, attr := range strings.Split(string(m[1]), ";") {
+			if attr == "4" {
+				caps.sixel = true
+			}
+		}
+	}
+	if xtsmGeometry.Match(reply) {
+		caps.sixel = true
+	}
+	if m := xtsmColorsReply.FindSubmatch(reply); m != nil {
+		caps.colors, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := cellSizeReply.FindSubmatch(reply); m != nil {
+		caps.cellH, _ = strconv.Atoi(string(m[1]))
+		caps.cellW, _ = strconv.Atoi(string(m[2]))
+	}
+	return caps
+}
+
+// kittyProtocol draws images with kitty's graphics protocol and Unicode
+// placeholders
+type kittyProtocol struct{}
+
+func (kittyProtocol) String() string { return "kitty" }
+
+func (kittyProtocol) beginRender() { seedKittySessionFromCache() }
+
+func (kittyProtocol) cached(url string, attrs imageAttrs) bool {
+	if attrs.sized() {
+		id, ok := smallKittyID(imageKey(url, attrs))
+		if !ok {
+			return false
+		}
+		kittySessionImageMux.RLock()
+		sessionEntry, inSession := kittySessionImages[id]
+		kittySessionImageMux.RUnlock()
+		return inSession && sessionEntry.confirmed
+	}
+	if globalImageCache == nil {
+		return false
+	}
+	// Check if we have cached metadata with a valid image ID
+	entry, ok := globalImageCache.GetKittyMeta(url)
+	if !ok || entry.ImageID == 0 {
+		return false
+	}
+	// Check if this image ID is in the kitty session cache
+	kittySessionImageMux.RLock()
+	sessionEntry, inSession := kittySessionImages[entry.ImageID]
+	kittySessionImageMux.RUnlock()
+	// Check if the session entry is confirmed or we trust the cache
+	return inSession && (sessionEntry.confirmed || trustKittyCache)
+}
+
+func (kittyProtocol) transmit(prep *preparedImage, attrs imageAttrs, maxCols int) (uint32, int, int) {
+	return transmitPreparedKittyImage(prep, attrs, maxCols)
+}
+
+func (kittyProtocol) placeholder(id uint32, cols, rows int) string {
+	// For virtual placements (a=T,U=1), placementID = imageID
+	return buildPlaceholderGrid(id, id, cols, rows)
+}
+
+// cellEncoder turns images into the escape sequences of a protocol that
+// draws pixels at the cursor
+type cellEncoder interface {
+	String() string
+	// prepare converts an image already scaled to the pixels of its cells
+	// into the form encode takes
+	prepare(img *image.NRGBA) image.Image
+	// encode returns the sequence that draws img over cols x rows cells
+	// starting at the cursor
+	encode(img image.Image, cols, rows int) string
+}
+
+// cellProtocol draws images with a cellEncoder in the rows reserved for them
+type cellProtocol struct {
+	cellEncoder
+	cellW, cellH int // cell size in pixels if the terminal reported it
+}
+
+func newCellProtocol(enc cellEncoder, caps terminalGraphics) *cellProtocol {
+	return &cellProtocol{cellEncoder: enc, cellW: caps.cellW, cellH: caps.cellH}
+}
+
+// cellImage is an image scaled to the cells it is drawn in
+type cellImage struct {
+	url         string
+	fingerprint string
+	img         image.Image // prepared by the encoder
+	cols, rows  int
+	fit         int    // imageColumns when it was scaled
+	full        string // the encoding of the whole image
+	used        uint64 // when it was last transmitted
+}
+
+// maxCellImages is the most scaled images kept for drawing
+const maxCellImages = 32
+
+var (
+	cellImages           = make(map[uint32]*cellImage)
+	cellImageIDs         = make(map[string]uint32)
+	cellImageNext uint32 = 1
+	cellImageUse  uint64
+	cellImageMux  sync.Mutex
+)
+
+// cellSize is the size of a terminal cell in pixels, from the window size
+// if the terminal fills in its pixel size, else as probed, else a guess
+func (p *cellProtocol) cellSize() (int, int) {
+	if ws, err := rawmode.GetWindowSize(); err == nil && ws.Xpixel > 0 && ws.Ypixel > 0 {
+		return int(ws.Xpixel) / int(ws.Col), int(ws.Ypixel) / int(ws.Row)
+	}
+	if p.cellW > 0 && p.cellH > 0 {
+		return p.cellW, p.cellH
+	}
+	return 10, 20
+}
+
+func (p *cellProtocol) beginRender() {}
+
+func (p *cellProtocol) cached(url string, attrs imageAttrs) bool {
+	cellImageMux.Lock()
+	defer cellImageMux.Unlock()
+	id, ok := cellImageIDs[imageKey(url, attrs)]
+	if !ok {
+		return false
+	}
+	ci := cellImages[id]
+	return ci != nil && ci.fit == imageColumns(app.Screen.totaleditorcols-PREVIEW_RIGHT_PADDING)
+}
+
+// imageColumns is the width of an image in a preview maxCols wide
+func imageColumns(maxCols int) int {
+	cols := maxCols - 2
+	if cols <= 0 || cols > app.imageScale {
+		cols = app.imageScale
+	}
+	return cols
+}
+
+func (p *cellProtocol) transmit(prep *preparedImage, attrs imageAttrs, maxCols int) (uint32, int, int) {
+	if prep == nil || prep.err != nil || prep.imgW == 0 || prep.imgH == 0 {
+		return 0, 0, 0
+	}
+	cellW, cellH := p.cellSize()
+	cols, rows := imageCells(prep.imgW, prep.imgH, attrs, maxCols, float64(cellW)/float64(cellH))
+
+	key := imageKey(prep.url, attrs)
+	cellImageMux.Lock()
+	id, ok := cellImageIDs[key]
+	if !ok {
+		id = cellImageNext
+		cellImageNext++
+		cellImageIDs[key] = id
+	}
+	cellImageUse++
+	ci := cellImages[id]
+	if ci != nil && ci.cols == cols && ci.rows == rows && ci.fingerprint == prep.cachedFingerprint {
+		ci.used = cellImageUse
+		cellImageMux.Unlock()
+		return id, cols, rows
+	}
+	cellImageMux.Unlock()
+
+	img, err := imaging.Decode(bytes.NewReader(prep.data))
+	if err != nil {
+		return 0, 0, 0
+	}
+	scaled := imaging.Resize(img, cols*cellW, rows*cellH, imaging.Lanczos)
+	ci = &cellImage{
+		url:         prep.url,
+		fingerprint: prep.cachedFingerprint,
+		img:         p.prepare(scaled),
+		cols:        cols,
+		rows:        rows,
+		fit:         imageColumns(maxCols),
+	}
+	cellImageMux.Lock()
+	ci.used = cellImageUse
+	cellImages[id] = ci
+	if len(cellImages) > maxCellImages {
+		var oldest uint32
+		for k, c := range cellImages {
+			if oldest == 0 || c.used < cellImages[oldest].used {
+				oldest = k
+			}
+		}
+		delete(cellImages, oldest)
+	}
+	cellImageMux.Unlock()
+	return id, cols, rows
+}
+
+// placeholder reserves rows blank rows, each starting with an image token
+func (p *cellProtocol) placeholder(id uint32, cols, rows int) string {
+	var b strings.Builder
+	for r := 0; r < rows; r++ {
+		fmt.Fprintf(&b, "\x1b_vimango;img=%d;row=%d\x1b\\\n", id, r)
+	}
+	return b.String()
+}
+
+// imageToken finds the token that starts a row reserved for an image
+var imageToken = regexp.MustCompile(`\x1b_vimango;img=(\d+);row=(\d+)\x1b\\`)
+
+// imageRun is consecutive rows of an image on screen
+type imageRun struct {
+	id       uint32
+	line     int // the preview line on screen the run starts on
+	col      int // the column the image starts at
+	top, end int // the image's rows [top, end) in the run
+}
+
+// splitImageRows removes the image tokens from lines and returns the runs
+// of image rows they marked
+func splitImageRows(lines []string) ([]string, []imageRun) {
+	var runs []imageRun
+	out := make([]string, len(lines))
+	copy(out, lines)
+	for i, line := range lines {
+		loc := imageToken.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+		id, _ := strconv.ParseUint(line[loc[2]:loc[3]], 10, 32)
+		row, _ := strconv.Atoi(line[loc[4]:loc[5]])
+		out[i] = line[:loc[0]] + line[loc[1]:]
+		if n := len(runs); n > 0 && runs[n-1].id == uint32(id) && runs[n-1].end == row &&
+			runs[n-1].line+row-runs[n-1].top == i {
+			runs[n-1].end++
+			continue
+		}
+		runs = append(runs, imageRun{
+			id:   uint32(id),
+			line: i,
+			col:  visibleWidth(line[:loc[0]]),
+			top:  row,
+			end:  row + 1,
+		})
+	}
+	return out, runs
+}
+
+// drawImageRuns draws the image rows in runs; the first row of the preview
+// is on screen row top and its first column is screen column left
+func drawImageRuns(runs []imageRun, top, left int) {
+	p, ok := app.imageProtocol.(*cellProtocol)
+	if !ok || len(runs) == 0 {
+		return
+	}
+	var b strings.Builder
+	for _, run := range runs {
+		cellImageMux.Lock()
+		ci := cellImages[run.id]
+		cellImageMux.Unlock()
+		if ci == nil || run.end > ci.rows {
+			continue
+		}
+		fmt.Fprintf(&b, "\x1b[%d;%dH", top+run.line, left+run.col)
+		b.WriteString(p.crop(ci, run.top, run.end))
+	}
+	b.WriteString("\x1b[0m")
+	os.Stdout.WriteString(b.String())
+}
+
+// crop returns the encoding of rows [top, end) of an image
+func (p *cellProtocol) crop(ci *cellImage, top, end int) string {
+	if top == 0 && end == ci.rows {
+		if ci.full == "" {
+			ci.full = p.encode(ci.img, ci.cols, ci.rows)
+		}
+		return ci.full
+	}
+	b := ci.img.Bounds()
+	y0 := b.Min.Y + b.Dy()*top/ci.rows
+	y1 := b.Min.Y + b.Dy()*end/ci.rows
+	sub, ok := ci.img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return ""
+	}
+	return p.encode(sub.SubImage(image.Rect(b.Min.X, y0, b.Max.X, y1)), ci.cols, end-top)
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// queryTerminal writes queries followed by a primary device attributes
+// request (DA1) and returns the terminal's replies up to its answer to DA1.
+// Terminals answer in order and all of them answer DA1, so a query the
+// terminal doesn't know just goes unanswered.
+func queryTerminal(queries string, timeout time.Duration) ([]byte, error) {
+	if _, err := os.Stdout.WriteString(queries + "\x1b[c"); err != nil {
+		return nil, err
+	}
+	fd := int(os.Stdin.Fd())
+	deadline := time.Now().Add(timeout)
+	var reply []byte
+	buf := make([]byte, 256)
+	for !da1Reply.Match(reply) {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return reply, errors.New("the terminal didn't answer")
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(wait/time.Millisecond)+1)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return reply, err
+		}
+		n, err = unix.Read(fd, buf)
+		if err != nil {
+			return reply, err
+		}
+		reply = append(reply, buf[:n]...)
+	}
+	return reply, nil
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// queryTerminal isn't supported on Windows, where the preview only uses an
+// image protocol that is chosen in config.json
+func queryTerminal(queries string, timeout time.Duration) ([]byte, error) {
+	return nil, errors.New("terminal queries aren't supported on Windows")
+}
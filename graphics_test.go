@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitImageRows(t *testing.T) {
+	p := &cellProtocol{}
+	image7 := strings.Split(strings.TrimSuffix(p.placeholder(7, 3, 3), "\n"), "\n")
+	image9 := strings.Split(strings.TrimSuffix(p.placeholder(9, 2, 2), "\n"), "\n")
+
+	tests := []struct {
+		name     string
+		lines    []string
+		wantText []string
+		wantRuns []imageRun
+	}{
+		{
+			name:     "no images",
+			lines:    []string{"one", "two"},
+			wantText: []string{"one", "two"},
+		},
+		{
+			name:     "indented image between text",
+			lines:    []string{"text", "  " + image7[0], "  " + image7[1], "  " + image7[2], "more"},
+			wantText: []string{"text", "  ", "  ", "  ", "more"},
+			wantRuns: []imageRun{{id: 7, line: 1, col: 2, top: 0, end: 3}},
+		},
+		{
+			name:     "image scrolled partly off the top",
+			lines:    []string{image7[1], image7[2], image9[0], image9[1]},
+			wantText: []string{"", "", "", ""},
+			wantRuns: []imageRun{
+				{id: 7, line: 0, col: 0, top: 1, end: 3},
+				{id: 9, line: 2, col: 0, top: 0, end: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		text, runs := splitImageRows(tt.lines)
+		if !reflect.DeepEqual(text, tt.wantText) || !reflect.DeepEqual(runs, tt.wantRuns) {
+			t.Errorf("%s: splitImageRows = %q, %+v, want %q, %+v", tt.name, text, runs, tt.wantText, tt.wantRuns)
+		}
+	}
+}
+
+func TestCellProtocolCrop(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	for _, colors := range []int{0, 16, 256, 1024} {
+		p := &cellProtocol{cellEncoder: sixelEncoder{colors: colors}}
+		ci := &cellImage{img: p.prepare(testImage(10, 12)), cols: 1, rows: 2}
+
+		full := p.crop(ci, 0, 2)
+		if full == "" || ci.full != full {
+			t.Errorf("colors %d: crop of the whole image isn't kept", colors)
+		}
+		if !strings.Contains(full, "\"1;1;10;12") {
+			t.Errorf("colors %d: crop of the whole image = %.30q..., want a 10x12 image", colors, full)
+		}
+		if lower := p.crop(ci, 1, 2); !strings.Contains(lower, "\"1;1;10;6") {
+			t.Errorf("colors %d: crop of the second row = %.30q..., want a 10x6 image", colors, lower)
+		}
+	}
+}
+
+func TestSetImageProtocolKeepsDetection(t *testing.T) {
+	t.Setenv("TERM_PROGRAM", "")
+	t.Setenv("LC_TERMINAL", "")
+	a := &App{}
+	if err := a.setImageProtocol("kitty"); err != nil || !a.kitty || !a.kittyPlace {
+		t.Fatalf("setImageProtocol(kitty) = %v, kitty %v, kittyPlace %v, want nil, true, true", err, a.kitty, a.kittyPlace)
+	}
+	if err := a.setImageProtocol("auto"); err != nil {
+		t.Fatalf("setImageProtocol(auto) = %v", err)
+	}
+	if a.kitty || a.kittyPlace || a.imageProtocol.String() != "blocks" {
+		t.Errorf("auto after kitty on a terminal without kitty = %s, kitty %v, kittyPlace %v, want blocks, false, false",
+			a.imageProtocol, a.kitty, a.kittyPlace)
+	}
+
+	a = &App{graphics: terminalGraphics{kitty: true, kittyPlace: true}}
+	a.setImageProtocol("blocks")
+	a.setImageProtocol("auto")
+	if !a.kitty || a.imageProtocol.String() != "kitty" {
+		t.Errorf("auto on a kitty terminal = %s, kitty %v, want kitty, true", a.imageProtocol, a.kitty)
+	}
+}
@@ -26,10 +26,14 @@ var (
 // Default availability flag - overridden by heic_pillow.go init()
 var isHEICAvailableDefault = false
 
-// GetHEICDecoder returns the global HEIC decoder instance
+// GetHEICDecoder returns the global HEIC decoder instance: the platform's
+// decoder if it is set up, else the WASM decoder
 func GetHEICDecoder() HEICDecoder {
 	globalHEICDecoderOnce.Do(func() {
 		globalHEICDecoder = createHEICDecoder()
+		if !globalHEICDecoder.IsAvailable() {
+			globalHEICDecoder = createWASMHEICDecoder()
+		}
 	})
 	return globalHEICDecoder
 }
@@ -64,6 +68,11 @@ func IsHEICData(data []byte) bool {
 		return false
 	}
 
+	// AVIF shares the mif1 and msf1 brands with HEIC
+	if IsAVIFData(data) {
+		return false
+	}
+
 	// Check brand identifier at offset 8
 	brandBytes := data[8:12]
 	for _, brand := range heicBrands {
@@ -77,7 +86,7 @@ func IsHEICData(data []byte) bool {
 
 // ShowHEICNotAvailableMessage returns a user-friendly message
 func ShowHEICNotAvailableMessage() string {
-	return fmt.Sprintf("%sHEIC format not supported (requires CGO with libheif or .venv with pillow-heif)%s", YELLOW_BG, RESET)
+	return fmt.Sprintf("%sHEIC format not supported (the HEIC decoder failed to load)%s", YELLOW_BG, RESET)
 }
 
 // StubHEICDecoder provides a no-op implementation for platforms without HEIC support
@@ -4,7 +4,8 @@ package main
 
 // No init function needed - isHEICAvailableDefault remains false
 
-// createHEICDecoder provides a stub implementation when CGO is not available
+// createHEICDecoder provides the WASM decoder since there is no libheif
+// worker or pillow-heif fallback on Windows
 func createHEICDecoder() HEICDecoder {
-	return createStubHEICDecoder()
+	return createWASMHEICDecoder()
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+	_ "golang.org/x/image/webp" // registers WebP with image.Decode
+)
+
+// WASMHEICDecoder decodes HEIC with libheif and libde265 compiled to WASM
+// and run by wazero, so it needs neither CGO nor Python. It uses a shared
+// libheif through purego instead if one is installed. It is the decoder of
+// builds that have no other and the fallback when the CGO worker or
+// pillow-heif isn't set up.
+type WASMHEICDecoder struct{}
+
+func createWASMHEICDecoder() HEICDecoder {
+	return &WASMHEICDecoder{}
+}
+
+func (d *WASMHEICDecoder) IsAvailable() bool {
+	return true
+}
+
+func (d *WASMHEICDecoder) Decode(r io.Reader) (image.Image, error) {
+	img, err := heic.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("WASM HEIC decoder: %v", err)
+	}
+	return img, nil
+}
+
+// avifBrands are the ftyp brands of AVIF images and sequences
+var avifBrands = [][]byte{
+	[]byte("avif"),
+	[]byte("avis"),
+}
+
+// IsAVIFData checks if the given data appears to be AVIF: an ISO base
+// media file whose major brand, or one of whose compatible brands, is AVIF.
+// AVIF files often have the generic HEIF major brand mif1.
+func IsAVIFData(data []byte) bool {
+	if len(data) < 12 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+	size := int(data[0])<<24 | int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if size < 16 || size > len(data) {
+		size = 12
+	}
+	// major brand at 8, minor version at 12, compatible brands from 16
+	for off := 8; off+4 <= size; off += 4 {
+		if off == 12 {
+			continue
+		}
+		for _, brand := range avifBrands {
+			if bytes.Equal(data[off:off+4], brand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeAVIF decodes an AVIF image with libavif and dav1d compiled to WASM
+// (or a shared libavif through purego)
+func decodeAVIF(data []byte) (image.Image, error) {
+	img, err := avif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("AVIF decode failed: %v", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"html"
+	"image"
+	"image/draw"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// An image in a note can be followed by attributes in braces that size and
+// place it, e.g. ![plot](gdrive:ID){width=20} or ![](attach:...){cols=60
+// rows=15 align=center}. cols (or width) is the image's width in preview
+// columns and rows (or height) its height in preview rows; given both, the
+// image is fit inside cols x rows. Without them the image is :imagescale
+// columns wide. align is left, center or right. The webview reads a column
+// as 1ch and the PDF exports as pdfColumnWidth points.
+
+// imageAttrs are the attributes of an image
+type imageAttrs struct {
+	cols, rows int
+	align      string // "", left, center or right
+}
+
+// sized reports whether the attributes change an image's size
+func (a imageAttrs) sized() bool { return a.cols > 0 || a.rows > 0 }
+
+// markdownImage is an image in a note
+type markdownImage struct {
+	url   string
+	attrs imageAttrs
+}
+
+// markdownImageAttrsRegex matches a markdown image and the braces after it
+var markdownImageAttrsRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)(\{[^}\n]*\})?`)
+
+// parseImageAttrs parses "{width=20 align=center}"; ok is false if the
+// braces hold anything that isn't an image attribute
+func parseImageAttrs(s string) (imageAttrs, bool) {
+	var a imageAttrs
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+	if len(fields) == 0 {
+		return a, false
+	}
+	for _, f := range fields {
+		key, value, found := strings.Cut(f, "=")
+		if !found {
+			return a, false
+		}
+		value = strings.Trim(value, `"'`)
+		switch strings.ToLower(key) {
+		case "width", "cols":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return a, false
+			}
+			a.cols = n
+		case "height", "rows":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return a, false
+			}
+			a.rows = n
+		case "align":
+			switch value = strings.ToLower(value); value {
+			case "left", "center", "right":
+				a.align = value
+			default:
+				return a, false
+			}
+		default:
+			return a, false
+		}
+	}
+	return a, true
+}
+
+// extractImages returns the images in markdown and their attributes
+func extractImages(markdown string) []markdownImage {
+	matches := markdownImageAttrsRegex.FindAllStringSubmatch(markdown, -1)
+	images := make([]markdownImage, 0, len(matches))
+	for _, m := range matches {
+		img := markdownImage{url: m[2]}
+		if m[3] != "" {
+			img.attrs, _ = parseImageAttrs(m[3])
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+// stripImageAttrs removes image attributes so renderers that don't know
+// them don't show them as text
+func stripImageAttrs(markdown string) string {
+	return replaceImageAttrs(markdown, func(alt, url string, _ imageAttrs) string {
+		return fmt.Sprintf("![%s](%s)", alt, url)
+	})
+}
+
+// replaceImageAttrs replaces each image that has attributes with repl's
+// result
+func replaceImageAttrs(markdown string, repl func(alt, url string, attrs imageAttrs) string) string {
+	return markdownImageAttrsRegex.ReplaceAllStringFunc(markdown, func(match string) string {
+		m := markdownImageAttrsRegex.FindStringSubmatch(match)
+		if m[3] == "" {
+			return match
+		}
+		attrs, ok := parseImageAttrs(m[3])
+		if !ok {
+			return match
+		}
+		return repl(m[1], m[2], attrs)
+	})
+}
+
+// imageKey names an image at the size its attributes give it
+func imageKey(url string, attrs imageAttrs) string {
+	if !attrs.sized() {
+		return url
+	}
+	return fmt.Sprintf("%s{cols=%d,rows=%d}", url, attrs.cols, attrs.rows)
+}
+
+// imageCells is the size in cells of an imgW x imgH image in a preview
+// maxCols wide; a cell is cellAspect as wide as it is tall
+func imageCells(imgW, imgH int, attrs imageAttrs, maxCols int, cellAspect float64) (int, int) {
+	ratio := float64(imgH) / float64(imgW) * cellAspect // rows per column
+	cols, rows := attrs.cols, 0
+	if !attrs.sized() {
+		cols = imageColumns(maxCols)
+	}
+	if cols > 0 {
+		rows = int(float64(cols) * ratio)
+	}
+	if attrs.rows > 0 && (cols == 0 || rows > attrs.rows) {
+		rows = attrs.rows
+		cols = int(float64(rows)/ratio + 0.5)
+	}
+	if limit := maxCols - 2; limit > 0 && cols > limit {
+		cols = limit
+		rows = int(float64(cols) * ratio)
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return cols, rows
+}
+
+// alignImage indents each line of an image's placeholder to align an image
+// cols wide in a preview width columns wide
+func alignImage(placeholder string, cols int, align string, width int) string {
+	var pad int
+	switch align {
+	case "center":
+		pad = (width - cols) / 2
+	case "right":
+		pad = width - cols
+	}
+	if pad <= 0 {
+		return placeholder
+	}
+	indent := strings.Repeat(" ", pad)
+	lines := strings.Split(placeholder, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// imageAttrsToHTML replaces images that have attributes with img tags
+// styled to match, for the webview
+func imageAttrsToHTML(markdown string) string {
+	return replaceImageAttrs(markdown, func(alt, url string, attrs imageAttrs) string {
+		var style []string
+		if attrs.cols > 0 {
+			style = append(style, fmt.Sprintf("width:%dch", attrs.cols))
+		}
+		if attrs.rows > 0 {
+			// a row is about twice as tall as a column is wide
+			if attrs.cols > 0 {
+				style = append(style, fmt.Sprintf("max-height:%dch", 2*attrs.rows), "object-fit:contain")
+			} else {
+				style = append(style, fmt.Sprintf("height:%dch", 2*attrs.rows))
+			}
+		}
+		switch attrs.align {
+		case "left":
+			style = append(style, "display:block", "margin-right:auto")
+		case "center":
+			style = append(style, "display:block", "margin-left:auto", "margin-right:auto")
+		case "right":
+			style = append(style, "display:block", "margin-left:auto")
+		}
+		return fmt.Sprintf(`<img src="%s" alt="%s" style="%s">`,
+			html.EscapeString(url), html.EscapeString(alt), strings.Join(style, ";"))
+	})
+}
+
+const (
+	pdfColumnWidth = 6.0   // points in a preview column
+	pdfTextWidth   = 540.0 // points between the page margins
+	pdfImageDPI    = 200   // resolution of sized images
+)
+
+// imageAttrsForPDF replaces images that have attributes with data URIs of
+// PNGs the width of the page's text with the image drawn at its size and
+// alignment, since the PDF renderers draw images at their own size (mdtopdf
+// from the PNG's resolution, goldmark-pdf the width of the page)
+func imageAttrsForPDF(markdown string) string {
+	return replaceImageAttrs(markdown, func(alt, url string, attrs imageAttrs) string {
+		data, err := pdfSizedImage(url, attrs)
+		if err != nil {
+			return fmt.Sprintf("![%s](%s)", alt, url)
+		}
+		return fmt.Sprintf("![%s](data:image/png;base64,%s)", alt, base64.StdEncoding.EncodeToString(data))
+	})
+}
+
+// pdfSizedImage draws the image at url on a canvas the width of the page's
+// text and returns it as a PNG whose resolution makes it that wide
+func pdfSizedImage(url string, attrs imageAttrs) ([]byte, error) {
+	img, err := loadImageForGlamour(url)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	textCols := int(pdfTextWidth / pdfColumnWidth)
+	cols, rows := imageCells(b.Dx(), b.Dy(), attrs, textCols+2, 0.5)
+
+	pixels := func(points float64) int { return int(points * pdfImageDPI / 72) }
+	w, h := pixels(float64(cols)*pdfColumnWidth), pixels(float64(rows)*2*pdfColumnWidth)
+	canvasW := pixels(pdfTextWidth)
+	var x int
+	switch attrs.align {
+	case "center":
+		x = (canvasW - w) / 2
+	case "right":
+		x = canvasW - w
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, canvasW, h))
+	draw.Draw(canvas, image.Rect(x, 0, x+w, h), imaging.Resize(img, w, h, imaging.Lanczos), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, err
+	}
+	return withPNGResolution(buf.Bytes(), pdfImageDPI), nil
+}
+
+// withPNGResolution adds a pHYs chunk giving a PNG's resolution in dots
+// per inch after its IHDR chunk
+func withPNGResolution(data []byte, dpi int) []byte {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4 // signature, length, type, data, crc
+	if len(data) < ihdrEnd {
+		return data
+	}
+	ppm := uint32(float64(dpi)/0.0254 + 0.5)
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk, 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1 // pixels per meter
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"testing"
+)
+
+func TestParseImageAttrs(t *testing.T) {
+	tests := []struct {
+		s    string
+		want imageAttrs
+		ok   bool
+	}{
+		{"{width=20}", imageAttrs{cols: 20}, true},
+		{"{cols=60 rows=15 align=center}", imageAttrs{cols: 60, rows: 15, align: "center"}, true},
+		{"{height=8, align=Right}", imageAttrs{rows: 8, align: "right"}, true},
+		{`{width="30"	ALIGN='left'}`, imageAttrs{cols: 30, align: "left"}, true},
+		{"{}", imageAttrs{}, false},
+		{"{width}", imageAttrs{}, false},
+		{"{width=0}", imageAttrs{}, false},
+		{"{rows=-2}", imageAttrs{}, false},
+		{"{width=abc}", imageAttrs{}, false},
+		{"{align=top}", imageAttrs{}, false},
+		{"{width=20 size=3}", imageAttrs{cols: 20}, false},
+		{"{.class}", imageAttrs{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseImageAttrs(tt.s)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseImageAttrs(%q) = %+v, %v, want %+v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestImageCells(t *testing.T) {
+	saved := app
+	t.Cleanup(func() { app = saved })
+	app = &App{imageScale: 45}
+
+	tests := []struct {
+		imgW, imgH         int
+		attrs              imageAttrs
+		maxCols            int
+		wantCols, wantRows int
+	}{
+		{100, 50, imageAttrs{}, 80, 45, 11},                 // :imagescale wide
+		{100, 50, imageAttrs{}, 10, 8, 2},                   // the preview is narrower
+		{100, 50, imageAttrs{cols: 20}, 80, 20, 5},          // cols
+		{100, 50, imageAttrs{rows: 4}, 80, 16, 4},           // rows
+		{100, 50, imageAttrs{cols: 40, rows: 4}, 80, 16, 4}, // fit inside both
+		{100, 50, imageAttrs{cols: 40, rows: 20}, 80, 40, 10},
+		{100, 50, imageAttrs{cols: 100}, 50, 48, 12}, // wider than the preview
+		{1000, 1, imageAttrs{cols: 20}, 80, 20, 1},   // at least a row
+	}
+	for _, tt := range tests {
+		cols, rows := imageCells(tt.imgW, tt.imgH, tt.attrs, tt.maxCols, 0.5)
+		if cols != tt.wantCols || rows != tt.wantRows {
+			t.Errorf("imageCells(%d, %d, %+v, %d) = %d, %d, want %d, %d",
+				tt.imgW, tt.imgH, tt.attrs, tt.maxCols, cols, rows, tt.wantCols, tt.wantRows)
+		}
+	}
+}
+
+func TestWithPNGResolution(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(4, 3)); err != nil {
+		t.Fatal(err)
+	}
+	data := withPNGResolution(buf.Bytes(), 200)
+
+	if len(data) != buf.Len()+21 {
+		t.Fatalf("withPNGResolution added %d bytes, want 21", len(data)-buf.Len())
+	}
+	chunk := data[33:54]
+	if string(chunk[4:8]) != "pHYs" || binary.BigEndian.Uint32(chunk) != 9 {
+		t.Fatalf("chunk after IHDR = %q, want a pHYs chunk", chunk[:8])
+	}
+	// 200 dpi is 7874 pixels per meter
+	if x, y := binary.BigEndian.Uint32(chunk[8:]), binary.BigEndian.Uint32(chunk[12:]); x != 7874 || y != 7874 || chunk[16] != 1 {
+		t.Errorf("pHYs = %d x %d unit %d, want 7874 x 7874 unit 1", x, y, chunk[16])
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode after withPNGResolution: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 3 {
+		t.Errorf("decoded image is %dx%d, want 4x3", b.Dx(), b.Dy())
+	}
+
+	short := []byte("\x89PNG")
+	if got := withPNGResolution(short, 200); !bytes.Equal(got, short) {
+		t.Errorf("withPNGResolution(%q) = %q, want it unchanged", short, got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -38,13 +39,33 @@ type CacheIndex struct {
 	KittyWindow string                `json:"kitty_window,omitempty"`
 }
 
-// ImageCache manages the disk-based image cache
+// defaultImageCacheBytes is the size of the disk cache unless
+// images.cache_max_mb in config.json says otherwise
+const defaultImageCacheBytes = 200 << 20
+
+// ImageCache manages the disk-based image cache; when storing an image
+// would take it over maxBytes the least recently used entries are evicted
 type ImageCache struct {
-	cacheDir   string
-	indexFile  string
-	maxEntries int
-	mutex      sync.RWMutex
-	index      CacheIndex
+	cacheDir  string
+	indexFile string
+	maxBytes  int64
+	mutex     sync.RWMutex
+	index     CacheIndex
+	stats     map[string]*cacheSourceStats // lookups this session by imageSource
+}
+
+// cacheSourceStats counts the cache lookups for one kind of image source
+type cacheSourceStats struct {
+	hits, misses int
+}
+
+// CacheSourceStats describes the cached images from one kind of source
+type CacheSourceStats struct {
+	Source  string
+	Entries int
+	Bytes   int64
+	Hits    int
+	Misses  int
 }
 
 // NewImageCache creates a new image cache instance
@@ -54,13 +75,14 @@ func NewImageCache() (*ImageCache, error) {
 	indexFile := filepath.Join(cacheDir, "cache_index.json")
 
 	cache := &ImageCache{
-		cacheDir:   cacheDir,
-		indexFile:  indexFile,
-		maxEntries: 50, // Default to 50 cached images
+		cacheDir:  cacheDir,
+		indexFile: indexFile,
+		maxBytes:  defaultImageCacheBytes,
 		index: CacheIndex{
 			Version: 1,
 			Entries: make(map[string]CacheEntry),
 		},
+		stats: make(map[string]*cacheSourceStats),
 	}
 
 	// Create cache directory if it doesn't exist
@@ -206,13 +228,30 @@ func (c *ImageCache) NextKittyImageID() uint32 {
 	return id
 }
 
+// accessResolution is how stale an entry's LastAccessed may get before a
+// lookup that only reads its metadata updates it and saves the index
+const accessResolution = time.Minute
+
 // GetKittyMeta returns the cache entry (including kitty fields) if present.
+// An image kitty already holds is drawn from its metadata alone, so this
+// counts as an access for eviction.
 func (c *ImageCache) GetKittyMeta(url string) (CacheEntry, bool) {
 	key := c.generateCacheKey(url)
 
 	c.mutex.RLock()
 	entry, exists := c.index.Entries[key]
 	c.mutex.RUnlock()
+
+	if exists && time.Since(entry.LastAccessed) > accessResolution {
+		c.mutex.Lock()
+		if e, ok := c.index.Entries[key]; ok {
+			e.LastAccessed = time.Now()
+			c.index.Entries[key] = e
+			entry = e
+			c.saveIndex() // Best effort, ignore errors
+		}
+		c.mutex.Unlock()
+	}
 	return entry, exists
 }
 
@@ -265,38 +304,141 @@ func (c *ImageCache) UpdateGDriveMeta(url, gdriveName, gdriveFolder string) erro
 	return c.saveIndex()
 }
 
-// evictOldestEntry removes the oldest cache entry (FIFO)
+// evictLeastRecentlyUsed removes the entries accessed longest ago until
+// need more bytes fit under maxBytes
 // Note: Caller must hold write lock
-func (c *ImageCache) evictOldestEntry() error {
-	if len(c.index.Entries) == 0 {
-		return nil
-	}
-
-	// Find oldest entry by creation time
-	var oldestKey string
-	var oldestTime time.Time
-	first := true
-
-	for key, entry := range c.index.Entries {
-		if first || entry.Created.Before(oldestTime) {
-			oldestKey = key
-			oldestTime = entry.Created
-			first = false
+func (c *ImageCache) evictLeastRecentlyUsed(need int64) {
+	var total int64
+	for _, entry := range c.index.Entries {
+		total += entry.SizeBytes
+	}
+
+	for total+need > c.maxBytes && len(c.index.Entries) > 0 {
+		var oldestKey string
+		var oldestTime time.Time
+		first := true
+		for key, entry := range c.index.Entries {
+			if first || entry.LastAccessed.Before(oldestTime) {
+				oldestKey = key
+				oldestTime = entry.LastAccessed
+				first = false
+			}
 		}
+		total -= c.index.Entries[oldestKey].SizeBytes
+		c.removeEntry(oldestKey)
 	}
+}
 
-	// Remove cache file
-	if entry, exists := c.index.Entries[oldestKey]; exists {
+// removeEntry deletes an entry's cache file and index entry
+// Note: Caller must hold write lock
+func (c *ImageCache) removeEntry(key string) {
+	if entry, exists := c.index.Entries[key]; exists {
 		cacheFile := filepath.Join(c.cacheDir, entry.Filename)
 		if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
 			// Silently ignore removal errors (avoid printing to stdout which interferes with TUI)
 		}
 	}
+	delete(c.index.Entries, key)
+}
 
-	// Remove from index
-	delete(c.index.Entries, oldestKey)
+// SetMaxBytes changes the size of the cache, evicting entries if it is
+// now over it
+func (c *ImageCache) SetMaxBytes(maxBytes int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	return nil
+	c.maxBytes = maxBytes
+	c.evictLeastRecentlyUsed(0)
+	_ = c.saveIndex()
+}
+
+// CollectGarbage removes the entries for images that none of urls (the
+// images the notes still reference) refer to and returns how many entries
+// and bytes it removed
+func (c *ImageCache) CollectGarbage(urls []string) (int, int64, error) {
+	referenced := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		referenced[c.generateCacheKey(url)] = true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var removed int
+	var freed int64
+	for key, entry := range c.index.Entries {
+		if referenced[key] {
+			continue
+		}
+		freed += entry.SizeBytes
+		removed++
+		c.removeEntry(key)
+	}
+
+	return removed, freed, c.saveIndex()
+}
+
+// imageSource classifies an image url for cache statistics
+func imageSource(url string) string {
+	switch {
+	case strings.HasPrefix(url, "gdrive:") || strings.Contains(url, "drive.google.com"):
+		return "gdrive"
+	case strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"):
+		return "http"
+	default:
+		return "file"
+	}
+}
+
+// recordLookup counts a cache hit or miss for url
+// Note: Caller must hold write lock
+func (c *ImageCache) recordLookup(url string, hit bool) {
+	source := imageSource(url)
+	st := c.stats[source]
+	if st == nil {
+		st = &cacheSourceStats{}
+		c.stats[source] = st
+	}
+	if hit {
+		st.hits++
+	} else {
+		st.misses++
+	}
+}
+
+// SourceStats returns the entries, bytes, hits and misses of the cache by
+// image source
+func (c *ImageCache) SourceStats() []CacheSourceStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	bySource := make(map[string]*CacheSourceStats)
+	get := func(source string) *CacheSourceStats {
+		st := bySource[source]
+		if st == nil {
+			st = &CacheSourceStats{Source: source}
+			bySource[source] = st
+		}
+		return st
+	}
+	for _, entry := range c.index.Entries {
+		st := get(imageSource(entry.URL))
+		st.Entries++
+		st.Bytes += entry.SizeBytes
+	}
+	for source, lookups := range c.stats {
+		st := get(source)
+		st.Hits = lookups.hits
+		st.Misses = lookups.misses
+	}
+
+	var stats []CacheSourceStats
+	for _, source := range []string{"gdrive", "http", "file"} {
+		if st := bySource[source]; st != nil {
+			stats = append(stats, *st)
+		}
+	}
+	return stats
 }
 
 // InvalidateCacheEntry removes a specific cache entry by URL, forcing re-download on next access.
@@ -307,19 +449,12 @@ func (c *ImageCache) InvalidateCacheEntry(url string) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	entry, exists := c.index.Entries[key]
-	if !exists {
+	if _, exists := c.index.Entries[key]; !exists {
 		return nil // Already not in cache
 	}
 
-	// Remove cache file
-	cacheFile := filepath.Join(c.cacheDir, entry.Filename)
-	if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
-		// Continue anyway - we still want to remove from index
-	}
-
-	// Remove from index
-	delete(c.index.Entries, key)
+	// Remove cache file and index entry
+	c.removeEntry(key)
 
 	return c.saveIndex()
 }
@@ -361,6 +496,9 @@ func (c *ImageCache) GetCachedImageData(url string) (string, int, int, bool) {
 	c.mutex.RUnlock()
 
 	if !exists {
+		c.mutex.Lock()
+		c.recordLookup(url, false)
+		c.mutex.Unlock()
 		return "", 0, 0, false
 	}
 
@@ -371,6 +509,7 @@ func (c *ImageCache) GetCachedImageData(url string) (string, int, int, bool) {
 		// File missing or unreadable - remove from index
 		c.mutex.Lock()
 		delete(c.index.Entries, key)
+		c.recordLookup(url, false)
 		c.saveIndex() // Best effort, ignore errors
 		c.mutex.Unlock()
 		return "", 0, 0, false
@@ -378,6 +517,7 @@ func (c *ImageCache) GetCachedImageData(url string) (string, int, int, bool) {
 
 	// Update last accessed time
 	c.mutex.Lock()
+	c.recordLookup(url, true)
 	entry.LastAccessed = time.Now()
 	c.index.Entries[key] = entry
 	c.saveIndex() // Best effort, ignore errors
@@ -416,13 +556,10 @@ func (c *ImageCache) StoreCachedImageData(url, base64Data string, width, height
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// Check if we need to evict entries before adding new one
-	if len(c.index.Entries) >= c.maxEntries {
-		if err := c.evictOldestEntry(); err != nil {
-			// Continue anyway - better to have oversized cache than fail
-			// (avoid printing to stdout which interferes with TUI)
-		}
-	}
+	// Evict least recently used entries so the new one fits; an entry being
+	// replaced is dropped first since evicting it would remove the new file
+	delete(c.index.Entries, key)
+	c.evictLeastRecentlyUsed(fileInfo.Size())
 
 	// Normalize URL to gdrive: format before storing
 	normalizedURL := url
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newTestImageCache returns a cache in a temporary directory holding a
+// size byte file for each url, accessed the given time ago
+func newTestImageCache(t *testing.T, maxBytes int64, urls []string, size int64, ago []time.Duration) *ImageCache {
+	t.Helper()
+	dir := t.TempDir()
+	c := &ImageCache{
+		cacheDir:  dir,
+		indexFile: filepath.Join(dir, "cache_index.json"),
+		maxBytes:  maxBytes,
+		index:     CacheIndex{Version: 1, Entries: make(map[string]CacheEntry)},
+		stats:     make(map[string]*cacheSourceStats),
+	}
+	for i, url := range urls {
+		key := c.generateCacheKey(url)
+		if err := os.WriteFile(filepath.Join(dir, key+".b64"), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c.index.Entries[key] = CacheEntry{
+			URL:          url,
+			Filename:     key + ".b64",
+			LastAccessed: time.Now().Add(-ago[i]),
+			SizeBytes:    size,
+		}
+	}
+	return c
+}
+
+// cachedURLs returns the urls in the cache's index, sorted
+func cachedURLs(c *ImageCache) []string {
+	var urls []string
+	for _, entry := range c.index.Entries {
+		urls = append(urls, entry.URL)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	urls := []string{"a.png", "b.png", "c.png", "d.png"}
+	ago := []time.Duration{3 * time.Hour, time.Hour, 4 * time.Hour, 2 * time.Hour}
+	tests := []struct {
+		maxBytes, need int64
+		want           []string
+	}{
+		{400, 0, []string{"a.png", "b.png", "c.png", "d.png"}},
+		{400, 100, []string{"a.png", "b.png", "d.png"}},
+		{400, 150, []string{"b.png", "d.png"}},
+		{250, 0, []string{"b.png", "d.png"}},
+		{400, 500, nil},
+	}
+	for _, tt := range tests {
+		c := newTestImageCache(t, tt.maxBytes, urls, 100, ago)
+		c.evictLeastRecentlyUsed(tt.need)
+		got := cachedURLs(c)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("max %d need %d: kept %q, want %q", tt.maxBytes, tt.need, got, tt.want)
+		}
+		for _, url := range urls {
+			key := c.generateCacheKey(url)
+			_, kept := c.index.Entries[key]
+			if _, err := os.Stat(filepath.Join(c.cacheDir, key+".b64")); (err == nil) != kept {
+				t.Errorf("max %d need %d: %s file exists %v, indexed %v", tt.maxBytes, tt.need, url, err == nil, kept)
+			}
+		}
+	}
+}
+
+func TestKittyMetaCountsAsAccess(t *testing.T) {
+	urls := []string{"viewed.png", "stored.png"}
+	c := newTestImageCache(t, 200, urls, 100, []time.Duration{2 * time.Hour, time.Hour})
+	if _, ok := c.GetKittyMeta("viewed.png"); !ok {
+		t.Fatal("GetKittyMeta found no entry")
+	}
+	c.evictLeastRecentlyUsed(100)
+	if got := cachedURLs(c); !reflect.DeepEqual(got, []string{"viewed.png"}) {
+		t.Errorf("after viewing viewed.png eviction kept %q, want [viewed.png]", got)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	urls := []string{"a.png", "gdrive:abc", "c.png"}
+	c := newTestImageCache(t, defaultImageCacheBytes, urls, 100, []time.Duration{0, 0, 0})
+
+	removed, freed, err := c.CollectGarbage([]string{"a.png", "gdrive:abc", "new.png"})
+	if err != nil || removed != 1 || freed != 100 {
+		t.Errorf("CollectGarbage = %d, %d, %v, want 1, 100, nil", removed, freed, err)
+	}
+	if got := cachedURLs(c); !reflect.DeepEqual(got, []string{"a.png", "gdrive:abc"}) {
+		t.Errorf("CollectGarbage kept %q, want [a.png gdrive:abc]", got)
+	}
+	if _, err := os.Stat(filepath.Join(c.cacheDir, c.generateCacheKey("c.png")+".b64")); !os.IsNotExist(err) {
+		t.Errorf("c.png's cache file is still there (%v)", err)
+	}
+	if _, err := os.Stat(c.indexFile); err != nil {
+		t.Errorf("CollectGarbage didn't save the index: %v", err)
+	}
+
+	removed, freed, err = c.CollectGarbage(nil)
+	if err != nil || removed != 2 || freed != 200 || len(c.index.Entries) != 0 {
+		t.Errorf("CollectGarbage(nil) = %d, %d, %v leaving %d entries, want 2, 200, nil leaving 0",
+			removed, freed, err, len(c.index.Entries))
+	}
+}
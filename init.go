@@ -102,6 +102,54 @@ CREATE TABLE sync_log (
 	note TEXT,
 	PRIMARY KEY (id)
 );
+` + undoHistorySchema + recoverySchema + attachmentSchema
+
+// Schema for the local-only per-note undo history
+const undoHistorySchema = `
+CREATE TABLE IF NOT EXISTS undo_history (
+	task_id INTEGER NOT NULL,
+	engine TEXT NOT NULL,
+	history BLOB NOT NULL,
+	modified TEXT DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (task_id),
+	FOREIGN KEY(task_id) REFERENCES task (id) ON DELETE CASCADE
+);
+`
+
+// Schema for the local-only autosaved text of notes with unsaved changes
+const recoverySchema = `
+CREATE TABLE IF NOT EXISTS recovery (
+	task_id INTEGER NOT NULL,
+	note TEXT NOT NULL,
+	modified TEXT DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (task_id),
+	FOREIGN KEY(task_id) REFERENCES task (id) ON DELETE CASCADE
+);
+`
+
+// Schema for files attached to notes, which are referenced as attach:<uuid>
+// and synchronized; attachments are never changed so created is all sync needs
+const attachmentSchema = `
+CREATE TABLE IF NOT EXISTS attachment (
+	id INTEGER NOT NULL,
+	uuid TEXT NOT NULL UNIQUE,
+	mime TEXT NOT NULL,
+	bytes BLOB NOT NULL,
+	hash TEXT NOT NULL,
+	created TEXT DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id)
+);
+`
+
+// Schema for the attachment table on the postgres server
+const pgAttachmentSchema = `
+CREATE TABLE IF NOT EXISTS attachment (
+	uuid TEXT NOT NULL PRIMARY KEY,
+	mime TEXT NOT NULL,
+	bytes BYTEA NOT NULL,
+	hash TEXT NOT NULL,
+	created TIMESTAMP WITHOUT TIME ZONE DEFAULT now()
+);
 `
 
 // generateUUID generates a new UUID string
@@ -165,11 +213,13 @@ func runInit() {
 			FTS_DB: "fts5_vimango.db",
 		},
 		Options: struct {
-			Type  string `json:"type"`
-			Title string `json:"title"`
+			Type     string `json:"type"`
+			Title    string `json:"title"`
+			Autosave int    `json:"autosave"`
 		}{
-			Type:  "folder",
-			Title: "none",
+			Type:     "folder",
+			Title:    "none",
+			Autosave: defaultAutosaveSeconds,
 		},
 		Chroma: struct {
 			Style string `json:"style"`
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// iterm2Encoder draws images with iTerm2's inline image sequence
+// (OSC 1337 File), which WezTerm, mintty and others also support
+type iterm2Encoder struct{}
+
+func (iterm2Encoder) String() string { return "iterm2" }
+
+func (iterm2Encoder) prepare(img *image.NRGBA) image.Image { return img }
+
+// encode sends img as a PNG the terminal stretches over cols x rows cells
+func (iterm2Encoder) encode(img image.Image, cols, rows int) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	opn := fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=0;doNotMoveCursor=1:",
+		buf.Len(), cols, rows)
+	cls := "\a"
+	if IsTmuxScreen() {
+		opn, cls = TmuxOscOpenClose(opn, cls)
+	}
+	return opn + base64.StdEncoding.EncodeToString(buf.Bytes()) + cls
+}
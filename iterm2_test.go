@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestITerm2Encode(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	enc := iterm2Encoder{}
+	img := enc.prepare(testImage(4, 2))
+	s := enc.encode(img, 3, 1)
+
+	head, data, ok := strings.Cut(s, ":")
+	if !ok || !strings.HasSuffix(data, "\a") {
+		t.Fatalf("encode = %q, want an OSC 1337 sequence", s)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(data, "\a"))
+	if err != nil {
+		t.Fatalf("the image isn't base64: %v", err)
+	}
+	want := fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=3;height=1;preserveAspectRatio=0;doNotMoveCursor=1", len(raw))
+	if head != want {
+		t.Errorf("encode header = %q, want %q", head, want)
+	}
+	decoded, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("the image isn't a PNG: %v", err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Errorf("the PNG is %dx%d, want 4x2", b.Dx(), b.Dy())
+	}
+}
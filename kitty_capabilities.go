@@ -85,9 +85,7 @@ func (a *App) DetectKittyCapabilities() {
 	kittySessionImageMux.Lock()
 	kittySessionImages = make(map[uint32]kittySessionEntry)
 	kittySessionImageMux.Unlock()
-	kittyIDMap = make(map[string]uint32)
-	kittyIDReverse = make(map[uint32]string)
-	kittyIDNext = 1
+	resetSmallKittyIDs()
 }
 
 func kittyBinaryVersion() (string, error) {
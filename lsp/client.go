@@ -0,0 +1,441 @@
+// Package lsp is a small Language Server Protocol client. It starts a
+// server as a child process, speaks JSON-RPC over its stdin and stdout and
+// covers what the editor needs for code notes: full document sync,
+// diagnostics, hover, go-to-definition and completion.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a request waits for its response
+const DefaultTimeout = 5 * time.Second
+
+var ErrClosed = errors.New("lsp: connection closed")
+
+// Client is a connection to one language server
+type Client struct {
+	cmd *exec.Cmd
+	w   io.WriteCloser
+	r   *bufio.Reader
+
+	// Timeout bounds every request; set it before making requests
+	Timeout time.Duration
+
+	// onDiagnostics is called from the reader goroutine whenever the server
+	// publishes diagnostics for a document
+	onDiagnostics func(uri string)
+
+	writeMu sync.Mutex // one message on the pipe at a time
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	docs    map[string]*document
+	err     error // set once the connection is gone
+	done    chan struct{}
+}
+
+// document is an open text document
+type document struct {
+	version     int
+	diagnostics []Diagnostic
+}
+
+// message is any JSON-RPC message read from the server
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *ResponseError  `json:"error,omitempty"`
+}
+
+type outgoing struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+}
+
+// reply is a response to a request from the server; result is always
+// present, even when it is null
+type reply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+// Start runs command in rootDir, performs the initialize handshake and
+// returns the client. onDiagnostics may be nil.
+func Start(command string, args []string, rootDir string, onDiagnostics func(uri string)) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = rootDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := newClient(stdout, stdin, onDiagnostics)
+	c.cmd = cmd
+	if err := c.initialize(rootDir); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("lsp: initializing %s: %w", command, err)
+	}
+	return c, nil
+}
+
+func newClient(r io.Reader, w io.WriteCloser, onDiagnostics func(uri string)) *Client {
+	c := &Client{
+		w:             w,
+		r:             bufio.NewReader(r),
+		Timeout:       DefaultTimeout,
+		onDiagnostics: onDiagnostics,
+		pending:       make(map[int]chan *message),
+		docs:          make(map[string]*document),
+		done:          make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *Client) initialize(rootDir string) error {
+	rootURI := PathToURI(rootDir)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(rootDir)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"dynamicRegistration": false},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]any{"linkSupport": true},
+				"completion":         map[string]any{"completionItem": map[string]any{"snippetSupport": false}},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+			},
+		},
+	}
+	if err := c.call("initialize", params, nil); err != nil {
+		return err
+	}
+	return c.notify("initialized", struct{}{})
+}
+
+// Shutdown asks the server to exit and waits briefly for it to go
+func (c *Client) Shutdown() error {
+	err := c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	c.Close()
+	return err
+}
+
+// Close drops the connection and kills the server if it is still running
+func (c *Client) Close() {
+	c.w.Close()
+	if c.cmd == nil {
+		return
+	}
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// Done is closed when the connection to the server is lost
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// DidOpen tells the server a document is open with the given text
+func (c *Client) DidOpen(path, languageID, text string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	c.docs[uri] = &document{version: 1}
+	c.mu.Unlock()
+	return c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        uri,
+			"languageId": languageID,
+			"version":    1,
+			"text":       validUTF8(text),
+		},
+	})
+}
+
+// DidChange replaces the text of an open document and returns its new version
+func (c *Client) DidChange(path, text string) (int, error) {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	doc, ok := c.docs[uri]
+	if !ok {
+		c.mu.Unlock()
+		return 0, fmt.Errorf("lsp: %s is not open", path)
+	}
+	doc.version++
+	version := doc.version
+	c.mu.Unlock()
+	return version, c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]string{{"text": validUTF8(text)}},
+	})
+}
+
+// DidClose tells the server a document is no longer open
+func (c *Client) DidClose(path string) error {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	delete(c.docs, uri)
+	c.mu.Unlock()
+	return c.notify("textDocument/didClose", map[string]any{
+		"textDocument": textDocumentIdentifier{URI: uri},
+	})
+}
+
+// IsOpen reports whether path has been opened with DidOpen
+func (c *Client) IsOpen(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.docs[PathToURI(path)]
+	return ok
+}
+
+// Version returns the version of the text last sent for path
+func (c *Client) Version(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if doc, ok := c.docs[PathToURI(path)]; ok {
+		return doc.version
+	}
+	return 0
+}
+
+// Diagnostics returns the diagnostics last published for path
+func (c *Client) Diagnostics(path string) []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if doc, ok := c.docs[PathToURI(path)]; ok {
+		return doc.diagnostics
+	}
+	return nil
+}
+
+// Hover returns the hover text at pos, usually markdown
+func (c *Client) Hover(path string, pos Position) (string, error) {
+	var raw json.RawMessage
+	if err := c.call("textDocument/hover", positionParams(path, pos), &raw); err != nil {
+		return "", err
+	}
+	return hoverText(raw), nil
+}
+
+// Definition returns where the symbol at pos is defined
+func (c *Client) Definition(path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.call("textDocument/definition", positionParams(path, pos), &raw); err != nil {
+		return nil, err
+	}
+	return definitionLocations(raw), nil
+}
+
+// Completion returns the completion candidates at pos
+func (c *Client) Completion(path string, pos Position) ([]CompletionItem, error) {
+	var raw json.RawMessage
+	if err := c.call("textDocument/completion", positionParams(path, pos), &raw); err != nil {
+		return nil, err
+	}
+	return completionItems(raw), nil
+}
+
+func positionParams(path string, pos Position) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: PathToURI(path)},
+		Position:     pos,
+	}
+}
+
+// call sends a request and waits for its response; result may be nil
+func (c *Client) call(method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	forget := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	err := c.write(outgoing{JSONRPC: "2.0", ID: json.RawMessage(strconv.Itoa(id)), Method: method, Params: params})
+	if err != nil {
+		forget()
+		return err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg == nil {
+			return c.connErr()
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-time.After(c.Timeout):
+		forget()
+		return fmt.Errorf("lsp: %s timed out", method)
+	}
+}
+
+func (c *Client) notify(method string, params any) error {
+	if err := c.connErr(); err != nil {
+		return err
+	}
+	return c.write(outgoing{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (c *Client) connErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeMessage(c.w, v)
+}
+
+func (c *Client) readLoop() {
+	for {
+		msg, err := readMessage(c.r)
+		if err != nil {
+			c.mu.Lock()
+			c.err = ErrClosed
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			close(c.done)
+			return
+		}
+		c.handle(msg)
+	}
+}
+
+func (c *Client) handle(msg *message) {
+	switch {
+	case msg.Method == "" && len(msg.ID) > 0:
+		id, err := strconv.Atoi(string(msg.ID))
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+
+	case msg.Method == "textDocument/publishDiagnostics":
+		var params publishDiagnosticsParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return
+		}
+		c.mu.Lock()
+		doc, ok := c.docs[params.URI]
+		if ok {
+			doc.diagnostics = params.Diagnostics
+		}
+		c.mu.Unlock()
+		if ok && c.onDiagnostics != nil {
+			c.onDiagnostics(params.URI)
+		}
+
+	case len(msg.ID) > 0:
+		// a request from the server (progress tokens, configuration,
+		// capability registration); servers wait for an answer, so answer
+		// each one with an empty result
+		var result any
+		if msg.Method == "workspace/configuration" {
+			var params struct {
+				Items []json.RawMessage `json:"items"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			result = make([]any, len(params.Items))
+		}
+		c.write(reply{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+}
+
+// writeMessage writes v with the Content-Length header the protocol uses
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// readMessage reads one framed message
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("lsp: bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
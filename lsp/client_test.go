@@ -0,0 +1,213 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fake server is this test binary run again with LSP_FAKE_SERVER set.
+// It reports every occurrence of "bad" as an error, answers hover with the
+// position it was asked about, puts every definition at line 0 col 5 and
+// completes two Print functions.
+func TestFakeServer(t *testing.T) {
+	if os.Getenv("LSP_FAKE_SERVER") != "1" {
+		t.Skip("only runs as the fake language server")
+	}
+	fakeServer()
+	os.Exit(0)
+}
+
+func fakeServer() {
+	r := bufio.NewReader(os.Stdin)
+	send := func(v any) { writeMessage(os.Stdout, v) }
+	respond := func(id json.RawMessage, result any) {
+		send(reply{JSONRPC: "2.0", ID: id, Result: result})
+	}
+	publish := func(uri, text string) {
+		diags := []Diagnostic{}
+		for i, line := range strings.Split(text, "\n") {
+			if col := strings.Index(line, "bad"); col != -1 {
+				start := UTF16Col(line, col)
+				diags = append(diags, Diagnostic{
+					Range:    Range{Position{i, start}, Position{i, start + 3}},
+					Severity: SeverityError,
+					Message:  "bad word",
+				})
+			}
+		}
+		send(outgoing{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics",
+			Params: publishDiagnosticsParams{URI: uri, Diagnostics: diags}})
+	}
+
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		var pos textDocumentPositionParams
+		json.Unmarshal(msg.Params, &pos)
+
+		switch msg.Method {
+		case "initialize":
+			// ask the client something first; it has to answer for us to go on
+			send(outgoing{JSONRPC: "2.0", ID: json.RawMessage(`"cfg"`), Method: "workspace/configuration",
+				Params: map[string]any{"items": []any{map[string]string{"section": "fake"}}}})
+			if answer, err := readMessage(r); err != nil || string(answer.Result) != "[null]" {
+				return
+			}
+			respond(msg.ID, map[string]any{"capabilities": map[string]any{"hoverProvider": true}})
+		case "textDocument/didOpen":
+			var p struct {
+				TextDocument struct{ URI, Text string } `json:"textDocument"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			publish(p.TextDocument.URI, p.TextDocument.Text)
+		case "textDocument/didChange":
+			var p struct {
+				TextDocument   struct{ URI string }    `json:"textDocument"`
+				ContentChanges []struct{ Text string } `json:"contentChanges"`
+			}
+			json.Unmarshal(msg.Params, &p)
+			publish(p.TextDocument.URI, p.ContentChanges[0].Text)
+		case "textDocument/hover":
+			respond(msg.ID, map[string]any{"contents": map[string]string{
+				"kind":  "markdown",
+				"value": "hover " + strings.Repeat("x", pos.Position.Line) + "|" + strings.Repeat("y", pos.Position.Character),
+			}})
+		case "textDocument/definition":
+			respond(msg.ID, []Location{{URI: pos.TextDocument.URI, Range: Range{Start: Position{0, 5}, End: Position{0, 8}}}})
+		case "textDocument/completion":
+			respond(msg.ID, map[string]any{"isIncomplete": false, "items": []CompletionItem{
+				{Label: "Println"},
+				{Label: "Printf(", InsertText: "Printf"},
+			}})
+		case "shutdown":
+			respond(msg.ID, nil)
+		case "exit":
+			return
+		}
+	}
+}
+
+func startFake(t *testing.T, onDiagnostics func(string)) *Client {
+	t.Helper()
+	t.Setenv("LSP_FAKE_SERVER", "1")
+	c, err := Start(os.Args[0], []string{"-test.run=^TestFakeServer$"}, t.TempDir(), onDiagnostics)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	c.Timeout = 2 * time.Second
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestDiagnostics(t *testing.T) {
+	published := make(chan string, 4)
+	c := startFake(t, func(uri string) { published <- uri })
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	wait := func() {
+		t.Helper()
+		select {
+		case uri := <-published:
+			if uri != PathToURI(path) {
+				t.Fatalf("diagnostics for %s, want %s", uri, PathToURI(path))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no diagnostics published")
+		}
+	}
+
+	if err := c.DidOpen(path, "go", "package main\n\nvar x = bad"); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	want := []Diagnostic{{Range: Range{Position{2, 8}, Position{2, 11}}, Severity: SeverityError, Message: "bad word"}}
+	if got := c.Diagnostics(path); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diagnostics = %+v, want %+v", got, want)
+	}
+
+	version, err := c.DidChange(path, "package main\n")
+	if err != nil || version != 2 {
+		t.Fatalf("DidChange = %d, %v", version, err)
+	}
+	wait()
+	if got := c.Diagnostics(path); len(got) != 0 {
+		t.Errorf("Diagnostics after fix = %+v", got)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	c := startFake(t, nil)
+	path := filepath.Join(t.TempDir(), "main.go")
+	c.DidOpen(path, "go", "package main")
+
+	hover, err := c.Hover(path, Position{Line: 1, Character: 2})
+	if err != nil || hover != "hover x|yy" {
+		t.Errorf("Hover = %q, %v", hover, err)
+	}
+
+	locs, err := c.Definition(path, Position{})
+	if err != nil || len(locs) != 1 || URIToPath(locs[0].URI) != path || locs[0].Range.Start != (Position{0, 5}) {
+		t.Errorf("Definition = %+v, %v", locs, err)
+	}
+
+	items, err := c.Completion(path, Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, item := range items {
+		texts = append(texts, item.Text())
+	}
+	if !reflect.DeepEqual(texts, []string{"Println", "Printf"}) {
+		t.Errorf("Completion texts = %q", texts)
+	}
+
+	if err := c.Shutdown(); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection still open after shutdown")
+	}
+	if _, err := c.Hover(path, Position{}); err != ErrClosed {
+		t.Errorf("Hover after shutdown err = %v, want ErrClosed", err)
+	}
+}
+
+func TestUTF16Columns(t *testing.T) {
+	line := "aé😀b"
+	tests := []struct{ byteCol, utf16Col int }{
+		{0, 0}, {1, 1}, {3, 2}, {7, 4}, {8, 5},
+	}
+	for _, tt := range tests {
+		if got := UTF16Col(line, tt.byteCol); got != tt.utf16Col {
+			t.Errorf("UTF16Col(%d) = %d, want %d", tt.byteCol, got, tt.utf16Col)
+		}
+		if got := ByteCol(line, tt.utf16Col); got != tt.byteCol {
+			t.Errorf("ByteCol(%d) = %d, want %d", tt.utf16Col, got, tt.byteCol)
+		}
+	}
+}
+
+func TestMarkedText(t *testing.T) {
+	tests := []struct{ raw, want string }{
+		{`"plain"`, "plain"},
+		{`{"kind":"markdown","value":"**md**"}`, "**md**"},
+		{`{"language":"go","value":"func f()"}`, "```go\nfunc f()\n```"},
+		{`["a",{"language":"go","value":"b"}]`, "a\n\n```go\nb\n```"},
+	}
+	for _, tt := range tests {
+		if got := markedText(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("markedText(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a zero-based line and a character offset counted in UTF-16
+// code units, which is what servers expect unless told otherwise
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is what servers that support linkSupport return for a definition
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// Diagnostic severities
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type CompletionItem struct {
+	Label      string    `json:"label"`
+	Kind       int       `json:"kind,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	InsertText string    `json:"insertText,omitempty"`
+	TextEdit   *TextEdit `json:"textEdit,omitempty"`
+}
+
+// Text returns what inserting the item puts in the buffer
+func (ci CompletionItem) Text() string {
+	switch {
+	case ci.TextEdit != nil:
+		return ci.TextEdit.NewText
+	case ci.InsertText != "":
+		return ci.InsertText
+	}
+	return ci.Label
+}
+
+type completionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     int          `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// ResponseError is an error returned by the server
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// PathToURI returns the file:// URI of path
+func PathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// URIToPath returns the file path of a file:// URI
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// UTF16Col converts a byte column in line to a UTF-16 offset
+func UTF16Col(line string, byteCol int) int {
+	if byteCol > len(line) {
+		byteCol = len(line)
+	}
+	n := 0
+	for _, r := range line[:byteCol] {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// ByteCol converts a UTF-16 offset in line to a byte column
+func ByteCol(line string, utf16Col int) int {
+	n := 0
+	for i, r := range line {
+		if n >= utf16Col {
+			return i
+		}
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return len(line)
+}
+
+// hoverText flattens the contents of a hover result, which may be a
+// MarkupContent, a MarkedString or an array of MarkedStrings
+func hoverText(raw json.RawMessage) string {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if json.Unmarshal(raw, &hover) != nil || len(hover.Contents) == 0 {
+		return ""
+	}
+	return markedText(hover.Contents)
+}
+
+func markedText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if t := markedText(item); t != "" {
+				parts = append(parts, t)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	var content struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(raw, &content) != nil {
+		return ""
+	}
+	if content.Language != "" {
+		return "```" + content.Language + "\n" + content.Value + "\n```"
+	}
+	return content.Value
+}
+
+// definitionLocations decodes a Location, a list of Locations or a list of
+// LocationLinks
+func definitionLocations(raw json.RawMessage) []Location {
+	var loc Location
+	if json.Unmarshal(raw, &loc) == nil && loc.URI != "" {
+		return []Location{loc}
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) != nil {
+		return nil
+	}
+	var locs []Location
+	for _, item := range list {
+		var l Location
+		if json.Unmarshal(item, &l) == nil && l.URI != "" {
+			locs = append(locs, l)
+			continue
+		}
+		var link locationLink
+		if json.Unmarshal(item, &link) == nil && link.TargetURI != "" {
+			locs = append(locs, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+		}
+	}
+	return locs
+}
+
+// completionItems decodes either a CompletionList or a list of items
+func completionItems(raw json.RawMessage) []CompletionItem {
+	var items []CompletionItem
+	if json.Unmarshal(raw, &items) == nil {
+		return items
+	}
+	var list completionList
+	if json.Unmarshal(raw, &list) == nil {
+		return list.Items
+	}
+	return nil
+}
+
+// validUTF8 replaces invalid bytes so a note with stray bytes can still be
+// sent as JSON
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, "�")
+}
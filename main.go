@@ -40,6 +40,7 @@ func main() {
 	// Override defaults with user preferences
 	app.imageScale = prefs.ImageScale
 	app.imageCacheMaxWidth = prefs.ImageCacheMaxWidth
+	app.themeChoice = prefs.Theme
 
 	// Google Drive is optional - initialize if credentials are available
 	srv, err := auth.GetDriveService()
@@ -122,6 +123,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := app.MigrateUndoHistory(); err != nil {
+		fmt.Printf("Error: Database migration failed.\n")
+		fmt.Printf("Details: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := app.MigrateRecovery(); err != nil {
+		fmt.Printf("Error: Database migration failed.\n")
+		fmt.Printf("Details: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := app.MigrateAttachments(); err != nil {
+		fmt.Printf("Error: Database migration failed.\n")
+		fmt.Printf("Details: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Validate glamour style file exists
 	if err := validateGlamourStyle(); err != nil {
 		log.Fatalf("Error: %v", err)
@@ -150,23 +169,40 @@ func main() {
 	}
 
 	app.origTermCfg = origCfg
+	// leave the terminal usable if vimango panics
+	defer func() {
+		if r := recover(); r != nil {
+			app.restoreTerminal()
+			panic(r)
+		}
+	}()
+
+	// Choose how the preview draws images now that replies to the terminal
+	// queries can be read
+	app.DetectImageProtocol()
+	app.setMouse(prefs.Mouse)
 	app.Session.editorMode = false
 
 	// Get window size
 	err = app.Screen.GetWindowSize()
 	if err != nil {
+		app.restoreTerminal()
 		fmt.Fprintf(os.Stderr, "Error getting window size: %v", err)
 		os.Exit(1)
 	}
 	//os.Exit(0) //debugging
 
 	app.InitApp()
+	if err := app.setTheme(startupTheme(app.themeChoice)); err != nil {
+		app.setTheme("default")
+	}
 
 	// Set edPct BEFORE LoadInitialData() so it uses the correct value
 	// LoadInitialData() will calculate divider and totaleditorcols based on this
 	app.Screen.edPct = prefs.EdPct
 
 	app.LoadInitialData()
+	app.Organizer.offerRecovery()
 
 	app.Run = true
 	app.MainLoop()
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slzatz/vimango/terminal"
+	"github.com/slzatz/vimango/vim"
+)
+
+// With the mouse on (the default; :mouse toggles it and the choice is kept
+// in preferences.json) a click selects an organizer row and a double-click
+// opens its note, the wheel scrolls the preview, the organizer and the
+// editors, a click in an editor moves the cursor there and dragging the
+// divider resizes the windows. With it off the terminal selects text as
+// usual.
+
+// doubleClickInterval is the longest time between the clicks of a
+// double-click
+const doubleClickInterval = 400 * time.Millisecond
+
+// wheelLines is how far a turn of the wheel scrolls
+const wheelLines = 3
+
+// mouseState is what handling one mouse event needs to know about the
+// earlier ones
+type mouseState struct {
+	lastClick       time.Time
+	lastX, lastY    int
+	draggingDivider bool
+}
+
+// setMouse turns mouse reporting on or off
+func (a *App) setMouse(on bool) {
+	a.mouse = on
+	if on {
+		terminal.EnableMouse()
+	} else {
+		terminal.DisableMouse()
+	}
+}
+
+// handleMouse acts on a mouse event
+func (a *App) handleMouse(m terminal.Mouse) {
+	if !a.mouse {
+		return
+	}
+	s := &a.mouseState
+
+	// dragging the divider moves it when the button is released
+	if s.draggingDivider {
+		if m.Release {
+			s.draggingDivider = false
+			a.moveDividerAbs(a.Screen.screenCols - m.X)
+		}
+		return
+	}
+	if m.Release || m.Drag {
+		return
+	}
+
+	switch m.Button {
+	case terminal.MouseWheelUp, terminal.MouseWheelDown:
+		lines := wheelLines
+		if m.Button == terminal.MouseWheelUp {
+			lines = -lines
+		}
+		a.mouseWheel(m.X, m.Y, lines)
+		return
+	case terminal.MouseLeft:
+	default:
+		return
+	}
+
+	if m.X == a.Screen.divider && m.Y > TOP_MARGIN {
+		s.draggingDivider = true
+		return
+	}
+
+	double := time.Since(s.lastClick) < doubleClickInterval && m.X == s.lastX && m.Y == s.lastY
+	s.lastClick, s.lastX, s.lastY = time.Now(), m.X, m.Y
+	if double {
+		s.lastClick = time.Time{} // a third click starts over
+	}
+
+	if m.X < a.Screen.divider {
+		if !a.Session.editorMode {
+			a.Organizer.clickRow(m.Y, double)
+		}
+		return
+	}
+	if a.Session.editorMode {
+		if e := a.editorAt(m.X, m.Y); e != nil {
+			e.click(m.X, m.Y)
+		}
+	}
+}
+
+// mouseWheel scrolls whatever is under the pointer
+func (a *App) mouseWheel(x, y, lines int) {
+	o := a.Organizer
+	if x < a.Screen.divider {
+		if !a.Session.editorMode && o.mode == NORMAL && len(o.rows) > 0 {
+			o.selectRow(o.fr + lines)
+		}
+		return
+	}
+	if a.Session.editorMode {
+		if e := a.editorAt(x, y); e != nil && e == a.Session.activeEditor {
+			e.scrollLines(lines)
+		}
+		return
+	}
+	if o.mode == NORMAL && o.view == TASK {
+		o.scrollPreviewLines(lines)
+	}
+}
+
+// editorAt returns the editor whose window contains screen column x and
+// row y
+func (a *App) editorAt(x, y int) *Editor {
+	for _, e := range a.Session.Editors {
+		if x > e.left_margin && x <= e.left_margin+e.screencols &&
+			y >= e.top_margin && y < e.top_margin+e.screenlines {
+			return e
+		}
+	}
+	return nil
+}
+
+// clickRow selects the row drawn on screen row y and opens its note if
+// the click was a double-click
+func (o *Organizer) clickRow(y int, double bool) {
+	if o.mode != NORMAL || len(o.rows) == 0 {
+		return
+	}
+	fr := o.rowoff + y - TOP_MARGIN - 1
+	if y <= TOP_MARGIN || y > o.Screen.textLines+TOP_MARGIN || fr >= len(o.rows) {
+		return
+	}
+	o.selectRow(fr)
+	if double && o.view == TASK {
+		o.editNote(-1)
+	}
+}
+
+// selectRow makes fr the current row the way moving the cursor in NORMAL
+// mode does
+func (o *Organizer) selectRow(fr int) {
+	fr = clamp(fr, 0, len(o.rows)-1)
+	if fr != o.fr {
+		prevRow := o.fr
+		o.fr, o.fc = fr, 0
+		vim.SetCursorPosition(o.fr+1, 0)
+		o.altRowoff = 0
+		o.erasePreviousRowMarker(prevRow)
+		if o.view == TASK {
+			o.displayNote()
+		} else {
+			o.displayContainerInfo()
+		}
+	}
+	o.scroll()
+	o.refreshScreen()
+	if o.Screen.divider > 10 {
+		o.drawStatusBar()
+	}
+}
+
+// scrollPreviewLines scrolls the preview down (or up if lines is negative)
+func (o *Organizer) scrollPreviewLines(lines int) {
+	n := len(o.previewLines())
+	offset := clamp(o.altRowoff+lines, 0, n-1)
+	if offset == o.altRowoff {
+		return
+	}
+	o.altRowoff = offset
+	o.Screen.eraseRightScreen()
+	o.drawRenderedNote()
+}
+
+// click moves the cursor to the character drawn at screen column x and
+// row y, making e the active editor
+func (e *Editor) click(x, y int) {
+	if e.mode != NORMAL && e.mode != INSERT {
+		return
+	}
+	if ae := e.Session.activeEditor; ae != e {
+		if ae.mode != NORMAL {
+			return
+		}
+		vim.SetCurrentBuffer(e.vbuf)
+		e.Session.activeEditor = e
+	}
+	line := y - e.top_margin + e.lineOffset
+	r, first := e.rowAtScreenLine(line)
+	if r < 0 {
+		return
+	}
+	e.fr = r
+	e.fc = e.colAtScreenX(r, line-first, x-e.left_margin-e.left_margin_offset-1)
+	vim.SetCursorPosition(e.fr+1, e.fc)
+	e.fc = utf8.RuneCountInString(e.ss[e.fr][:e.fc])
+	e.scroll()
+	e.drawText()
+	e.drawStatusBar()
+}
+
+// rowAtScreenLine returns the row drawn on the note's screen line line
+// (counting from the top of the note) and the row's first screen line; the
+// last row if line is past the end of the note
+func (e *Editor) rowAtScreenLine(line int) (int, int) {
+	if len(e.ss) == 0 {
+		return -1, 0
+	}
+	first := 0
+	for r := range e.ss {
+		n := e.getLinesInRowWW(r)
+		if line < first+n {
+			return r, first
+		}
+		if r == len(e.ss)-1 {
+			return r, first + max(n-1, 0)
+		}
+		first += n
+	}
+	return -1, 0
+}
+
+// colAtScreenX returns the byte offset in row r of the character drawn at
+// column x of the row's wrapped line line (both counting from 0)
+func (e *Editor) colAtScreenX(r, line, x int) int {
+	if e.foldedRow(r) {
+		return 0
+	}
+	row := e.ss[r]
+	col := -1
+	for c := range row {
+		if e.getLineInRowWW(r, c)-1 != line {
+			if col >= 0 {
+				break
+			}
+			continue
+		}
+		if e.getScreenXFromRowColWW(r, c) > x && col >= 0 {
+			break
+		}
+		col = c
+	}
+	if col < 0 {
+		return 0
+	}
+	return col
+}
+
+// scrollLines scrolls the editor down (or up if lines is negative),
+// keeping the cursor on the screen
+func (e *Editor) scrollLines(lines int) {
+	if e.mode != NORMAL && e.mode != INSERT || len(e.ss) == 0 {
+		return
+	}
+	last := len(e.ss) - 1
+	total := e.getScreenYFromRowColWW(last, 0) + e.getLinesInRowWW(last)
+	offset := clamp(e.lineOffset+lines, 0, total-e.screenlines)
+	if offset == e.lineOffset {
+		return
+	}
+	prevOffset, prevFirst := e.lineOffset, e.firstVisibleRow
+	e.lineOffset = offset
+	e.adjustFirstVisibleRow()
+	if e.firstVisibleRow > last {
+		e.lineOffset, e.firstVisibleRow = prevOffset, prevFirst
+		return
+	}
+
+	cy := e.getScreenYFromRowColWW(e.fr, e.fc)
+	switch {
+	case cy < e.lineOffset:
+		e.fr, e.fc = e.firstVisibleRow, 0
+	case cy > e.lineOffset+e.screenlines-1:
+		r, first := e.rowAtScreenLine(e.lineOffset + e.screenlines - 1)
+		if first+e.getLinesInRowWW(r) > e.lineOffset+e.screenlines && r > e.firstVisibleRow {
+			r--
+		}
+		e.fr, e.fc = r, 0
+	}
+	vim.SetCursorPosition(e.fr+1, e.fc)
+	e.scroll()
+	e.drawText()
+	e.drawStatusBar()
+}
+
+// clamp limits n to lo..hi, favoring lo if hi < lo
+func clamp(n, lo, hi int) int {
+	if n > hi {
+		n = hi
+	}
+	if n < lo {
+		n = lo
+	}
+	return n
+}
+
+// toggleMouse turns mouse reporting on or off (:mouse [on|off])
+func (o *Organizer) toggleMouse(pos int) {
+	var arg string
+	if pos != -1 {
+		arg = o.command_line[pos+1:]
+	}
+	o.mode = NORMAL
+	o.command_line = ""
+	o.ShowMessage(BL, "%s", app.chooseMouse(arg))
+}
+
+// toggleMouse turns mouse reporting on or off (:mouse [on|off])
+func (e *Editor) toggleMouse() {
+	_, arg, _ := strings.Cut(e.command_line, " ")
+	e.ShowMessage(BR, "%s", app.chooseMouse(arg))
+}
+
+// chooseMouse implements :mouse for the organizer and the editor
+func (a *App) chooseMouse(arg string) string {
+	switch strings.TrimSpace(arg) {
+	case "":
+		a.setMouse(!a.mouse)
+	case "on":
+		a.setMouse(true)
+	case "off":
+		a.setMouse(false)
+	default:
+		return "Usage: mouse [on|off]"
+	}
+	if err := a.SavePreferences(); err != nil {
+		// Silently ignore save errors (preferences not critical)
+	}
+	if a.mouse {
+		return "Mouse is on"
+	}
+	return "Mouse is off; the terminal selects text"
+}
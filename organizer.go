@@ -35,8 +35,15 @@ type Organizer struct {
 	sortPriority        bool
 	command_line        string
 	message             string
-	note                []string // the preview
-	notice              []string // e.g., synch results, help test, research notification
+	note                []string        // the preview
+	notice              []string        // e.g., synch results, help test, research notification
+	outlineHeadings     []heading       // headings of the note shown by :outline
+	outlineIndex        int             // selected heading in :outline
+	outlineRowoff       int             // preview scroll to return to when :outline is quit
+	previewFolds        map[int]int     // collapsed sections of the preview, first row -> last row
+	recovered           []recoveredNote // notes with unsaved changes listed by :recover
+	recoveryIndex       int             // selected note in :recover
+	recoveryDiff        bool            // the preview shows the selected note's diff
 	command             string
 	show_deleted        bool
 	show_completed      bool
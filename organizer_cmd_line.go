@@ -56,6 +56,35 @@ func (a *App) setOrganizerExCmds(organizer *Organizer) map[string]func(*Organize
 		Examples:    []string{":openkeyword urgent", ":ok meeting"},
 	})
 
+	registry.Register("outline", (*Organizer).outline, CommandInfo{
+		Aliases:     []string{"ol"},
+		Description: "List the headings of the current note and scroll the preview to one",
+		Usage:       "outline",
+		Category:    "Navigation",
+		Examples:    []string{":outline", ":ol"},
+	})
+
+	registry.Register("recover", (*Organizer).recover, CommandInfo{
+		Description: "Review autosaved changes that were never written: diff, restore or discard them",
+		Usage:       "recover",
+		Category:    "Data Management",
+		Examples:    []string{":recover"},
+	})
+
+	registry.Register("fold", (*Organizer).foldPreview, CommandInfo{
+		Description: "Collapse the preview's sections with headings at the level or below (default 1)",
+		Usage:       "fold [level]",
+		Category:    "Navigation",
+		Examples:    []string{":fold", ":fold 2"},
+	})
+
+	registry.Register("unfold", (*Organizer).unfoldPreview, CommandInfo{
+		Description: "Expand all the preview's collapsed sections",
+		Usage:       "unfold",
+		Category:    "Navigation",
+		Examples:    []string{":unfold"},
+	})
+
 	// Data Management commands
 	registry.Register("new", (*Organizer).newEntry, CommandInfo{
 		Aliases:     []string{"n"},
@@ -244,6 +273,20 @@ func (a *App) setOrganizerExCmds(organizer *Organizer) map[string]func(*Organize
 		Examples:    []string{":showall", ":show"},
 	})
 
+	registry.Register("mouse", (*Organizer).toggleMouse, CommandInfo{
+		Description: "Turn the mouse on or off; off leaves text selection to the terminal",
+		Usage:       "mouse [on|off]",
+		Category:    "View Management",
+		Examples:    []string{":mouse", ":mouse off"},
+	})
+
+	registry.Register("theme", (*Organizer).setTheme, CommandInfo{
+		Description: "Show the theme or switch to another (default, mono or a file in themes/)",
+		Usage:       "theme [name]",
+		Category:    "View Management",
+		Examples:    []string{":theme", ":theme mono", ":theme light"},
+	})
+
 	/*
 		registry.Register("image", (*Organizer).setImage, CommandInfo{
 			Name:        "image",
@@ -279,6 +322,14 @@ func (a *App) setOrganizerExCmds(organizer *Organizer) map[string]func(*Organize
 		Examples:    []string{":toggleimages", ":ti"},
 	})
 
+	registry.Register("imagemode", (*Organizer).imageMode, CommandInfo{
+		Aliases:     []string{"im"},
+		Description: "Show or change how inline images are drawn (blocks works in any terminal)",
+		Usage:       "imagemode [auto|kitty|sixel|iterm2|blocks|none]",
+		Category:    "Images",
+		Examples:    []string{":imagemode", ":imagemode blocks", ":imagemode auto", ":im sixel"},
+	})
+
 	registry.Register("showimageinfo", (*Organizer).showImageInfo, CommandInfo{
 		Aliases:     []string{"sii"},
 		Description: "Toggle display of Google Drive folder/filename above images",
@@ -319,6 +370,21 @@ func (a *App) setOrganizerExCmds(organizer *Organizer) map[string]func(*Organize
 		Examples:    []string{":clearcache", ":clc"},
 	})
 
+	registry.Register("cachestats", (*Organizer).cacheStats, CommandInfo{
+		Aliases:     []string{"cs"},
+		Description: "Show disk image cache entries, size, hits and misses by source (gdrive/http/file)",
+		Usage:       "cachestats",
+		Category:    "Images",
+		Examples:    []string{":cachestats", ":cs"},
+	})
+
+	registry.Register("cachegc", (*Organizer).cacheGC, CommandInfo{
+		Description: "Remove cached images that no note references any more",
+		Usage:       "cachegc",
+		Category:    "Images",
+		Examples:    []string{":cachegc"},
+	})
+
 	registry.Register("imagereset", (*Organizer).imageReset, CommandInfo{
 		Aliases:     []string{"ir"},
 		Description: "Clear terminal image cache and rerender current note",
@@ -900,6 +966,8 @@ func (o *Organizer) editNote(id int) {
 		ae.vbuf = vim.NewBuffer(0)
 		vim.SetCurrentBuffer(ae.vbuf)
 		ae.vbuf.SetLines(0, -1, ae.ss)
+		ae.restoreUndoHistory()
+		ae.startLsp()
 		//////// need to look at whether we need both buffer and save tick 10/01/2025
 		ae.bufferTick = ae.vbuf.GetLastChangedTick()
 		ae.saveTick = ae.vbuf.GetLastChangedTick()
@@ -1090,6 +1158,7 @@ func (o *Organizer) synchronize(_ int) {
 	} else {
 		log = app.Synchronize(false)
 		err = nil //FIXME
+		personalWords.loaded = false // sync may have brought words from other devices
 	}
 
 	if err != nil {
@@ -1861,6 +1930,33 @@ func (o *Organizer) toggleImages(_ int) {
 	o.displayNote()
 }
 
+// imageMode shows or changes the protocol used to draw inline images
+func (o *Organizer) imageMode(pos int) {
+	var argStr string
+	if pos != -1 {
+		argStr = strings.ToLower(strings.TrimSpace(o.command_line[pos+1:]))
+	}
+
+	o.mode = NORMAL
+	o.command_line = ""
+
+	if argStr != "" {
+		if err := app.setImageProtocol(argStr); err != nil {
+			o.ShowMessage(BL, "%v", err)
+			return
+		}
+	}
+
+	mode := "none"
+	if app.imageProtocol != nil {
+		mode = app.imageProtocol.String()
+	}
+	o.ShowMessage(BL, "Image mode: %s", mode)
+	if argStr != "" {
+		o.displayNote()
+	}
+}
+
 // showImageInfo toggles display of Google Drive folder/filename above images
 func (o *Organizer) showImageInfo(_ int) {
 	o.mode = NORMAL
@@ -2093,6 +2189,68 @@ func (o *Organizer) clearImageCache(pos int) {
 	o.ShowMessage(BL, fmt.Sprintf("Cleared image cache: %d files removed (%.1f MB freed)", count, float64(size)/(1024*1024)))
 }
 
+// cacheStats shows what the disk image cache holds and how often it was hit
+func (o *Organizer) cacheStats(_ int) {
+	o.mode = NORMAL
+	o.command_line = ""
+
+	if globalImageCache == nil {
+		o.ShowMessage(BL, "Image cache not initialized")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Image cache\n\n")
+	sb.WriteString("| Source | Entries | MB | Hits | Misses |\n")
+	sb.WriteString("|---|---:|---:|---:|---:|\n")
+	var entries, hits, misses int
+	var size int64
+	for _, st := range globalImageCache.SourceStats() {
+		fmt.Fprintf(&sb, "| %s | %d | %.1f | %d | %d |\n", st.Source, st.Entries, float64(st.Bytes)/(1<<20), st.Hits, st.Misses)
+		entries += st.Entries
+		size += st.Bytes
+		hits += st.Hits
+		misses += st.Misses
+	}
+	fmt.Fprintf(&sb, "| **total** | %d | %.1f | %d | %d |\n\n", entries, float64(size)/(1<<20), hits, misses)
+	globalImageCache.mutex.RLock()
+	maxBytes := globalImageCache.maxBytes
+	globalImageCache.mutex.RUnlock()
+	fmt.Fprintf(&sb, "Limit: %.0f MB (least recently used images are evicted first)\n", float64(maxBytes)/(1<<20))
+
+	o.drawNotice(sb.String())
+	o.altRowoff = 0
+	o.mode = NAVIGATE_NOTICE
+}
+
+// cacheGC removes cached images that no note's markdown refers to
+func (o *Organizer) cacheGC(_ int) {
+	o.mode = NORMAL
+	o.command_line = ""
+
+	if globalImageCache == nil {
+		o.ShowMessage(BL, "Image cache not initialized")
+		return
+	}
+
+	notes, err := o.Database.allNotes()
+	if err != nil {
+		o.ShowMessage(BL, "Error reading notes: %v", err)
+		return
+	}
+	var urls []string
+	for _, note := range notes {
+		urls = append(urls, extractImageURLs(note)...)
+	}
+
+	removed, freed, err := globalImageCache.CollectGarbage(urls)
+	if err != nil {
+		o.ShowMessage(BL, "Error saving image cache index: %v", err)
+		return
+	}
+	o.ShowMessage(BL, "Removed %d unreferenced cached images (%.1f MB freed)", removed, float64(freed)/(1<<20))
+}
+
 // imageReset clears terminal graphics cache and local caches, then rerenders current note.
 // Works with any terminal supporting the kitty graphics protocol (kitty, ghostty, etc.)
 func (o *Organizer) imageReset(pos int) {
@@ -2100,9 +2258,7 @@ func (o *Organizer) imageReset(pos int) {
 	kittySessionImageMux.Lock()
 	kittySessionImages = make(map[uint32]kittySessionEntry)
 	kittySessionImageMux.Unlock()
-	kittyIDMap = make(map[string]uint32)
-	kittyIDReverse = make(map[uint32]string)
-	kittyIDNext = 1
+	resetSmallKittyIDs()
 	sent := kittyImagesSent
 	bytes := kittyBytesSent
 	kittyImagesSent = 0
@@ -42,11 +42,14 @@ var (
 	trustKittyCache        bool
 	kittyPurgeBeforeRender bool
 	kittyClearedOnStart    bool
-	kittyIDMap                    = make(map[string]uint32) // url -> small kitty ID
-	kittyIDReverse                = make(map[uint32]string)
-	kittyIDNext            uint32 = 1
-	kittyImagesSent        uint64
-	kittyBytesSent         uint64
+	// The render goroutine assigns small kitty IDs while the main one checks
+	// them, so kittyIDMux guards kittyIDMap, kittyIDReverse and kittyIDNext
+	kittyIDMux      sync.Mutex
+	kittyIDMap             = make(map[string]uint32) // url -> small kitty ID
+	kittyIDReverse         = make(map[uint32]string)
+	kittyIDNext     uint32 = 1
+	kittyImagesSent uint64
+	kittyBytesSent  uint64
 )
 
 type kittySessionEntry struct {
@@ -108,8 +111,8 @@ var (
 	// Map from imageID to URL for metadata lookup during marker replacement
 	currentRenderImageURLs = make(map[uint32]string)
 
-	// Regex to extract markdown images
-	markdownImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`)
+	// Map from imageID to the image's size and alignment attributes
+	currentRenderImageAttrs = make(map[uint32]imageAttrs)
 
 	// Diacritic table for encoding row/column positions in kitty placeholders
 	kittyDiacritics = []rune{
@@ -205,7 +208,7 @@ func (o *Organizer) drawActiveRow(ab *strings.Builder) {
 
 		fmt.Fprintf(ab, "\x1b[%d;%dH\x1b[1K\x1b[%dG", y+TOP_MARGIN+1, titlecols+LEFT_MARGIN+1, LEFT_MARGIN+1)
 		ab.WriteString(row.title[o.coloff : o.highlight[j]-o.coloff])
-		ab.WriteString(LIGHT_GRAY_BG)
+		ab.WriteString(app.theme.UI.TitleSelection)
 		ab.WriteString(row.title[o.highlight[j] : o.highlight[k]-o.coloff])
 		ab.WriteString(RESET)
 		ab.WriteString(row.title[o.highlight[k]:])
@@ -221,24 +224,24 @@ func (o *Organizer) appendStandardRow(ab *strings.Builder, fr, y, titlecols int)
 		ab.WriteString(BOLD)
 	}
 	if timeKeywordsRegex.MatchString(row.sort) {
-		ab.WriteString(CYAN)
+		ab.WriteString(app.theme.UI.TimeMarker)
 	} else {
-		ab.WriteString(WHITE)
+		ab.WriteString(app.theme.UI.Row)
 	}
 
 	if row.archived && row.deleted {
-		ab.WriteString(GREEN)
+		ab.WriteString(app.theme.UI.ArchivedDeleted)
 	} else if row.archived {
-		ab.WriteString(YELLOW)
+		ab.WriteString(app.theme.UI.Archived)
 	} else if row.deleted {
-		ab.WriteString(RED)
+		ab.WriteString(app.theme.UI.Deleted)
 	}
 
 	if row.dirty {
-		ab.WriteString(BLACK + WHITE_BG)
+		ab.WriteString(app.theme.UI.Dirty)
 	}
 	if _, ok := o.marked_entries[row.id]; ok {
-		ab.WriteString(BLACK + YELLOW_BG)
+		ab.WriteString(app.theme.UI.Marked)
 	}
 
 	if len(row.title) > titlecols {
@@ -250,7 +253,7 @@ func (o *Organizer) appendStandardRow(ab *strings.Builder, fr, y, titlecols int)
 
 	ab.WriteString(RESET)
 	o.writeImageMarker(ab, y, row.hasImage)
-	ab.WriteString(WHITE)
+	ab.WriteString(app.theme.UI.Row)
 	sortX := o.Screen.divider - TIME_COL_WIDTH + 2
 	width := o.Screen.divider - sortX
 	if width > 0 {
@@ -270,37 +273,41 @@ func (o *Organizer) appendSearchRow(ab *strings.Builder, fr, y, titlecols int) {
 	row := &o.rows[fr]
 
 	fmt.Fprintf(ab, "\x1b[%d;%dH", y+TOP_MARGIN+1, LEFT_MARGIN+1)
+	styleStart := ab.Len()
 
 	if row.star {
 		ab.WriteString(BOLD)
 	}
 	if timeKeywordsRegex.MatchString(row.sort) {
-		ab.WriteString(CYAN)
+		ab.WriteString(app.theme.UI.TimeMarker)
 	} else {
-		ab.WriteString(WHITE)
+		ab.WriteString(app.theme.UI.Row)
 	}
 
 	if row.archived && row.deleted {
-		ab.WriteString(GREEN)
+		ab.WriteString(app.theme.UI.ArchivedDeleted)
 	} else if row.archived {
-		ab.WriteString(YELLOW)
+		ab.WriteString(app.theme.UI.Archived)
 	} else if row.deleted {
-		ab.WriteString(RED)
+		ab.WriteString(app.theme.UI.Deleted)
 	}
 
 	if row.dirty {
-		ab.WriteString(BLACK + WHITE_BG)
+		ab.WriteString(app.theme.UI.Dirty)
 	}
 	if _, ok := o.marked_entries[row.id]; ok {
-		ab.WriteString(BLACK + YELLOW_BG)
+		ab.WriteString(app.theme.UI.Marked)
 	}
+	// the search highlight ends by going back to the row's style
+	rowStyle := ab.String()[styleStart:]
+	highlight := strings.NewReplacer(ftsHighlightStart, app.theme.UI.SearchHighlight, ftsHighlightEnd, RESET+rowStyle)
 
 	if len(row.title) <= titlecols {
-		ab.WriteString(row.ftsTitle)
+		ab.WriteString(highlight.Replace(row.ftsTitle))
 	} else {
-		pos := strings.Index(row.ftsTitle, "\x1b[49m")
+		pos := strings.Index(row.ftsTitle, ftsHighlightEnd)
 		if pos > 0 && pos < titlecols+11 && len(row.ftsTitle) >= titlecols+15 {
-			ab.WriteString(row.ftsTitle[:titlecols+15])
+			ab.WriteString(highlight.Replace(row.ftsTitle[:titlecols+15]))
 		} else {
 			ab.WriteString(row.title[:titlecols])
 		}
@@ -338,7 +345,7 @@ func (o *Organizer) writeImageMarker(ab *strings.Builder, y int, hasImage bool)
 	}
 
 	fmt.Fprintf(ab, "\x1b[%d;%dH", y+TOP_MARGIN+1, markerX)
-	ab.WriteString(WHITE)
+	ab.WriteString(app.theme.UI.ImageMarker)
 	if hasImage {
 		ab.WriteString(filledImageMarker)
 	} else {
@@ -346,7 +353,7 @@ func (o *Organizer) writeImageMarker(ab *strings.Builder, y int, hasImage bool)
 	}
 	ab.WriteString(RESET)
 	for i := 0; i < IMAGE_MARKER_AGE_GAP; i++ {
-		fmt.Fprintf(ab, "\x1b[%d;%dH \x1b[37;1mx\x1b[0m", y+TOP_MARGIN+1, markerX+IMAGE_MARKER_WIDTH+i)
+		fmt.Fprintf(ab, "\x1b[%d;%dH %sx\x1b[0m", y+TOP_MARGIN+1, markerX+IMAGE_MARKER_WIDTH+i, app.theme.UI.Border)
 	}
 }
 
@@ -410,12 +417,11 @@ func (o *Organizer) drawAltRows() {
 		}
 
 		if o.altRows[fr].star {
-			ab.WriteString("\x1b[1m") //bold
-			ab.WriteString("\x1b[1;36m")
+			ab.WriteString(app.theme.UI.Starred)
 		}
 
 		if fr == o.altFr {
-			ab.WriteString("\x1b[48;5;236m") // 236 is a grey
+			ab.WriteString(app.theme.UI.SelectedRow)
 		}
 
 		ab.WriteString(o.altRows[fr].title[:length])
@@ -431,20 +437,21 @@ func (o *Organizer) drawRenderedNote() {
 		debugLog.Close()
 	}
 
-	if len(o.note) == 0 {
+	lines := o.previewLines()
+	if len(lines) == 0 {
 		return
 	}
-	if o.altRowoff >= len(o.note) {
+	if o.altRowoff >= len(lines) {
 		o.altRowoff = 0
 	}
 	start := o.altRowoff
 	var end int
 	// check if there are more lines than can fit on the screen
-	if len(o.note)-start > o.Screen.textLines-1 {
+	if len(lines)-start > o.Screen.textLines-1 {
 		end = o.Screen.textLines + start
 	} else {
 		//end = len(o.note) - 1
-		end = len(o.note)
+		end = len(lines)
 	}
 
 	if debugLog, err := os.OpenFile("kitty_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666); err == nil {
@@ -452,10 +459,14 @@ func (o *Organizer) drawRenderedNote() {
 		debugLog.Close()
 	}
 
+	// Sixel and iTerm2 images are drawn over the rows reserved for them
+	visible, runs := splitImageRows(lines[start:end])
+
 	fmt.Fprintf(os.Stdout, "\x1b[%d;%dH", TOP_MARGIN+1, o.Screen.divider+1)
 	lf_ret := fmt.Sprintf("\r\n\x1b[%dC", o.Screen.divider+0)
-	fmt.Print(strings.Join(o.note[start:end], lf_ret))
+	fmt.Print(strings.Join(visible, lf_ret))
 	fmt.Print(RESET) //sometimes there is an unclosed escape sequence
+	drawImageRuns(runs, TOP_MARGIN+1, o.Screen.divider+1)
 
 	// Note: With Unicode placeholders (U+10EEEE), we don't need separate placements
 	// The placeholders are embedded directly in the text and reference the transmitted images
@@ -616,7 +627,7 @@ func (o *Organizer) drawStatusBar() {
 	var ab strings.Builder
 	//position cursor and erase - and yes you do have to reposition cursor after erase
 	fmt.Fprintf(&ab, "\x1b[%d;%dH\x1b[1K\x1b[%d;1H", o.Screen.textLines+TOP_MARGIN+1, o.Screen.divider, o.Screen.textLines+TOP_MARGIN+1)
-	ab.WriteString("\x1b[7m") //switches to reversed colors
+	ab.WriteString(app.theme.UI.StatusBar)
 
 	var str string
 	var id int
@@ -674,13 +685,13 @@ func (o *Organizer) drawStatusBar() {
 
 	}
 
-	// [49m - revert background to normal
-	// 7m - reverses video
-	// because video is reversted [42 sets text to green and 49 undoes it
-	// also [0;35;7m -> because of 7m it reverses background and foreground
-	// [0;7m is revert text to normal and reverse video
-	status := fmt.Sprintf("\x1b[1m%s\x1b[0;7m %s \x1b[0;35;7m%s\x1b[0;7m %d %d/%d \x1b[1;42m%%s\x1b[0;7m sort: %s ",
-		str, title, keywords, id, o.fr+1, len(o.rows), o.sort)
+	// bar resets the formatting and goes back to the status bar's style;
+	// the mode is drawn in StatusMode over the status bar's style
+	ui := app.theme.UI
+	bar := RESET + ui.StatusBar
+	accent := RESET + ui.StatusAccent
+	status := fmt.Sprintf("\x1b[1m%s%s %s %s%s%s %d %d/%d %s%%s%s sort: %s ",
+		str, bar, title, accent, keywords, bar, id, o.fr+1, len(o.rows), ui.StatusMode, bar, o.sort)
 
 	// klugy way of finding length of string without the escape characters
 	plain := fmt.Sprintf("%s %s %s %d %d/%d   sort: %s ",
@@ -695,17 +706,17 @@ func (o *Organizer) drawStatusBar() {
 		*/
 		fmt.Fprintf(&ab, status, fmt.Sprintf(fmt.Sprintf("%%-%ds", o.Screen.divider-length), o.mode))
 	} else {
-		status = fmt.Sprintf("\x1b[1m%s\x1b[0;7m %s \x1b[0;35;7m%s\x1b[0;7m %d %d/%d\x1b[49m",
-			str, title, keywords, id, o.fr+1, len(o.rows))
+		status = fmt.Sprintf("\x1b[1m%s%s %s %s%s%s %d %d/%d\x1b[49m",
+			str, bar, title, accent, keywords, bar, id, o.fr+1, len(o.rows))
 		plain = fmt.Sprintf("%s %s %s %d %d/%d",
 			str, title, keywords, id, o.fr+1, len(o.rows))
 		length := len(plain)
 		if length < o.Screen.divider {
 			fmt.Fprintf(&ab, "%s%-*s", status, o.Screen.divider-length, " ")
 		} else {
-			status = fmt.Sprintf("\x1b[1m%s\x1b[0;7m %s %s %d %d/%d",
-				str, title, keywords, id, o.fr+1, len(o.rows))
-			ab.WriteString(status[:o.Screen.divider+10])
+			status = fmt.Sprintf("\x1b[1m%s%s %s %s %d %d/%d",
+				str, bar, title, keywords, id, o.fr+1, len(o.rows))
+			ab.WriteString(status[:o.Screen.divider+len("\x1b[1m")+len(bar)])
 		}
 	}
 	ab.WriteString("\x1b[0m") //switches back to normal formatting
@@ -789,12 +800,7 @@ func (o *Organizer) displayNote() {
 	}
 
 	id := o.rows[o.fr].id
-	var note string
-	if o.taskview != BY_FIND {
-		note = o.Database.readNoteIntoString(id)
-	} else {
-		note = o.Database.highlightTerms2(id)
-	}
+	note := o.previewMarkdown(id, o.taskview == BY_FIND)
 	o.Screen.eraseRightScreen()
 
 	if o.Database.taskFolder(id) == "code" {
@@ -810,6 +816,24 @@ func (o *Organizer) displayNote() {
 		// (kitty/no-kitty, images/no-images, cached/uncached)
 		app.RenderManager.StartRender(id, note, o.Screen.totaleditorcols)
 	}
+
+	// Render the notes above and below ahead of time
+	var neighbors []int
+	for _, r := range []int{o.fr + 1, o.fr - 1} {
+		if r >= 0 && r < len(o.rows) {
+			neighbors = append(neighbors, o.rows[r].id)
+		}
+	}
+	app.RenderManager.Prefetch(neighbors, o.Screen.totaleditorcols)
+}
+
+// previewMarkdown returns the note the preview shows: its text, or with
+// the search terms marked when find is set
+func (o *Organizer) previewMarkdown(id int, find bool) string {
+	if !find {
+		return o.Database.readNoteIntoString(id)
+	}
+	return o.Database.highlightTerms2(id)
 }
 
 func (o *Organizer) renderCode(s string, lang string) {
@@ -819,11 +843,12 @@ func (o *Organizer) renderCode(s string, lang string) {
 
 	if o.taskview == BY_FIND {
 		// could use strings.Count to make sure they are balanced
-		note = strings.ReplaceAll(note, "qx", "\x1b[48;5;31m") //^^
-		note = strings.ReplaceAll(note, "qy", "\x1b[0m")       // %%
+		note = strings.ReplaceAll(note, "qx", app.theme.UI.SearchHighlight) //^^
+		note = strings.ReplaceAll(note, "qy", "\x1b[0m")                    // %%
 	}
 	note = WordWrap(note, o.Screen.totaleditorcols, 0)
 	o.note = strings.Split(note, "\n")
+	o.previewFolds = nil
 }
 func (o *Organizer) drawRenderedNote_() {
 	if len(o.note) == 0 {
@@ -846,12 +871,10 @@ func (o *Organizer) drawRenderedNote_() {
 
 // extractImageURLs extracts all image URLs from markdown
 func extractImageURLs(markdown string) []string {
-	matches := markdownImageRegex.FindAllStringSubmatch(markdown, -1)
-	urls := make([]string, 0, len(matches))
-	for _, match := range matches {
-		if len(match) > 2 {
-			urls = append(urls, match[2]) // match[2] is the URL
-		}
+	images := extractImages(markdown)
+	urls := make([]string, 0, len(images))
+	for _, img := range images {
+		urls = append(urls, img.url)
 	}
 	return urls
 }
@@ -937,22 +960,21 @@ func prepareKittyImage(url string) *preparedImage {
 
 // transmitPreparedKittyImage transmits (or reuses) a prepared image and returns imageID, cols, rows.
 // Must be called in render order to keep Glamour's lookup ordering intact.
-func transmitPreparedKittyImage(prep *preparedImage, maxCols int) (uint32, int, int) {
+func transmitPreparedKittyImage(prep *preparedImage, attrs imageAttrs, maxCols int) (uint32, int, int) {
 	if prep == nil || prep.err != nil || prep.imgW == 0 || prep.imgH == 0 {
 		return 0, 0, 0
 	}
 
-	targetCols := maxCols - 2
-	if targetCols <= 0 || targetCols > app.imageScale {
-		targetCols = app.imageScale
-	}
-	rows := int(float64(prep.imgH) / float64(prep.imgW) * float64(targetCols) * 0.42)
-	if rows < 1 {
-		rows = 1
-	}
+	targetCols, rows := imageCells(prep.imgW, prep.imgH, attrs, maxCols, 0.42)
 
 	isTmux := IsTmuxScreen()
 
+	// A virtual placement's size belongs to its image, so an image sized by
+	// its attributes is transmitted under its own ID, kept for the session
+	if attrs.sized() {
+		return transmitSizedKittyImage(prep, imageKey(prep.url, attrs), targetCols, rows, isTmux)
+	}
+
 	// Reuse if fingerprint matches cache
 	if prep.cachedImageID != 0 && prep.cachedFingerprint != "" {
 		kittySessionImageMux.RLock()
@@ -999,6 +1021,30 @@ func transmitPreparedKittyImage(prep *preparedImage, maxCols int) (uint32, int,
 	return imageID, targetCols, rows
 }
 
+// transmitSizedKittyImage transmits (or reuses) an image sized by its attributes
+func transmitSizedKittyImage(prep *preparedImage, key string, cols, rows int, isTmux bool) (uint32, int, int) {
+	imageID := nextSmallKittyID(key)
+
+	kittySessionImageMux.RLock()
+	entry, ok := kittySessionImages[imageID]
+	kittySessionImageMux.RUnlock()
+	if ok && entry.fingerprint == prep.cachedFingerprint && entry.confirmed {
+		_ = kittyUpdateVirtualPlacement(imageID, cols, rows, isTmux)
+		return imageID, cols, rows
+	}
+
+	if err := kittyTransmitActualImage(prep.data, imageID, cols, rows, isTmux); err != nil {
+		return 0, 0, 0
+	}
+	kittySessionImageMux.Lock()
+	kittySessionImages[imageID] = kittySessionEntry{
+		fingerprint: prep.cachedFingerprint,
+		confirmed:   true,
+	}
+	kittySessionImageMux.Unlock()
+	return imageID, cols, rows
+}
+
 // replaceImagesWithPlaceholders replaces glamour's image output with kitty placeholders
 
 // kittyTransmitImageToStdout transmits image data directly to stdout using kitty protocol
@@ -1304,10 +1350,11 @@ func kittyImageCacheLookup(url string) (uint32, int, int, bool) {
 	return 0, 0, 0, false
 }
 
-// replaceKittyImageMarkers converts glamour's text markers into actual Unicode placeholder grids
+// replaceKittyImageMarkers converts glamour's text markers into the image protocol's
+// placeholders (Unicode placeholder grids for kitty, reserved rows for sixel and iTerm2)
 // Markers have format: [KITTY_IMAGE:id=42,cols=30,rows=15]
 // If app.showImageInfo is true, prepends Google Drive folder/filename above each image
-func replaceKittyImageMarkers(text string) string {
+func replaceKittyImageMarkers(text string, protocol imageProtocol) string {
 	markerRegex := regexp.MustCompile(`\[KITTY_IMAGE:id=(\d+),cols=(\d+),rows=(\d+)\]`)
 
 	return markerRegex.ReplaceAllStringFunc(text, func(match string) string {
@@ -1327,9 +1374,6 @@ func replaceKittyImageMarkers(text string) string {
 			return match
 		}
 
-		// For virtual placements (a=T,U=1), placementID = imageID
-		placementID := uint32(imageID)
-
 		if debugLog, err := os.OpenFile("kitty_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666); err == nil {
 			fmt.Fprintf(debugLog, "REPLACE: Converting marker to grid: id=%d, cols=%d, rows=%d\n", imageID, cols, rows)
 			debugLog.Close()
@@ -1344,8 +1388,12 @@ func replaceKittyImageMarkers(text string) string {
 			result.WriteString(infoLine)
 		}
 
-		// Generate the Unicode placeholder grid
-		grid := buildPlaceholderGrid(uint32(imageID), placementID, cols, rows)
+		// Generate the placeholder grid, indented if the image is aligned
+		grid := protocol.placeholder(uint32(imageID), cols, rows)
+		currentRenderImageMux.RLock()
+		align := currentRenderImageAttrs[uint32(imageID)].align
+		currentRenderImageMux.RUnlock()
+		grid = alignImage(grid, cols, align, app.Screen.totaleditorcols-PREVIEW_RIGHT_PADDING)
 		result.WriteString(grid)
 
 		if debugLog, err := os.OpenFile("kitty_debug.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666); err == nil {
@@ -1399,7 +1447,7 @@ func getImageInfoLine(imageID uint32) string {
 }
 
 // loadImageForGlamour loads images for glamour's kitty renderer
-// It handles local files, Google Drive, and web URLs
+// It handles attachments, local files, Google Drive, and web URLs
 func loadImageForGlamour(url string) (image.Image, error) {
 	parsed, _ := net_url.Parse(url)
 	maxW := int(app.Screen.ws.Xpixel)
@@ -1414,6 +1462,11 @@ func loadImageForGlamour(url string) (image.Image, error) {
 	isHTTP := parsed.Scheme == "http" || parsed.Scheme == "https"
 	isFile := parsed.Scheme == "file"
 
+	// Attachments stored in the database
+	if id, ok := attachmentID(url); ok {
+		return loadAttachmentImage(id, maxW, maxH)
+	}
+
 	// Try Google Drive first if we can extract a file ID
 	if id, err := ExtractFileID(url); err == nil && id != "" {
 		img, _, gErr := loadGoogleImage(url, maxW, maxH)
@@ -1497,11 +1550,11 @@ func (o *Organizer) drawNoticeLayer() {
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+6, o.Screen.divider+7)
 
 	// \x1b[ 2*x is DECSACE to operate in rectable mode
-	// \x1b[%d;%d;%d;%d;48;5;235$r is DECCARA to apply specified attributes (background color 235) to rectangle area
+	// \x1b[%d;%d;%d;%d;%s$r is DECCARA to apply specified attributes (the notice box's style) to rectangle area
 	// \x1b[ *x is DECSACE to exit rectangle mode
-	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;48;5;235$r\x1b[*x",
-		TOP_MARGIN+6, o.Screen.divider+7, TOP_MARGIN+4+length, o.Screen.divider+7+width)
-	ab.WriteString("\x1b[48;5;235m") //draws the box lines with same background as above rectangle
+	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;%s$r\x1b[*x",
+		TOP_MARGIN+6, o.Screen.divider+7, TOP_MARGIN+4+length, o.Screen.divider+7+width, sgrParams(app.theme.UI.NoticeBox))
+	ab.WriteString(app.theme.UI.NoticeBox) //draws the box lines with same background as above rectangle
 	fmt.Print(ab.String())
 	o.drawNoticeBox()
 }
@@ -1517,32 +1570,32 @@ func (o *Organizer) drawNoticeBox() {
 
 	ab.WriteString("\x1b(0") // Enter line drawing mode
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, o.Screen.divider+6)
-	ab.WriteString("\x1b[37;1ml") //upper left corner
+	ab.WriteString(app.theme.UI.Border + "l") //upper left corner
 
 	for i := 1; i < length; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5+i, o.Screen.divider+6)
 		// x=0x78 vertical line (q=0x71 is horizontal) 37=white; 1m=bold (only need 1 m)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 	}
 
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+4+length, o.Screen.divider+6)
 	ab.WriteString("\x1b[1B")
-	ab.WriteString("\x1b[37;1mm") //lower left corner
+	ab.WriteString(app.theme.UI.Border + "m") //lower left corner
 
 	move_cursor = fmt.Sprintf("\x1b[1D\x1b[%dB", length)
 
 	for i := 1; i < width+1; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, o.Screen.divider+6+i)
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 	}
 
-	ab.WriteString("\x1b[37;1mj") //lower right corner
+	ab.WriteString(app.theme.UI.Border + "j") //lower right corner
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, o.Screen.divider+7+width)
-	ab.WriteString("\x1b[37;1mk") //upper right corner
+	ab.WriteString(app.theme.UI.Border + "k") //upper right corner
 
 	//exit line drawing mode
 	ab.WriteString("\x1b(B")
@@ -1571,6 +1624,8 @@ func (o *Organizer) drawNoticeText() {
 	fmt.Print(RESET) //sometimes there is an unclosed escape sequence
 }
 func nextSmallKittyID(url string) uint32 {
+	kittyIDMux.Lock()
+	defer kittyIDMux.Unlock()
 	if id, ok := kittyIDMap[url]; ok {
 		return id
 	}
@@ -1584,3 +1639,20 @@ func nextSmallKittyID(url string) uint32 {
 	kittyIDReverse[id] = url
 	return id
 }
+
+// smallKittyID returns the small kitty ID assigned to url, if there is one
+func smallKittyID(url string) (uint32, bool) {
+	kittyIDMux.Lock()
+	defer kittyIDMux.Unlock()
+	id, ok := kittyIDMap[url]
+	return id, ok
+}
+
+// resetSmallKittyIDs forgets the small kitty IDs assigned so far
+func resetSmallKittyIDs() {
+	kittyIDMux.Lock()
+	defer kittyIDMux.Unlock()
+	kittyIDMap = make(map[string]uint32)
+	kittyIDReverse = make(map[uint32]string)
+	kittyIDNext = 1
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sections of the organizer's preview can be collapsed so long notes, like
+// research notes, can be skimmed: :fold [level] collapses the sections at
+// that level and below, :unfold expands them all and <space> in :outline
+// toggles one. A collapsed section shows only its heading. The folds last
+// until the preview is rendered again.
+
+// previewLines returns the preview as drawn, without the rows of collapsed
+// sections
+func (o *Organizer) previewLines() []string {
+	if len(o.previewFolds) == 0 {
+		return o.note
+	}
+	lines := make([]string, 0, len(o.note))
+	for r := 0; r < len(o.note); r++ {
+		end, ok := o.previewFolds[r]
+		if !ok {
+			lines = append(lines, o.note[r])
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s%s%s ··· %d lines%s", o.note[r], RESET, app.theme.UI.Fold, end-r, RESET))
+		r = end
+	}
+	return lines
+}
+
+// previewRow returns the row of previewLines that shows row of the note's
+// rendering, expanding any collapsed section it is in
+func (o *Organizer) previewRow(row int) int {
+	for start, end := range o.previewFolds {
+		if row > start && row <= end {
+			delete(o.previewFolds, start)
+		}
+	}
+	visible := 0
+	for r := 0; r < row; r++ {
+		if end, ok := o.previewFolds[r]; ok {
+			r = end
+		}
+		visible++
+	}
+	return visible
+}
+
+// previewSection returns the rendered rows of the section of headings[i],
+// leaving out the blank rows before the next heading; ok is false if the
+// section has no rows below its heading
+func (o *Organizer) previewSection(headings []heading, rows []int, i int) (start, end int, ok bool) {
+	start, end = rows[i], len(o.note)-1
+	if start == -1 {
+		return 0, 0, false
+	}
+	for j := i + 1; j < len(headings); j++ {
+		if headings[j].level <= headings[i].level && rows[j] != -1 {
+			end = rows[j] - 1
+			break
+		}
+	}
+	for end > start && strings.TrimSpace(stripANSI(o.note[end])) == "" {
+		end--
+	}
+	return start, end, end > start
+}
+
+func (o *Organizer) previewHeadings() ([]heading, []int) {
+	headings := markdownHeadings(strings.Split(o.Database.readNoteIntoString(o.getId()), "\n"))
+	return headings, o.renderedHeadingRows(headings)
+}
+
+// foldPreview collapses the preview's sections with headings at the given
+// level or below (:fold [level])
+func (o *Organizer) foldPreview(pos int) {
+	defer func() {
+		o.command_line = ""
+		o.mode = NORMAL
+	}()
+	if o.view != TASK {
+		o.ShowMessage(BL, "Only a note's preview can be folded")
+		return
+	}
+	level := 1
+	if pos != -1 {
+		var err error
+		if level, err = strconv.Atoi(strings.TrimSpace(o.command_line[pos+1:])); err != nil || level < 1 || level > 6 {
+			o.ShowMessage(BL, "The level must be a number from 1 to 6")
+			return
+		}
+	}
+	headings, rows := o.previewHeadings()
+	o.previewFolds = make(map[int]int)
+	for i, h := range headings {
+		if h.level < level {
+			continue
+		}
+		if start, end, ok := o.previewSection(headings, rows, i); ok {
+			o.previewFolds[start] = end
+		}
+	}
+	if len(o.previewFolds) == 0 {
+		o.ShowMessage(BL, "No sections to collapse at level %d", level)
+		return
+	}
+	o.altRowoff = 0
+	o.Screen.eraseRightScreen()
+	o.drawRenderedNote()
+	o.ShowMessage(BL, "Collapsed %d sections", len(o.previewFolds))
+}
+
+// unfoldPreview expands all the preview's sections (:unfold)
+func (o *Organizer) unfoldPreview(_ int) {
+	o.command_line = ""
+	o.mode = NORMAL
+	o.previewFolds = nil
+	o.altRowoff = 0
+	o.Screen.eraseRightScreen()
+	o.drawRenderedNote()
+}
+
+// togglePreviewFold collapses or expands the section of the index-th
+// heading in :outline
+func (o *Organizer) togglePreviewFold(index int) {
+	rows := o.renderedHeadingRows(o.outlineHeadings)
+	start, end, ok := o.previewSection(o.outlineHeadings, rows, index)
+	if !ok {
+		o.ShowMessage(BL, "The section can't be collapsed")
+		return
+	}
+	if _, closed := o.previewFolds[start]; closed {
+		delete(o.previewFolds, start)
+	} else {
+		if o.previewFolds == nil {
+			o.previewFolds = make(map[int]int)
+		}
+		o.previewFolds[start] = end
+	}
+	// altRowoff is scrolling the outline; the preview's is saved
+	noticeRowoff := o.altRowoff
+	o.altRowoff = o.outlineRowoff
+	o.Screen.eraseRightScreen()
+	o.drawRenderedNote()
+	o.outlineRowoff, o.altRowoff = o.altRowoff, noticeRowoff
+	o.drawOutline()
+}
@@ -193,12 +193,13 @@ func (o *Organizer) switchToEditorMode() {
 
 // for scrolling terminal markdown rendered note
 func (o *Organizer) scrollPreviewDown() {
-	if o.altRowoff == len(o.note)-1 {
+	lines := len(o.previewLines())
+	if o.altRowoff >= lines-1 {
 		o.ShowMessage(BL, "Reached end of rendered note")
 		return
 	}
 	o.altRowoff++
-	o.ShowMessage(BL, "Line %d of %d", o.altRowoff, len(o.note))
+	o.ShowMessage(BL, "Line %d of %d", o.altRowoff, lines)
 	o.Screen.eraseRightScreen()
 	o.drawRenderedNote()
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+)
+
+// The organizer's :outline lists the headings of the selected note in the
+// notice box; <cr> scrolls the preview to the selected heading, <space>
+// collapses or expands its section in the preview and q puts the preview
+// back where it was.
+
+// outline shows the headings of the selected note (:outline)
+func (o *Organizer) outline(_ int) {
+	o.command_line = ""
+	if o.view != TASK {
+		o.ShowMessage(BL, "The outline is only available for notes")
+		o.mode = NORMAL
+		return
+	}
+	headings := markdownHeadings(strings.Split(o.Database.readNoteIntoString(o.getId()), "\n"))
+	if len(headings) == 0 {
+		o.ShowMessage(BL, "The note has no headings")
+		o.mode = NORMAL
+		return
+	}
+	o.outlineHeadings = headings
+	o.outlineIndex = 0
+	o.outlineRowoff = o.altRowoff
+	o.altRowoff = 0
+	o.mode = OUTLINE
+	o.drawOutline()
+	o.ShowMessage(BL, "j/k select, <cr> go to heading, <space> collapse/expand, q quit")
+}
+
+func (o *Organizer) drawOutline() {
+	o.notice = outlineRows(o.outlineHeadings, o.outlineIndex, o.Screen.totaleditorcols-NOTICE_RIGHT_PADDING)
+	// the notice box shows at most textLines-12 rows; keep the selection in view
+	visible := o.Screen.textLines - 12
+	if visible < 1 {
+		visible = 1
+	}
+	if o.outlineIndex < o.altRowoff {
+		o.altRowoff = o.outlineIndex
+	} else if o.outlineIndex >= o.altRowoff+visible {
+		o.altRowoff = o.outlineIndex - visible + 1
+	}
+	o.drawNoticeLayer()
+	o.drawNoticeText()
+}
+
+// case OUTLINE:
+func (o *Organizer) OutlineModeKeyHandler(c int) RedrawScope {
+	switch c {
+	case ARROW_DOWN, 'j':
+		if o.outlineIndex < len(o.outlineHeadings)-1 {
+			o.outlineIndex++
+		}
+		o.drawOutline()
+	case ARROW_UP, 'k':
+		if o.outlineIndex > 0 {
+			o.outlineIndex--
+		}
+		o.drawOutline()
+	case ' ':
+		o.togglePreviewFold(o.outlineIndex)
+	case '\r':
+		o.altRowoff = 0
+		if row := o.renderedHeadingRows(o.outlineHeadings)[o.outlineIndex]; row != -1 {
+			o.altRowoff = o.previewRow(row)
+		}
+		o.mode = NORMAL
+		o.Screen.eraseRightScreen()
+		o.drawRenderedNote()
+		o.ShowMessage(BL, "")
+	case 'q':
+		o.altRowoff = o.outlineRowoff
+		o.mode = NORMAL
+		o.Screen.eraseRightScreen()
+		o.drawRenderedNote()
+		o.ShowMessage(BL, "")
+	}
+	return RedrawNone
+}
+
+// renderedHeadingRows returns the row of the preview that shows each
+// heading, or -1 if it can't be found. The preview is rendered, so a
+// heading is found by its text, searching past the rows of the headings
+// before it.
+func (o *Organizer) renderedHeadingRows(headings []heading) []int {
+	rows := make([]int, len(headings))
+	next := 0
+	for i, h := range headings {
+		rows[i] = -1
+		text := plainHeading(h.text)
+		if text == "" {
+			continue
+		}
+		for r := next; r < len(o.note); r++ {
+			if strings.Contains(plainHeading(stripANSI(o.note[r])), text) {
+				rows[i], next = r, r+1
+				break
+			}
+		}
+	}
+	return rows
+}
+
+// plainHeading drops markup that is rendered away, for matching headings
+func plainHeading(s string) string {
+	s = strings.NewReplacer("*", "", "_", "", "`", "").Replace(s)
+	return strings.ToLower(strings.TrimSpace(s))
+}
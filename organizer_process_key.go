@@ -44,6 +44,10 @@ func (o *Organizer) organizerProcessKey(c int) (redraw RedrawScope) {
 		redraw = o.NavigateNoticeModeKeyHandler(c)
 	case CONTAINER:
 		redraw = o.NavigateContainerModeKeyHandler(c)
+	case OUTLINE:
+		redraw = o.OutlineModeKeyHandler(c)
+	case RECOVERY:
+		redraw = o.RecoveryModeKeyHandler(c)
 	default:
 		return
 	}
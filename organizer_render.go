@@ -16,6 +16,7 @@ type RenderRequest struct {
 	NoteID    int    // Database ID of note
 	Markdown  string // Note content
 	MaxCols   int    // Maximum columns for rendering
+	Settings  renderSettings
 	CancelCh  chan struct{}
 	CreatedAt time.Time
 }
@@ -38,6 +39,10 @@ type RenderManager struct {
 	// Channel for render results
 	resultCh chan RenderResult
 
+	// Rendered previews, and the notes whose previews to render ahead
+	cache      *renderCache
+	prefetchCh chan prefetchRequest
+
 	// Lifecycle
 	running bool
 	stopCh  chan struct{}
@@ -51,6 +56,13 @@ func NewRenderManager(app *App) *RenderManager {
 		organizer: app.Organizer,
 		resultCh:  make(chan RenderResult, 5),
 		stopCh:    make(chan struct{}),
+
+		prefetchCh: make(chan prefetchRequest, 1),
+	}
+	if app.Config != nil {
+		rm.cache = newRenderCache(app.Config.RenderCache.Entries, app.Config.RenderCache.Persist)
+	} else {
+		rm.cache = newRenderCache(0, false)
 	}
 
 	rm.start()
@@ -64,6 +76,10 @@ func (rm *RenderManager) start() {
 	// Start result handler
 	rm.wg.Add(1)
 	go rm.resultHandler()
+
+	// Start prefetcher
+	rm.wg.Add(1)
+	go rm.prefetcher()
 }
 
 // Stop shuts down the render manager
@@ -98,6 +114,7 @@ func (rm *RenderManager) Stop() {
 	}
 
 	close(rm.resultCh)
+	_ = rm.cache.save()
 }
 
 // StartRender initiates async rendering of a note
@@ -121,6 +138,7 @@ func (rm *RenderManager) StartRender(noteID int, markdown string, maxCols int) {
 		NoteID:    noteID,
 		Markdown:  markdown,
 		MaxCols:   maxCols,
+		Settings:  currentRenderSettings(),
 		CancelCh:  make(chan struct{}),
 		CreatedAt: time.Now(),
 	}
@@ -128,30 +146,38 @@ func (rm *RenderManager) StartRender(noteID int, markdown string, maxCols int) {
 	rm.mutex.Unlock()
 
 	// Check if images are enabled
-	if !app.kitty || !app.showImages {
+	if app.imageProtocol == nil || !app.showImages {
 		// No images mode - just render text
-		textLines := rm.renderTextOnly(markdown, maxCols)
+		textLines := rm.cachedTextOnly(markdown, maxCols, req.Settings)
 		rm.organizer.note = textLines
 		rm.organizer.drawRenderedNote()
 		return
 	}
 
 	// Check if there are any images in the markdown
-	imageURLs := extractImageURLs(markdown)
-	if len(imageURLs) == 0 {
+	images := extractImages(markdown)
+	if len(images) == 0 {
 		// No images in this note - just render text
-		textLines := rm.renderTextOnly(markdown, maxCols)
+		textLines := rm.cachedTextOnly(markdown, maxCols, req.Settings)
 		rm.organizer.note = textLines
 		rm.organizer.drawRenderedNote()
 		return
 	}
 
-	// Check if ALL images are in kitty's session cache
+	// Check if ALL images are cached by the image protocol
 	// If so, full render will be fast and we can skip the text-only phase
-	if rm.allImagesInKittyCache(imageURLs) {
+	if rm.allImagesCached(images) {
+		// A preview rendered with the same images can be shown as is
+		key := renderKey(markdown, maxCols, req.Settings, true)
+		if lines, ok := rm.cache.get(key); ok {
+			rm.organizer.note = lines
+			rm.organizer.drawRenderedNote()
+			return
+		}
 		// Fast path: all images cached, render directly with images
 		lines := rm.renderFullWithImages(req)
 		if lines != nil {
+			rm.cache.put(key, lines, true)
 			rm.organizer.note = lines
 			rm.organizer.drawRenderedNote()
 		}
@@ -160,56 +186,48 @@ func (rm *RenderManager) StartRender(noteID int, markdown string, maxCols int) {
 
 	// Slow path: some images need loading
 	// Phase 1: Render text-only immediately (no images)
-	textLines := rm.renderTextOnly(markdown, maxCols)
+	textLines := rm.cachedTextOnly(markdown, maxCols, req.Settings)
 
 	// Display text-only version immediately
 	rm.organizer.note = textLines
 	rm.organizer.drawRenderedNote()
 
 	// Show status that images are loading
-	rm.organizer.ShowMessage(BR, "Loading %d image(s)...", len(imageURLs))
+	rm.organizer.ShowMessage(BR, "Loading %d image(s)...", len(images))
 
 	// Phase 2: Start background goroutine for full render with images
 	go rm.backgroundRender(req)
 }
 
-// allImagesInKittyCache checks if all image URLs are in kitty's session cache
+// allImagesCached checks if all images are cached by the image protocol at their sizes
 // This means they can be reused without network/disk loading
-func (rm *RenderManager) allImagesInKittyCache(imageURLs []string) bool {
-	if globalImageCache == nil {
-		return false
-	}
-
-	for _, url := range imageURLs {
-		// Check if we have cached metadata with a valid image ID
-		entry, ok := globalImageCache.GetKittyMeta(url)
-		if !ok || entry.ImageID == 0 {
-			return false
-		}
-
-		// Check if this image ID is in the kitty session cache
-		kittySessionImageMux.RLock()
-		sessionEntry, inSession := kittySessionImages[entry.ImageID]
-		kittySessionImageMux.RUnlock()
-
-		if !inSession {
-			return false
-		}
-
-		// Check if the session entry is confirmed or we trust the cache
-		if !sessionEntry.confirmed && !trustKittyCache {
+func (rm *RenderManager) allImagesCached(images []markdownImage) bool {
+	for _, img := range images {
+		if !app.imageProtocol.cached(img.url, img.attrs) {
 			return false
 		}
 	}
-
 	return true
 }
 
+// cachedTextOnly returns the preview of markdown without images, from the
+// render cache if it is there
+func (rm *RenderManager) cachedTextOnly(markdown string, maxCols int, s renderSettings) []string {
+	key := renderKey(markdown, maxCols, s, false)
+	if lines, ok := rm.cache.get(key); ok {
+		return lines
+	}
+	lines := rm.renderTextOnly(markdown, maxCols, s)
+	rm.cache.put(key, lines, false)
+	return lines
+}
+
 // renderTextOnly renders markdown without images (fast path)
-func (rm *RenderManager) renderTextOnly(markdown string, maxCols int) []string {
+func (rm *RenderManager) renderTextOnly(markdown string, maxCols int, s renderSettings) []string {
 	// Render markdown without kitty images
+	markdown = stripImageAttrs(markdown)
 	options := []glamour.TermRendererOption{
-		glamour.WithStylePath(getGlamourStylePath()),
+		glamour.WithStylePath(s.style),
 		glamour.WithWordWrap(0),
 		// Note: NOT enabling kitty images here
 	}
@@ -230,8 +248,8 @@ func (rm *RenderManager) renderTextOnly(markdown string, maxCols int) []string {
 	note = ansi.DecodeKittyTextSizeMarkers(note)
 
 	// Handle search highlighting
-	if rm.organizer.taskview == BY_FIND {
-		note = strings.ReplaceAll(note, "qx", "\x1b[48;5;31m")
+	if s.find {
+		note = strings.ReplaceAll(note, "qx", s.highlight)
 		note = strings.ReplaceAll(note, "qy", "\x1b[0m")
 	}
 
@@ -254,6 +272,9 @@ func (rm *RenderManager) backgroundRender(req *RenderRequest) {
 	// Perform the full render using existing renderMarkdown logic
 	// This includes image loading, transmission, and proper placeholder sizing
 	lines := rm.renderFullWithImages(req)
+	if lines != nil {
+		rm.cache.put(renderKey(req.Markdown, req.MaxCols, req.Settings, true), lines, true)
+	}
 
 	// Check for cancellation before sending result
 	select {
@@ -291,21 +312,23 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 	default:
 	}
 
-	// Pre-transmit kitty images (same logic as renderMarkdown)
-	if app.kitty && app.kittyPlace && app.showImages {
-		seedKittySessionFromCache()
+	// Pre-transmit images (same logic as renderMarkdown)
+	protocol := app.imageProtocol
+	if protocol != nil && app.showImages {
+		protocol.beginRender()
 
 		// Clear the dimension map for this render
 		currentRenderImageMux.Lock()
 		currentRenderImageDims = make(map[uint32]struct{ cols, rows int })
 		currentRenderImageURLs = make(map[uint32]string)
+		currentRenderImageAttrs = make(map[uint32]imageAttrs)
 		nextImageLookupID = 1
 		currentRenderImageOrder = currentRenderImageOrder[:0]
 		currentRenderOrderIdx = 0
 		currentRenderImageMux.Unlock()
 
-		imageURLs := extractImageURLs(markdown)
-		if len(imageURLs) > 0 {
+		images := extractImages(markdown)
+		if len(images) > 0 {
 			// Check cancellation before image loading
 			select {
 			case <-req.CancelCh:
@@ -321,7 +344,7 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 			}
 
 			jobCh := make(chan job)
-			resCh := make(chan result, len(imageURLs))
+			resCh := make(chan result, len(images))
 			var wg sync.WaitGroup
 
 			workers := 6
@@ -343,10 +366,10 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 
 			// Deduplicate URLs
 			seen := make(map[string]bool)
-			for _, url := range imageURLs {
-				if !seen[url] {
-					seen[url] = true
-					jobCh <- job{url: url}
+			for _, img := range images {
+				if !seen[img.url] {
+					seen[img.url] = true
+					jobCh <- job{url: img.url}
 				}
 			}
 			close(jobCh)
@@ -370,14 +393,15 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 			}
 
 			// Ordered transmit to keep kitty IDs aligned with markdown order
-			for _, url := range imageURLs {
-				prep := preparedMap[url]
-				imageID, cols, rows := transmitPreparedKittyImage(prep, o.Screen.totaleditorcols-PREVIEW_RIGHT_PADDING)
+			for _, img := range images {
+				prep := preparedMap[img.url]
+				imageID, cols, rows := protocol.transmit(prep, img.attrs, o.Screen.totaleditorcols-PREVIEW_RIGHT_PADDING)
 				if imageID != 0 {
 					currentRenderImageMux.Lock()
 					currentRenderImageDims[imageID] = struct{ cols, rows int }{cols, rows}
 					currentRenderImageOrder = append(currentRenderImageOrder, imageID)
-					currentRenderImageURLs[imageID] = url
+					currentRenderImageURLs[imageID] = img.url
+					currentRenderImageAttrs[imageID] = img.attrs
 					currentRenderImageMux.Unlock()
 				}
 			}
@@ -397,16 +421,16 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 		glamour.WithWordWrap(0),
 	}
 
-	if app.kitty && app.kittyPlace && app.showImages {
+	if protocol != nil && app.showImages {
 		options = append(options, glamour.WithKittyImages(true, kittyImageCacheLookup))
 	}
 
 	r, _ := glamour.NewTermRenderer(options...)
-	note, _ := r.Render(markdown)
+	note, _ := r.Render(stripImageAttrs(markdown))
 
-	// Replace glamour's text markers with actual Unicode placeholder grids
-	if app.kitty && app.kittyPlace && app.showImages {
-		note = replaceKittyImageMarkers(note)
+	// Replace glamour's text markers with the protocol's placeholders
+	if protocol != nil && app.showImages {
+		note = replaceKittyImageMarkers(note, protocol)
 	}
 
 	note = strings.TrimSpace(note)
@@ -416,7 +440,7 @@ func (rm *RenderManager) renderFullWithImages(req *RenderRequest) []string {
 
 	// Handle search highlighting
 	if o.taskview == BY_FIND {
-		note = strings.ReplaceAll(note, "qx", "\x1b[48;5;31m")
+		note = strings.ReplaceAll(note, "qx", app.theme.UI.SearchHighlight)
 		note = strings.ReplaceAll(note, "qy", "\x1b[0m")
 	}
 
@@ -460,6 +484,7 @@ func (rm *RenderManager) resultHandler() {
 				// Erase the right screen before displaying the new content
 				o.Screen.eraseRightScreen()
 				o.note = result.RenderedLines
+				o.previewFolds = nil
 				// Clear the loading message
 				o.ShowMessage(BR, "")
 				// Trigger redraw via notification
@@ -468,3 +493,47 @@ func (rm *RenderManager) resultHandler() {
 		}
 	}
 }
+
+// prefetchRequest asks for the previews of notes to be rendered ahead
+type prefetchRequest struct {
+	noteIDs  []int
+	maxCols  int
+	settings renderSettings
+}
+
+// Prefetch renders the previews of the notes next to the current one in
+// the background so moving to them shows them at once. Notes with images
+// get their text preview; their images load when they are shown.
+func (rm *RenderManager) Prefetch(noteIDs []int, maxCols int) {
+	req := prefetchRequest{noteIDs: noteIDs, maxCols: maxCols, settings: currentRenderSettings()}
+	// only the latest request matters
+	select {
+	case <-rm.prefetchCh:
+	default:
+	}
+	select {
+	case rm.prefetchCh <- req:
+	default:
+	}
+}
+
+// prefetcher renders the previews Prefetch asks for
+func (rm *RenderManager) prefetcher() {
+	defer rm.wg.Done()
+
+	for {
+		select {
+		case <-rm.stopCh:
+			return
+		case req := <-rm.prefetchCh:
+			for _, id := range req.noteIDs {
+				o := rm.organizer
+				if id < 1 || o.Database.taskFolder(id) == "code" {
+					continue
+				}
+				markdown := o.previewMarkdown(id, req.settings.find)
+				rm.cachedTextOnly(markdown, req.maxCols, req.settings)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/slzatz/vimango/auth"
+)
+
+// :pasteimage takes the image on the clipboard, scales it down to the
+// :cachewidth width, stores it as an attachment (or uploads it to the
+// Google Drive folder images.paste_drive_folder when images.paste_to is
+// "gdrive") and inserts the markdown image at the cursor. The clipboard is
+// read by the first clipboardImageProvider that is available, or by
+// images.paste_command if it is set.
+
+// clipboardImageProvider reads an image from the system clipboard
+type clipboardImageProvider interface {
+	String() string
+	// available reports whether the provider can be used here
+	available() bool
+	// read returns the clipboard image and its mime type
+	read() ([]byte, string, error)
+}
+
+// clipboardImageProviders are tried in order
+var clipboardImageProviders = []clipboardImageProvider{
+	&clipboardCommand{
+		name:  "wl-paste",
+		env:   "WAYLAND_DISPLAY",
+		types: []string{"wl-paste", "--list-types"},
+		paste: []string{"wl-paste", "--no-newline", "--type", "{type}"},
+	},
+	&clipboardCommand{
+		name:  "xclip",
+		env:   "DISPLAY",
+		types: []string{"xclip", "-selection", "clipboard", "-t", "TARGETS", "-o"},
+		paste: []string{"xclip", "-selection", "clipboard", "-t", "{type}", "-o"},
+	},
+	&clipboardCommand{
+		name:  "pngpaste",
+		paste: []string{"pngpaste", "-"},
+	},
+}
+
+// clipboardImageTypes are the clipboard types that are pasted, best first
+var clipboardImageTypes = []string{"image/png", "image/jpeg"}
+
+// clipboardTimeout bounds a clipboard tool, which can hang when the
+// clipboard owner doesn't answer
+const clipboardTimeout = 5 * time.Second
+
+// errNoClipboardImage is returned when the clipboard doesn't hold an image
+var errNoClipboardImage = errors.New("the clipboard doesn't contain a png or jpeg image")
+
+// clipboardCommand reads the clipboard with a command line tool
+type clipboardCommand struct {
+	name  string
+	env   string   // environment variable the tool needs, if any
+	types []string // lists the clipboard's types; nil if paste is always png
+	paste []string // writes the clipboard as {type} to stdout
+}
+
+func (c *clipboardCommand) String() string { return c.name }
+
+func (c *clipboardCommand) available() bool {
+	if c.env != "" && os.Getenv(c.env) == "" {
+		return false
+	}
+	_, err := exec.LookPath(c.paste[0])
+	return err == nil
+}
+
+func (c *clipboardCommand) read() ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+
+	mimeType := "image/png"
+	if c.types != nil {
+		out, err := exec.CommandContext(ctx, c.types[0], c.types[1:]...).Output()
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %v", c.name, err)
+		}
+		mimeType = ""
+		offered := strings.Fields(string(out))
+		for _, t := range clipboardImageTypes {
+			for _, o := range offered {
+				if o == t && mimeType == "" {
+					mimeType = t
+				}
+			}
+		}
+		if mimeType == "" {
+			return nil, "", errNoClipboardImage
+		}
+	}
+
+	args := make([]string, len(c.paste))
+	for i, arg := range c.paste {
+		args[i] = strings.ReplaceAll(arg, "{type}", mimeType)
+	}
+	data, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", c.name, err)
+	}
+	if len(data) == 0 {
+		return nil, "", errNoClipboardImage
+	}
+	return data, mimeType, nil
+}
+
+// clipboardShellCommand reads the clipboard with images.paste_command,
+// which writes a png or jpeg image to stdout
+type clipboardShellCommand string
+
+func (c clipboardShellCommand) String() string { return string(c) }
+
+func (c clipboardShellCommand) available() bool { return c != "" }
+
+func (c clipboardShellCommand) read() ([]byte, string, error) {
+	data, err := shellCommand(string(c)).Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", c, err)
+	}
+	mimeType := detectMime("", data)
+	if mimeType != "image/png" && mimeType != "image/jpeg" {
+		return nil, "", errNoClipboardImage
+	}
+	return data, mimeType, nil
+}
+
+// readClipboardImage returns the image on the clipboard and its mime type
+func readClipboardImage() ([]byte, string, error) {
+	providers := clipboardImageProviders
+	if app.Config != nil && app.Config.Images.PasteCommand != "" {
+		providers = []clipboardImageProvider{clipboardShellCommand(app.Config.Images.PasteCommand)}
+	}
+	for _, p := range providers {
+		if p.available() {
+			return p.read()
+		}
+	}
+	return nil, "", errors.New("no clipboard tool found (install wl-paste, xclip or pngpaste, or set images.paste_command)")
+}
+
+// scaleClipboardImage scales an image down to maxWidth pixels wide,
+// keeping its format; images that are narrow enough are returned as is
+func scaleClipboardImage(data []byte, mimeType string, maxWidth int) ([]byte, error) {
+	img, _, err := decodeImageWithOrientation(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if maxWidth <= 0 || img.Bounds().Dx() <= maxWidth {
+		return data, nil
+	}
+	img = imaging.Resize(img, maxWidth, 0, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pasteImage inserts the image on the clipboard at the cursor (:pasteimage)
+func (e *Editor) pasteImage() {
+	data, mimeType, err := readClipboardImage()
+	if err != nil {
+		e.ShowMessage(BR, "%v", err)
+		return
+	}
+	data, err = scaleClipboardImage(data, mimeType, app.imageCacheMaxWidth)
+	if err != nil {
+		e.ShowMessage(BR, "Error reading clipboard image: %v", err)
+		return
+	}
+
+	name := "pasted-" + time.Now().Format("20060102-150405")
+	var link string
+	if app.Config != nil && app.Config.Images.PasteTo == "gdrive" {
+		if app.Session.googleDrive == nil {
+			e.ShowMessage(BR, "Google Drive is not configured (see README: Google Drive Setup)")
+			return
+		}
+		ext := ".png"
+		if mimeType == "image/jpeg" {
+			ext = ".jpg"
+		}
+		id, err := auth.UploadFile(app.Session.googleDrive, name+ext, mimeType, data, app.Config.Images.PasteDriveFolder)
+		if err != nil {
+			e.ShowMessage(BR, "Error uploading image to Google Drive: %v", err)
+			return
+		}
+		link = fmt.Sprintf("![%s](gdrive:%s)", name, id)
+	} else {
+		id, err := e.Database.insertAttachment(mimeType, data)
+		if err != nil {
+			e.ShowMessage(BR, "Error storing attachment: %v", err)
+			return
+		}
+		link = attachmentMarkdown(name, mimeType, id)
+	}
+
+	e.insertAtCursor(link)
+	e.ShowMessage(BR, "Pasted %s image (%d KB)", mimeType, len(data)>>10)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// encodedTestImage returns a w by h image encoded as mimeType
+func encodedTestImage(t *testing.T, w, h int, mimeType string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScaleClipboardImage(t *testing.T) {
+	tests := []struct {
+		mimeType      string
+		width, height int
+		maxWidth      int
+		wantWidth     int
+		wantHeight    int
+		wantFormat    string
+		wantUnchanged bool
+	}{
+		{"image/png", 40, 20, 100, 40, 20, "png", true},
+		{"image/png", 40, 20, 40, 40, 20, "png", true},
+		{"image/png", 40, 20, 0, 40, 20, "png", true},
+		{"image/png", 200, 100, 50, 50, 25, "png", false},
+		{"image/jpeg", 200, 100, 50, 50, 25, "jpeg", false},
+	}
+	for _, tt := range tests {
+		data := encodedTestImage(t, tt.width, tt.height, tt.mimeType)
+		got, err := scaleClipboardImage(data, tt.mimeType, tt.maxWidth)
+		if err != nil {
+			t.Errorf("scaleClipboardImage(%s %dx%d, %d) error: %v", tt.mimeType, tt.width, tt.height, tt.maxWidth, err)
+			continue
+		}
+		if unchanged := bytes.Equal(got, data); unchanged != tt.wantUnchanged {
+			t.Errorf("scaleClipboardImage(%s %dx%d, %d) unchanged = %v, want %v", tt.mimeType, tt.width, tt.height, tt.maxWidth, unchanged, tt.wantUnchanged)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(got))
+		if err != nil {
+			t.Errorf("scaleClipboardImage(%s %dx%d, %d) returned an undecodable image: %v", tt.mimeType, tt.width, tt.height, tt.maxWidth, err)
+			continue
+		}
+		if cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight || format != tt.wantFormat {
+			t.Errorf("scaleClipboardImage(%s %dx%d, %d) = %s %dx%d, want %s %dx%d", tt.mimeType, tt.width, tt.height, tt.maxWidth,
+				format, cfg.Width, cfg.Height, tt.wantFormat, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestScaleClipboardImageRejectsNonImages(t *testing.T) {
+	if _, err := scaleClipboardImage([]byte("not an image"), "image/png", 50); err == nil {
+		t.Error("scaleClipboardImage(text) returned no error")
+	}
+}
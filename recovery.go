@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Editors with unsaved changes are autosaved to the recovery table every
+// options.autosave seconds (30 by default, -1 turns it off) and when the
+// app quits, so a crash loses at most the last few seconds of typing. A
+// note's recovery row is removed when the note is written or its editor is
+// closed. At startup, recovery data newer than the saved note is listed as
+// by :recover: d shows the diff with the saved note, r opens the note with
+// the recovered text and x discards it.
+
+const defaultAutosaveSeconds = 30
+
+// autosaveInterval is the time between autosaves; zero turns autosave off
+func (a *App) autosaveInterval() time.Duration {
+	seconds := defaultAutosaveSeconds
+	if a.Config != nil && a.Config.Options.Autosave != 0 {
+		seconds = a.Config.Options.Autosave
+	}
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// autosave writes the text of each editor with unsaved changes to the
+// recovery table if it changed since the last autosave
+func (a *App) autosave() {
+	for _, e := range a.Session.Editors {
+		if e.id == -1 || !e.isModified() {
+			continue
+		}
+		text := strings.Join(e.vbuf.Lines(), "\n")
+		if text == e.autosaved {
+			continue
+		}
+		if err := a.Database.saveRecovery(e.id, text); err != nil {
+			e.ShowMessage(BR, "Autosave failed: %v", err)
+			continue
+		}
+		e.autosaved = text
+	}
+}
+
+// discardRecovery removes the editor's autosaved text once it is written
+// or closed
+func (e *Editor) discardRecovery() {
+	e.autosaved = ""
+	if e.id != -1 {
+		e.Database.deleteRecovery(e.id)
+	}
+}
+
+// offerRecovery lists the recovered notes at startup if there are any
+func (o *Organizer) offerRecovery() {
+	notes, err := o.Database.recoveredNotes()
+	if err != nil {
+		o.ShowMessage(BL, "Error reading recovered notes: %v", err)
+		return
+	}
+	if len(notes) == 0 {
+		return
+	}
+	o.showRecovery(notes)
+}
+
+// recover lists the notes with recovered unsaved changes (:recover)
+func (o *Organizer) recover(_ int) {
+	o.command_line = ""
+	o.mode = NORMAL
+	notes, err := o.Database.recoveredNotes()
+	if err != nil {
+		o.ShowMessage(BL, "Error reading recovered notes: %v", err)
+		return
+	}
+	if len(notes) == 0 {
+		o.ShowMessage(BL, "There are no recovered changes")
+		return
+	}
+	o.showRecovery(notes)
+}
+
+func (o *Organizer) showRecovery(notes []recoveredNote) {
+	o.recovered = notes
+	o.recoveryIndex = 0
+	o.recoveryDiff = false
+	o.altRowoff = 0
+	o.mode = RECOVERY
+	o.Screen.eraseRightScreen()
+	o.drawRecovery()
+}
+
+func (o *Organizer) drawRecovery() {
+	width := o.Screen.totaleditorcols - NOTICE_RIGHT_PADDING
+	o.notice = make([]string, len(o.recovered))
+	for i, r := range o.recovered {
+		row := fmt.Sprintf("%s  %s", r.modified, r.title)
+		if utf8.RuneCountInString(row) > width {
+			row = string([]rune(row)[:width])
+		}
+		if i == o.recoveryIndex {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		o.notice[i] = row
+	}
+	visible := o.Screen.textLines - 12
+	if visible < 1 {
+		visible = 1
+	}
+	if o.recoveryIndex < o.altRowoff {
+		o.altRowoff = o.recoveryIndex
+	} else if o.recoveryIndex >= o.altRowoff+visible {
+		o.altRowoff = o.recoveryIndex - visible + 1
+	}
+	o.drawNoticeLayer()
+	o.drawNoticeText()
+	o.ShowMessage(BL, "Unsaved changes were recovered for %d notes | j/k select, d diff, r restore, x discard, q quit",
+		len(o.recovered))
+}
+
+// drawRecoveryDiff shows the diff of the selected note's saved and
+// recovered text in the preview
+func (o *Organizer) drawRecoveryDiff() {
+	r := o.recovered[o.recoveryIndex]
+	saved := o.Database.readNoteIntoString(r.id)
+	o.note = diffRows(lineDiff(strings.Split(saved, "\n"), strings.Split(r.note, "\n")), o.Screen.totaleditorcols)
+	o.previewFolds = nil
+	o.Screen.eraseRightScreen()
+	o.drawRenderedNote()
+	o.ShowMessage(BL, "%s: saved -> recovered | j/k scroll, d list, r restore, x discard, q quit", r.title)
+}
+
+// case RECOVERY:
+func (o *Organizer) RecoveryModeKeyHandler(c int) RedrawScope {
+	switch c {
+	case ARROW_DOWN, 'j':
+		if o.recoveryDiff {
+			if o.altRowoff < len(o.note)-1 {
+				o.altRowoff++
+			}
+			o.Screen.eraseRightScreen()
+			o.drawRenderedNote()
+			return RedrawNone
+		}
+		if o.recoveryIndex < len(o.recovered)-1 {
+			o.recoveryIndex++
+		}
+	case ARROW_UP, 'k':
+		if o.recoveryDiff {
+			if o.altRowoff > 0 {
+				o.altRowoff--
+			}
+			o.Screen.eraseRightScreen()
+			o.drawRenderedNote()
+			return RedrawNone
+		}
+		if o.recoveryIndex > 0 {
+			o.recoveryIndex--
+		}
+	case 'd':
+		o.recoveryDiff = !o.recoveryDiff
+		o.altRowoff = 0
+		if o.recoveryDiff {
+			o.drawRecoveryDiff()
+			return RedrawNone
+		}
+		o.Screen.eraseRightScreen()
+	case 'r':
+		o.restoreRecovered()
+		return RedrawNone
+	case 'x':
+		r := o.recovered[o.recoveryIndex]
+		if err := o.Database.deleteRecovery(r.id); err != nil {
+			o.ShowMessage(BL, "Error discarding the recovered changes: %v", err)
+			return RedrawNone
+		}
+		o.recovered = append(o.recovered[:o.recoveryIndex], o.recovered[o.recoveryIndex+1:]...)
+		if len(o.recovered) == 0 {
+			o.quitRecovery()
+			o.ShowMessage(BL, "Discarded the recovered changes to %s", r.title)
+			return RedrawNone
+		}
+		if o.recoveryIndex >= len(o.recovered) {
+			o.recoveryIndex = len(o.recovered) - 1
+		}
+		o.recoveryDiff = false
+		o.altRowoff = 0
+		o.Screen.eraseRightScreen()
+	case 'q':
+		o.quitRecovery()
+		o.ShowMessage(BL, "")
+		return RedrawNone
+	default:
+		return RedrawNone
+	}
+	o.drawRecovery()
+	return RedrawNone
+}
+
+func (o *Organizer) quitRecovery() {
+	o.mode = NORMAL
+	o.recovered = nil
+	o.altRowoff = 0
+	o.displayNote()
+}
+
+// restoreRecovered opens the selected note in an editor with the recovered
+// text; it isn't written until the note is
+func (o *Organizer) restoreRecovered() {
+	if o.view != TASK {
+		o.ShowMessage(BL, "Switch to the notes view to restore a note")
+		return
+	}
+	r := o.recovered[o.recoveryIndex]
+	o.recovered = nil
+	o.mode = NORMAL
+	o.altRowoff = 0
+	o.editNote(r.id)
+	ae := o.Session.activeEditor
+	if ae == nil || ae.id != r.id {
+		return
+	}
+	ae.title = r.title
+	ae.vbuf.SetLines(0, -1, strings.Split(r.note, "\n"))
+	ae.ss = ae.vbuf.Lines()
+	ae.autosaved = r.note
+	o.Screen.eraseRightScreen()
+	o.Screen.drawRightScreen()
+	ae.ShowMessage(BR, "Restored the recovered changes; :w to save them")
+}
+
+type diffOp struct {
+	kind byte // ' ' unchanged, '-' removed or '+' added
+	line string
+}
+
+// maxDiffCells bounds the longest common subsequence table; when the
+// changed lines of both versions need more, they are shown as all removed
+// and then all added
+const maxDiffCells = 1 << 22
+
+// lineDiff returns the changes that turn the lines of a into those of b,
+// from their longest common subsequence
+func lineDiff(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+	var lcs [][]int
+	if (len(ma)+1)*(len(mb)+1) <= maxDiffCells {
+		lcs = make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+	}
+
+	var ops []diffOp
+	for _, line := range a[:pre] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case lcs != nil && i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, diffOp{' ', ma[i]})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs == nil || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', ma[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', mb[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// diffRows draws a diff with three unchanged lines around each change
+func diffRows(ops []diffOp, width int) []string {
+	const context = 3
+	keep := make([]bool, len(ops))
+	changed := false
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		changed = true
+		for k := max(0, i-context); k <= i+context && k < len(ops); k++ {
+			keep[k] = true
+		}
+	}
+	if !changed {
+		return []string{"No differences"}
+	}
+	var rows []string
+	for i, op := range ops {
+		if !keep[i] {
+			if i == 0 || keep[i-1] {
+				rows = append(rows, app.theme.UI.Fold+"···"+RESET)
+			}
+			continue
+		}
+		line := string(op.kind) + " " + strings.ReplaceAll(op.line, "\t", "    ")
+		if utf8.RuneCountInString(line) > width {
+			line = string([]rune(line)[:width])
+		}
+		switch op.kind {
+		case '-':
+			line = app.theme.UI.DiffRemoved + line + RESET
+		case '+':
+			line = app.theme.UI.DiffAdded + line + RESET
+		}
+		rows = append(rows, line)
+	}
+	return rows
+}
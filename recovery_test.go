@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// opsString writes a diff compactly, e.g. " a|-b|+c"
+func opsString(ops []diffOp) string {
+	var parts []string
+	for _, op := range ops {
+		parts = append(parts, string(op.kind)+op.line)
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, " a| b"},
+		{"both empty", nil, nil, ""},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, " a|+b| c"},
+		{"insert at end", []string{"a"}, []string{"a", "b"}, " a|+b"},
+		{"delete", []string{"a", "b", "c"}, []string{"a", "c"}, " a|-b| c"},
+		{"delete at start", []string{"a", "b"}, []string{"b"}, "-a| b"},
+		{"change", []string{"a", "b", "c"}, []string{"a", "x", "c"}, " a|-b|+x| c"},
+		{"change and move", []string{"a", "b", "c", "d"}, []string{"b", "a", "d", "e"}, "-a| b|-c|+a| d|+e"},
+		{"empty old", nil, []string{"a", "b"}, "+a|+b"},
+		{"empty new", []string{"a", "b"}, nil, "-a|-b"},
+	}
+	for _, tt := range tests {
+		if got := opsString(lineDiff(tt.a, tt.b)); got != tt.want {
+			t.Errorf("%s: lineDiff(%q, %q) = %q, want %q", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLineDiffLarge(t *testing.T) {
+	// the changed middle is too big for the table, so it is shown as
+	// removed and then added; the common ends are still unchanged
+	n := 3000
+	a := []string{"head"}
+	b := []string{"head"}
+	for i := 0; i < n; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append(a, "tail")
+	b = append(b, "tail")
+
+	ops := lineDiff(a, b)
+	var old, new []string
+	for _, op := range ops {
+		if op.kind != '+' {
+			old = append(old, op.line)
+		}
+		if op.kind != '-' {
+			new = append(new, op.line)
+		}
+	}
+	if !reflect.DeepEqual(old, a) || !reflect.DeepEqual(new, b) {
+		t.Fatalf("lineDiff of %d lines doesn't turn the old lines into the new ones", n)
+	}
+	if ops[0] != (diffOp{' ', "head"}) || ops[1].kind != '-' || ops[n+1].kind != '+' || ops[len(ops)-1] != (diffOp{' ', "tail"}) {
+		t.Errorf("lineDiff of %d lines = %v ... %v, want head, removed, added, tail", n, ops[:2], ops[len(ops)-2:])
+	}
+}
+
+func TestDiffRowsUsesTheme(t *testing.T) {
+	saved := app
+	t.Cleanup(func() { app = saved })
+	app = &App{theme: monoTheme()}
+
+	var a, b []string
+	for i := 0; i < 10; i++ {
+		a = append(a, fmt.Sprint(i))
+	}
+	b = append(append([]string{}, a...), "new")
+	b[0] = "zero"
+	ui := app.theme.UI
+	want := []string{
+		ui.DiffRemoved + "- 0" + RESET,
+		ui.DiffAdded + "+ zero" + RESET,
+		"  1", "  2", "  3",
+		ui.Fold + "···" + RESET,
+		"  7", "  8", "  9",
+		ui.DiffAdded + "+ new" + RESET,
+	}
+	if got := diffRows(lineDiff(a, b), 80); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffRows = %q, want %q", got, want)
+	}
+	for _, row := range want {
+		if strings.Contains(row, "\x1b[3") {
+			t.Errorf("diffRows with the mono theme colored %q", row)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// renderCache keeps rendered note previews so a note whose content, width,
+// theme, glamour style and image settings haven't changed is shown without running
+// glamour again. Previews with images are only reused while the image
+// protocol still has their images; previews without images can also be
+// kept on disk between runs (render_cache.persist in config.json).
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	max     int
+	path    string // where previews are persisted; "" if they aren't
+}
+
+// renderCacheEntry is a rendered preview
+type renderCacheEntry struct {
+	Key    string   `json:"key"`
+	Lines  []string `json:"lines"`
+	Images bool     `json:"-"` // drawn with an image protocol
+}
+
+// defaultRenderCacheEntries is how many previews are kept by default
+const defaultRenderCacheEntries = 200
+
+// renderCacheFile is where previews are persisted
+var renderCacheFile = filepath.Join(".", "render_cache", "renders.json")
+
+func newRenderCache(max int, persist bool) *renderCache {
+	if max <= 0 {
+		max = defaultRenderCacheEntries
+	}
+	c := &renderCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		max:     max,
+	}
+	if persist {
+		c.path = renderCacheFile
+		c.load()
+	}
+	return c
+}
+
+// renderSettings are what a preview looks like besides its markdown and
+// width. They are read on the main goroutine, since :theme and :imagemode
+// change them, and handed to the background renders.
+type renderSettings struct {
+	style     string // glamour style path
+	theme     string
+	highlight string // search match style
+	find      bool   // the find view marks the search terms
+	images    string // image protocol, scale and info; empty without images
+}
+
+// currentRenderSettings returns the render settings in use; call it on the
+// main goroutine
+func currentRenderSettings() renderSettings {
+	s := renderSettings{
+		style:     getGlamourStylePath(),
+		theme:     app.theme.Name,
+		highlight: app.theme.UI.SearchHighlight,
+		find:      app.Organizer != nil && app.Organizer.taskview == BY_FIND,
+	}
+	if app.imageProtocol != nil {
+		s.images = fmt.Sprintf("%s|%d|%t", app.imageProtocol, app.imageScale, app.showImageInfo)
+	}
+	return s
+}
+
+// renderKey identifies the preview of markdown maxCols wide, drawn with
+// settings s with or without images
+func renderKey(markdown string, maxCols int, s renderSettings, withImages bool) string {
+	var styleTime int64
+	if info, err := os.Stat(s.style); err == nil {
+		styleTime = info.ModTime().UnixNano()
+	}
+	key := fmt.Sprintf("%s|%d|%s|%d|%s|%t", hashString(markdown), maxCols, s.style, styleTime,
+		s.theme, s.find)
+	if withImages && s.images != "" {
+		key += "|" + s.images
+	}
+	return hashString(key)
+}
+
+// get returns the preview stored under key
+func (c *renderCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*renderCacheEntry).Lines, true
+}
+
+// put stores a preview, evicting the least recently used past max
+func (c *renderCache) put(key string, lines []string, images bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = &renderCacheEntry{Key: key, Lines: lines, Images: images}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&renderCacheEntry{Key: key, Lines: lines, Images: images})
+	for c.order.Len() > c.max {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*renderCacheEntry).Key)
+	}
+}
+
+// load reads the persisted previews
+func (c *renderCache) load() {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	var saved []renderCacheEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return
+	}
+	// saved is most recently used first
+	for i := len(saved) - 1; i >= 0; i-- {
+		c.put(saved[i].Key, saved[i].Lines, false)
+	}
+}
+
+// save persists the previews without images
+func (c *renderCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	var saved []renderCacheEntry
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		if e := el.Value.(*renderCacheEntry); !e.Images {
+			saved = append(saved, *e)
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
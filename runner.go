@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunnerConfig says how :compile, :run and :test handle code notes in one
+// language. Commands go through the shell in Dir; {file} is replaced by
+// the source file name and arguments given to :run or :test are appended.
+type RunnerConfig struct {
+	File     string `json:"file"`     // name the note is written to, e.g. main.go
+	Template string `json:"template"` // optional file in which {{note}} is replaced by the note
+	Dir      string `json:"dir"`      // working directory; a temp dir if empty
+	Setup    string `json:"setup"`    // run before the first command in a directory
+	Project  string `json:"project"`  // file Setup creates; Setup only runs if it is missing
+	Build    string `json:"build"`
+	Run      string `json:"run"`
+	Test     string `json:"test"`
+	Timeout  int    `json:"timeout"` // seconds
+}
+
+const (
+	defaultRunTimeout = 60 // seconds
+	maxRunLines       = 5000
+	runNotification   = "_RUN_OUTPUT_"
+)
+
+// defaultRunners are used for the fields config.json leaves empty
+var defaultRunners = map[string]RunnerConfig{
+	"go": {
+		File:    "main.go",
+		Setup:   "go mod init notes",
+		Project: "go.mod",
+		Build:   "go mod tidy && go build -o main .",
+		Run:     "./main",
+		Test:    "go test ./...",
+	},
+	"cpp": {
+		File:  "main.cpp",
+		Build: "g++ -std=c++20 -o main {file}",
+		Run:   "./main",
+	},
+	"python": {
+		File:  "main.py",
+		Build: "python3 -m py_compile {file}",
+		Run:   "python3 {file}",
+		Test:  "python3 -m pytest -q",
+	},
+	"sh": {
+		File: "main.sh",
+		Run:  "sh {file}",
+	},
+}
+
+// runDirs are the working directories already set up this session and
+// runTempDirs the ones among them made because the runner has no Dir; the
+// mutex is there because code files are also written in the background
+var (
+	runDirs     = map[string]string{}
+	runTempDirs []string
+	runDirsMu   sync.Mutex
+)
+
+// runnerFor merges the configured runner for lang over the default one
+func runnerFor(lang string) (RunnerConfig, bool) {
+	rc, ok := defaultRunners[lang]
+	var user RunnerConfig
+	if app.Config != nil {
+		var found bool
+		user, found = app.Config.Runners[lang]
+		ok = ok || found
+	}
+	if !ok {
+		return rc, false
+	}
+	for _, f := range []struct{ dst, src *string }{
+		{&rc.File, &user.File},
+		{&rc.Template, &user.Template},
+		{&rc.Dir, &user.Dir},
+		{&rc.Setup, &user.Setup},
+		{&rc.Project, &user.Project},
+		{&rc.Build, &user.Build},
+		{&rc.Run, &user.Run},
+		{&rc.Test, &user.Test},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if user.Timeout > 0 {
+		rc.Timeout = user.Timeout
+	}
+	if rc.Timeout <= 0 {
+		rc.Timeout = defaultRunTimeout
+	}
+	if rc.File == "" {
+		rc.File = "main." + lang
+	}
+	return rc, true
+}
+
+// blockRunDir is the runner directory's subdirectory fenced blocks are run
+// in, so running one doesn't overwrite a code note's file
+const blockRunDir = "blocks"
+
+// workDir returns the runner's directory, or its subdirectory sub, and
+// whether it is new this session
+func (rc RunnerConfig) workDir(lang, sub string) (string, bool, error) {
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+	key := lang
+	if sub != "" {
+		key += "-" + sub
+	}
+	if dir, ok := runDirs[key]; ok {
+		return dir, false, nil
+	}
+	dir := rc.Dir
+	var err error
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "vimango-"+key+"-")
+		if err == nil {
+			runTempDirs = append(runTempDirs, dir)
+		}
+	} else {
+		dir = filepath.Join(dir, sub)
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	runDirs[key] = dir
+	return dir, true, nil
+}
+
+// codeFile returns the file a code note in lang is written to: the
+// runner's file in the runner's directory, which is created if need be
+func codeFile(lang string) (string, error) {
+	rc, ok := runnerFor(lang)
+	if !ok {
+		return "", fmt.Errorf("No runner for %q", lang)
+	}
+	dir, _, err := rc.workDir(lang, "")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, rc.File), nil
+}
+
+// removeRunDirs removes the temp directories runners used this session,
+// leaving the configured ones
+func removeRunDirs() {
+	runDirsMu.Lock()
+	defer runDirsMu.Unlock()
+	for _, dir := range runTempDirs {
+		os.RemoveAll(dir)
+	}
+	runTempDirs = nil
+	runDirs = map[string]string{}
+}
+
+// needsSetup reports whether Setup has to run in dir: when the project file
+// is missing or, if the runner names none, the first time dir is used
+func (rc RunnerConfig) needsSetup(dir string, isNew bool) bool {
+	if rc.Setup == "" {
+		return false
+	}
+	if rc.Project == "" {
+		return isNew
+	}
+	_, err := os.Stat(filepath.Join(dir, rc.Project))
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// source returns the note as it is written to the source file
+func (rc RunnerConfig) source(note string) (string, error) {
+	if rc.Template == "" {
+		return note, nil
+	}
+	b, err := os.ReadFile(rc.Template)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(b), "{{note}}", note), nil
+}
+
+func (e *Editor) compile() { e.runCode("build") }
+func (e *Editor) run()     { e.runCode("run") }
+func (e *Editor) test()    { e.runCode("test") }
+
+// runCode writes the note to its runner's directory and starts the build,
+// run or test command, streaming its output into the output notice
+func (e *Editor) runCode(action string) {
+	defer func() {
+		e.mode = NORMAL
+		e.command_line = ""
+	}()
+
+	if r := e.Session.run; r != nil && !r.finished() {
+		e.ShowMessage(BR, "%q is still running; Ctrl-C stops it", r.title)
+		return
+	}
+	context := e.Database.taskContext(e.id)
+	lang := Languages[context]
+	if _, ok := runnerFor(lang); !ok {
+		e.ShowMessage(BR, "I don't recognize %q", context)
+		return
+	}
+	var args string
+	if pos := strings.Index(e.command_line, " "); pos != -1 && action != "build" {
+		args = strings.TrimSpace(e.command_line[pos+1:])
+	}
+	r, err := startRunner(lang, "", action, e.bufferToString(), args)
+	if err != nil {
+		e.ShowMessage(BR, "%v", err)
+		return
+	}
+	e.Session.run = r
+	r.draw()
+	e.ShowMessage(BR, "Running %s in %s (Ctrl-C stops it)", action, r.cmd.Dir)
+}
+
+// startRunner writes source to the directory of lang's runner, or its
+// subdirectory sub, and starts its build, run or test command with args
+// appended
+func startRunner(lang, sub, action, source, args string) (*codeRun, error) {
+	rc, ok := runnerFor(lang)
+	if !ok {
+		return nil, fmt.Errorf("No runner for %q", lang)
+	}
+	var cmdline string
+	switch action {
+	case "build":
+		cmdline = rc.Build
+	case "run":
+		if rc.Run != "" {
+			cmdline = joinCommands(rc.Build, rc.Run)
+		}
+	case "test":
+		cmdline = rc.Test
+	}
+	if cmdline == "" {
+		return nil, fmt.Errorf("No %s command configured for %s", action, lang)
+	}
+
+	dir, isNew, err := rc.workDir(lang, sub)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating directory for %s: %v", lang, err)
+	}
+	if rc.needsSetup(dir, isNew) {
+		cmdline = joinCommands(rc.Setup, cmdline)
+	}
+	text, err := rc.source(source)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading template %s: %v", rc.Template, err)
+	}
+	filePath := filepath.Join(dir, rc.File)
+	if err := os.WriteFile(filePath, []byte(text), 0o644); err != nil {
+		return nil, fmt.Errorf("Error writing file %s: %v", filePath, err)
+	}
+
+	cmdline = strings.ReplaceAll(cmdline, "{file}", rc.File)
+	if args != "" {
+		cmdline += " " + args
+	}
+	r, err := startRun(lang+" "+action, cmdline, dir, time.Duration(rc.Timeout)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting %q: %v", cmdline, err)
+	}
+	return r, nil
+}
+
+// joinCommands chains shell commands so each runs only if the one before
+// it succeeded
+func joinCommands(cmds ...string) string {
+	var parts []string
+	for _, c := range cmds {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+// codeRun is a running (or finished) build, run or test command
+type codeRun struct {
+	title   string
+	cmdline string
+	cmd     *exec.Cmd
+	pending atomic.Bool // a redraw notification is queued
+
+	// then, if set, is called on the main loop once the command has finished
+	then func(*codeRun)
+
+	mu       sync.Mutex
+	lines    []string
+	status   string // e.g. [exit status 1] once done
+	ok       bool   // exited with status 0
+	done     bool
+	handled  bool // then has been called
+	killed   bool
+	timedOut bool
+}
+
+func startRun(title, cmdline, dir string, timeout time.Duration) (*codeRun, error) {
+	cmd := shellCommand(cmdline)
+	cmd.Dir = dir
+	// stdout and stderr share one pipe so their lines stay in order
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	r := &codeRun{
+		title:   title,
+		cmdline: cmdline,
+		cmd:     cmd,
+	}
+
+	readDone := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			r.add(scanner.Text())
+		}
+		// keep draining so the process can't block on a line too long to scan
+		io.Copy(io.Discard, pr)
+		close(readDone)
+	}()
+
+	go func() {
+		timer := time.AfterFunc(timeout, func() {
+			r.mu.Lock()
+			r.timedOut = true
+			r.mu.Unlock()
+			killProcess(cmd)
+		})
+		err := cmd.Wait()
+		timer.Stop()
+		pw.Close()
+		<-readDone
+
+		r.mu.Lock()
+		var exitErr *exec.ExitError
+		switch {
+		case r.timedOut:
+			r.status = fmt.Sprintf("[timed out after %s]", timeout)
+		case r.killed:
+			r.status = "[stopped]"
+		case err == nil:
+			r.status = "[done]"
+			r.ok = true
+		case errors.As(err, &exitErr):
+			r.status = fmt.Sprintf("[exit status %d]", exitErr.ExitCode())
+		default:
+			r.status = fmt.Sprintf("[%v]", err)
+		}
+		r.done = true
+		r.mu.Unlock()
+		r.notify()
+	}()
+	return r, nil
+}
+
+func (r *codeRun) add(line string) {
+	r.mu.Lock()
+	r.lines = append(r.lines, line)
+	if len(r.lines) > maxRunLines {
+		r.lines = r.lines[len(r.lines)-maxRunLines:]
+	}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// notify asks the main loop to redraw the output, at most once per redraw
+func (r *codeRun) notify() {
+	if r.pending.CompareAndSwap(false, true) {
+		app.addNotification(runNotification)
+	}
+}
+
+func (r *codeRun) finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+// output returns the command's output so far
+func (r *codeRun) output() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// stop kills the command (Ctrl-C) and reports whether it was running
+func (r *codeRun) stop() bool {
+	r.mu.Lock()
+	if r.done {
+		r.mu.Unlock()
+		return false
+	}
+	r.killed = true
+	r.mu.Unlock()
+	killProcess(r.cmd)
+	return true
+}
+
+// complete calls then the first time it is called after the command finished
+func (r *codeRun) complete() {
+	r.mu.Lock()
+	call := r.done && !r.handled && r.then != nil
+	if call {
+		r.handled = true
+	}
+	r.mu.Unlock()
+	if call {
+		r.then(r)
+	}
+}
+
+// draw shows the output in the notice on the left, scrolled to the end
+func (r *codeRun) draw() {
+	r.mu.Lock()
+	text := strings.Join(append([]string{"## " + r.title, "$ " + r.cmdline}, r.lines...), "\n")
+	if r.status != "" {
+		text += "\n" + r.status
+	}
+	r.mu.Unlock()
+
+	s := app.Screen
+	s.notice = strings.Split(WordWrap(text, s.divider-2, 0), "\n")
+	s.altRowoff = 0
+	if visible := s.textLines - 12; visible > 0 && len(s.notice) > s.textLines-10 {
+		s.altRowoff = len(s.notice) - visible
+	}
+	s.drawNoticeLayerLeft()
+	s.drawNoticeTextLeft()
+}
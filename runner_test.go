@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunnerWorkDirs(t *testing.T) {
+	t.Cleanup(removeRunDirs)
+	configured := t.TempDir()
+	temp := RunnerConfig{File: "main.go"}
+	fixed := RunnerConfig{File: "main.py", Dir: configured}
+
+	noteDir, isNew, err := temp.workDir("go", "")
+	if err != nil || !isNew {
+		t.Fatalf("workDir(go) = %q, %v, %v, want a new dir", noteDir, isNew, err)
+	}
+	if again, isNew, _ := temp.workDir("go", ""); again != noteDir || isNew {
+		t.Errorf("workDir(go) again = %q, %v, want %q, false", again, isNew, noteDir)
+	}
+	blockDir, isNew, err := temp.workDir("go", blockRunDir)
+	if err != nil || !isNew || blockDir == noteDir {
+		t.Errorf("workDir(go, blocks) = %q, %v, %v, want a new dir apart from %q", blockDir, isNew, err, noteDir)
+	}
+
+	pyDir, _, err := fixed.workDir("python", "")
+	if err != nil || pyDir != configured {
+		t.Errorf("workDir(python) = %q, %v, want %q", pyDir, err, configured)
+	}
+	pyBlocks, _, err := fixed.workDir("python", blockRunDir)
+	if want := filepath.Join(configured, blockRunDir); err != nil || pyBlocks != want {
+		t.Errorf("workDir(python, blocks) = %q, %v, want %q", pyBlocks, err, want)
+	}
+
+	removeRunDirs()
+	for _, dir := range []string{noteDir, blockDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("temp dir %s is still there after removeRunDirs (%v)", dir, err)
+		}
+	}
+	for _, dir := range []string{pyDir, pyBlocks} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("configured dir %s was removed: %v", dir, err)
+		}
+	}
+	if _, isNew, _ := temp.workDir("go", ""); !isNew {
+		t.Errorf("workDir(go) after removeRunDirs isn't new")
+	}
+}
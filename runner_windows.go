@@ -0,0 +1,19 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+)
+
+// shellCommand runs cmdline with cmd.exe
+func shellCommand(cmdline string) *exec.Cmd {
+	return exec.Command("cmd", "/C", cmdline)
+}
+
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
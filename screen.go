@@ -33,23 +33,23 @@ func (s *Screen) eraseScreenRedrawLines() {
 
 		// x = 0x78 vertical line; q = 0x71 horizontal line
 		// 37 = white; 1m = bold (note only need one 'm')
-		fmt.Fprint(os.Stdout, "\x1b[37;1mx")
+		fmt.Fprint(os.Stdout, app.theme.UI.Border+"x")
 	}
 
 	fmt.Fprint(os.Stdout, "\x1b[1;1H")
 	for k := 1; k < s.screenCols; k++ {
 		// cursor advances - same as char write
-		fmt.Fprint(os.Stdout, "\x1b[37;1mq")
+		fmt.Fprint(os.Stdout, app.theme.UI.Border+"q")
 	}
 
 	if s.divider > 10 {
 		fmt.Fprintf(os.Stdout, "\x1b[%d;%dH", TOP_MARGIN, s.divider-TIME_COL_WIDTH+1)
-		fmt.Fprint(os.Stdout, "\x1b[37;1mw") //'T' corner
+		fmt.Fprint(os.Stdout, app.theme.UI.Border+"w") //'T' corner
 	}
 
 	// draw next column's 'T' corner - divider
 	fmt.Fprintf(os.Stdout, "\x1b[%d;%dH", TOP_MARGIN, s.divider)
-	fmt.Fprint(os.Stdout, "\x1b[37;1mw") //'T' corner
+	fmt.Fprint(os.Stdout, app.theme.UI.Border+"w") //'T' corner
 
 	fmt.Fprint(os.Stdout, "\x1b[0m") // return background to normal (? necessary)
 	fmt.Fprint(os.Stdout, "\x1b(B")  //exit line drawing mode
@@ -85,7 +85,7 @@ func (s *Screen) eraseRightScreen() {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN, s.divider+j)
 		// below x = 0x78 vertical line (q = 0x71 is horizontal) 37 = white;
 		// 1m = bold (note only need one 'm'
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 	}
 
 	//exit line drawing mode
@@ -221,11 +221,11 @@ func (s *Screen) drawNoticeLayer() {
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+6, s.divider+7)
 
 	// \x1b[ 2*x is DECSACE to operate in rectable mode
-	// \x1b[%d;%d;%d;%d;48;5;235$r is DECCARA to apply specified attributes (background color 235) to rectangle area
+	// \x1b[%d;%d;%d;%d;%s$r is DECCARA to apply specified attributes (the notice box's style) to rectangle area
 	// \x1b[ *x is DECSACE to exit rectangle mode
-	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;48;5;235$r\x1b[*x",
-		TOP_MARGIN+6, s.divider+7, TOP_MARGIN+4+length, s.divider+7+width)
-	ab.WriteString("\x1b[48;5;235m") //draws the box lines with same background as above rectangle
+	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;%s$r\x1b[*x",
+		TOP_MARGIN+6, s.divider+7, TOP_MARGIN+4+length, s.divider+7+width, sgrParams(app.theme.UI.NoticeBox))
+	ab.WriteString(app.theme.UI.NoticeBox) //draws the box lines with same background as above rectangle
 	fmt.Print(ab.String())
 	s.drawNoticeBox()
 }
@@ -257,11 +257,11 @@ func (s *Screen) drawNoticeLayerLeft() {
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+6, 3) /////
 
 	// \x1b[ 2*x is DECSACE to operate in rectable mode
-	// \x1b[%d;%d;%d;%d;48;5;235$r is DECCARA to apply specified attributes (background color 235) to rectangle area
+	// \x1b[%d;%d;%d;%d;%s$r is DECCARA to apply specified attributes (the notice box's style) to rectangle area
 	// \x1b[ *x is DECSACE to exit rectangle mode
-	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;48;5;235$r\x1b[*x",
-		TOP_MARGIN+6, 7, TOP_MARGIN+4+length, 7+width)
-	ab.WriteString("\x1b[48;5;235m") //draws the box lines with same background as above rectangle
+	fmt.Fprintf(&ab, "\x1b[2*x\x1b[%d;%d;%d;%d;%s$r\x1b[*x",
+		TOP_MARGIN+6, 7, TOP_MARGIN+4+length, 7+width, sgrParams(app.theme.UI.NoticeBox))
+	ab.WriteString(app.theme.UI.NoticeBox) //draws the box lines with same background as above rectangle
 	fmt.Print(ab.String())
 	s.drawNoticeBoxLeft()
 }
@@ -276,32 +276,32 @@ func (s *Screen) drawNoticeBox() {
 
 	ab.WriteString("\x1b(0") // Enter line drawing mode
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, s.divider+6)
-	ab.WriteString("\x1b[37;1ml") //upper left corner
+	ab.WriteString(app.theme.UI.Border + "l") //upper left corner
 
 	for i := 1; i < length; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5+i, s.divider+6)
 		// x=0x78 vertical line (q=0x71 is horizontal) 37=white; 1m=bold (only need 1 m)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 	}
 
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+4+length, s.divider+6)
 	ab.WriteString("\x1b[1B")
-	ab.WriteString("\x1b[37;1mm") //lower left corner
+	ab.WriteString(app.theme.UI.Border + "m") //lower left corner
 
 	move_cursor = fmt.Sprintf("\x1b[1D\x1b[%dB", length)
 
 	for i := 1; i < width+1; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, s.divider+6+i)
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 	}
 
-	ab.WriteString("\x1b[37;1mj") //lower right corner
+	ab.WriteString(app.theme.UI.Border + "j") //lower right corner
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, s.divider+7+width)
-	ab.WriteString("\x1b[37;1mk") //upper right corner
+	ab.WriteString(app.theme.UI.Border + "k") //upper right corner
 
 	//exit line drawing mode
 	ab.WriteString("\x1b(B")
@@ -322,32 +322,32 @@ func (s *Screen) drawNoticeBoxLeft() {
 
 	ab.WriteString("\x1b(0") // Enter line drawing mode
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, 2)
-	ab.WriteString("\x1b[37;1ml") //upper left corner
+	ab.WriteString(app.theme.UI.Border + "l") //upper left corner
 
 	for i := 1; i < length; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5+i, 2)
 		// x=0x78 vertical line (q=0x71 is horizontal) 37=white; 1m=bold (only need 1 m)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mx")
+		ab.WriteString(app.theme.UI.Border + "x")
 	}
 
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+4+length, 2)
 	ab.WriteString("\x1b[1B")
-	ab.WriteString("\x1b[37;1mm") //lower left corner
+	ab.WriteString(app.theme.UI.Border + "m") //lower left corner
 
 	move_cursor = fmt.Sprintf("\x1b[1D\x1b[%dB", length)
 
 	for i := 1; i < width+1; i++ {
 		fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, 2+i) //3
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 		ab.WriteString(move_cursor)
-		ab.WriteString("\x1b[37;1mq")
+		ab.WriteString(app.theme.UI.Border + "q")
 	}
 
-	ab.WriteString("\x1b[37;1mj")                          //lower right corner
+	ab.WriteString(app.theme.UI.Border + "j")              //lower right corner
 	fmt.Fprintf(&ab, "\x1b[%d;%dH", TOP_MARGIN+5, 3+width) //3
-	ab.WriteString("\x1b[37;1mk")                          //upper right corner
+	ab.WriteString(app.theme.UI.Border + "k")              //upper right corner
 
 	//exit line drawing mode
 	ab.WriteString("\x1b(B")
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/alecthomas/chroma/v2"
 	"google.golang.org/api/drive/v3"
 )
@@ -14,6 +16,9 @@ type Session struct {
 	style            [8]string
 	markdown_style   *chroma.Style
 	styleIndex       int
-	Editors          []*Editor      //slice of all active Editor
-	googleDrive      *drive.Service // Google Drive service for file operations
+	Editors          []*Editor             //slice of all active Editor
+	googleDrive      *drive.Service        // Google Drive service for file operations
+	lsps             map[string]*lspServer // language servers by language
+	lspMux           sync.Mutex
+	run              *codeRun // the last :compile, :run or :test
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"strings"
+)
+
+// sixelEncoder draws images with DEC sixel graphics, which foot, WezTerm,
+// mlterm, xterm -ti vt340 and others support
+type sixelEncoder struct {
+	colors int // color registers the terminal has; 0 if it didn't say
+}
+
+func (sixelEncoder) String() string { return "sixel" }
+
+// maxSixelColors is the most colors an image is drawn with, leaving the
+// last index of a byte for transparent
+const maxSixelColors = 255
+
+// sixelPalette is the palette images are dithered to for a terminal with
+// the given number of color registers; its last color is transparent
+func sixelPalette(colors int) color.Palette {
+	var p color.Palette
+	if colors == 0 || colors > maxSixelColors+1 {
+		// Plan9's colors without its pale yellow
+		p = append(p, palette.Plan9[:254]...)
+		p = append(p, palette.Plan9[255])
+	} else {
+		// a color cube as large as the registers allow
+		n := 2
+		for (n+1)*(n+1)*(n+1) < colors {
+			n++
+		}
+		for r := 0; r < n; r++ {
+			for g := 0; g < n; g++ {
+				for b := 0; b < n; b++ {
+					p = append(p, color.RGBA{
+						uint8(r * 255 / (n - 1)), uint8(g * 255 / (n - 1)), uint8(b * 255 / (n - 1)), 0xff,
+					})
+				}
+			}
+		}
+	}
+	return append(p, color.Transparent)
+}
+
+// prepare dithers img to the terminal's palette
+func (e sixelEncoder) prepare(img *image.NRGBA) image.Image {
+	pal := sixelPalette(e.colors)
+	out := image.NewPaletted(img.Bounds(), pal)
+	draw.FloydSteinberg.Draw(out, out.Bounds(), img, img.Bounds().Min)
+	return out
+}
+
+// encode returns the sixel sequence for img; cols and rows aren't needed
+// since img was scaled to the pixels of its cells
+func (sixelEncoder) encode(img image.Image, cols, rows int) string {
+	p, ok := img.(*image.Paletted)
+	if !ok {
+		return ""
+	}
+	bounds := p.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	transparent := len(p.Palette) - 1
+
+	var b strings.Builder
+	// P2=1 leaves pixels that aren't drawn as they are
+	b.WriteString("\x1bP0;1;0q")
+	fmt.Fprintf(&b, "\"1;1;%d;%d", w, h)
+	for i, c := range p.Palette[:transparent] {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	bits := make([]byte, w)
+	for y := 0; y < h; y += 6 {
+		// the colors used in this band of six rows
+		used := make([]bool, transparent)
+		for dy := 0; dy < 6 && y+dy < h; dy++ {
+			row := p.Pix[(y+dy)*p.Stride : (y+dy)*p.Stride+w]
+			for _, c := range row {
+				if int(c) < transparent {
+					used[c] = true
+				}
+			}
+		}
+		first := true
+		for c, u := range used {
+			if !u {
+				continue
+			}
+			for x := range bits {
+				bits[x] = 0
+			}
+			for dy := 0; dy < 6 && y+dy < h; dy++ {
+				row := p.Pix[(y+dy)*p.Stride : (y+dy)*p.Stride+w]
+				for x, pc := range row {
+					if int(pc) == c {
+						bits[x] |= 1 << dy
+					}
+				}
+			}
+			if !first {
+				b.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", c)
+			writeSixelRun(&b, bits)
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+
+	if IsTmuxScreen() {
+		opn, cls := TmuxOscOpenClose(b.String(), "")
+		return opn + cls
+	}
+	return b.String()
+}
+
+// writeSixelRun writes a row of sixels, run-length encoding repeats
+func writeSixelRun(b *strings.Builder, bits []byte) {
+	for x := 0; x < len(bits); {
+		n := 1
+		for x+n < len(bits) && bits[x+n] == bits[x] {
+			n++
+		}
+		ch := byte('?' + bits[x])
+		switch {
+		case n > 3:
+			fmt.Fprintf(b, "!%d%c", n, ch)
+		default:
+			for i := 0; i < n; i++ {
+				b.WriteByte(ch)
+			}
+		}
+		x += n
+	}
+}
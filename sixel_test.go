@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// testImage is a w x h gradient whose first column is transparent
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(0xff)
+			if x == 0 {
+				a = 0
+			}
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) * 7), a})
+		}
+	}
+	return img
+}
+
+func TestSixelPalette(t *testing.T) {
+	tests := []struct {
+		colors, want int // want counts transparent
+	}{
+		{0, 256},
+		{8, 9},
+		{16, 9},
+		{64, 28},
+		{256, 217},
+		{1024, 256},
+	}
+	for _, tt := range tests {
+		p := sixelPalette(tt.colors)
+		if len(p) != tt.want {
+			t.Errorf("sixelPalette(%d) has %d colors, want %d", tt.colors, len(p), tt.want)
+		}
+		if p[len(p)-1] != color.Transparent {
+			t.Errorf("sixelPalette(%d) doesn't end with transparent", tt.colors)
+		}
+	}
+}
+
+func TestSixelEncode(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	const w, h = 20, 13
+	for _, colors := range []int{0, 16, 256, 1024} {
+		enc := sixelEncoder{colors: colors}
+		img := enc.prepare(testImage(w, h))
+		s := enc.encode(img, 2, 1)
+
+		if !strings.HasPrefix(s, "\x1bP0;1;0q\"1;1;20;13") || !strings.HasSuffix(s, "\x1b\\") {
+			t.Errorf("colors %d: encode = %.30q...%q, want a sixel sequence with the image size", colors, s, s[max(0, len(s)-10):])
+			continue
+		}
+		pal := sixelPalette(colors)
+		if n := strings.Count(s, ";2;"); n != len(pal)-1 {
+			t.Errorf("colors %d: %d color registers defined, want %d", colors, n, len(pal)-1)
+		}
+		if bands := strings.Count(s, "-"); bands != (h+5)/6 {
+			t.Errorf("colors %d: %d bands of sixels, want %d", colors, bands, (h+5)/6)
+		}
+	}
+}
+
+func TestWriteSixelRun(t *testing.T) {
+	tests := []struct {
+		bits []byte
+		want string
+	}{
+		{[]byte{0, 0, 0, 0, 0, 1, 1, 63}, "!5?@@~"},
+		{[]byte{2, 2, 2}, "AAA"},
+		{[]byte{2, 2, 2, 2}, "!4A"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		writeSixelRun(&b, tt.bits)
+		if got := b.String(); got != tt.want {
+			t.Errorf("writeSixelRun(%v) = %q, want %q", tt.bits, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+// Package spell checks spelling with hunspell dictionaries (a .aff and a
+// .dic file) without the hunspell library, so builds without cgo can check
+// spelling too. It reads the parts of the affix file most dictionaries use:
+// SET, FLAG, AF, TRY, REP, PFX and SFX (with cross products and suffixes
+// that continue other suffixes), FORBIDDENWORD, NEEDAFFIX, ONLYINCOMPOUND
+// and NOSUGGEST. Compound words aren't supported.
+package spell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dictionary is a loaded hunspell dictionary; it isn't changed after
+// loading so it can be used from several goroutines
+type Dictionary struct {
+	words    map[string][][]string // word -> the flags of each of its entries
+	prefixes map[string][]*affix   // by the text they add
+	suffixes map[string][]*affix   // by the text they add
+	try      string
+	rep      [][2]string
+
+	flagType string     // "", "long", "num" or "UTF-8"
+	aliases  [][]string // AF flag sets, referred to by number from 1
+
+	forbidden      string
+	needAffix      string
+	onlyInCompound string
+	noSuggest      string
+}
+
+type affix struct {
+	flag   string
+	cross  bool // can combine with an affix of the other kind
+	strip  string
+	add    string
+	cont   []string // continuation flags
+	cond   condition
+	prefix bool
+}
+
+// Open loads the dictionary in the given .aff and .dic files
+func Open(affPath, dicPath string) (*Dictionary, error) {
+	aff, err := os.ReadFile(affPath)
+	if err != nil {
+		return nil, err
+	}
+	dic, err := os.ReadFile(dicPath)
+	if err != nil {
+		return nil, err
+	}
+	return Load(aff, dic)
+}
+
+// Load loads a dictionary from the contents of its .aff and .dic files
+func Load(aff, dic []byte) (*Dictionary, error) {
+	d := &Dictionary{
+		words:    make(map[string][][]string),
+		prefixes: make(map[string][]*affix),
+		suffixes: make(map[string][]*affix),
+	}
+	enc := encoding(aff)
+	affText, err := decode(aff, enc)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.parseAff(affText); err != nil {
+		return nil, err
+	}
+	dicText, err := decode(dic, enc)
+	if err != nil {
+		return nil, err
+	}
+	d.parseDic(dicText)
+	return d, nil
+}
+
+// encoding returns the character set named by the affix file's SET line
+func encoding(aff []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(aff))
+	for s.Scan() {
+		if f := strings.Fields(s.Text()); len(f) > 1 && f[0] == "SET" {
+			return f[1]
+		}
+	}
+	return ""
+}
+
+// iso885915 holds the characters of ISO 8859-15 that differ from ISO 8859-1
+var iso885915 = map[byte]rune{
+	0xa4: '€', 0xa6: 'Š', 0xa8: 'š', 0xb4: 'Ž', 0xb8: 'ž', 0xbc: 'Œ', 0xbd: 'œ', 0xbe: 'Ÿ',
+}
+
+func decode(b []byte, enc string) (string, error) {
+	switch strings.ToUpper(enc) {
+	case "", "UTF-8", "UTF8":
+		return string(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))), nil
+	case "ISO8859-1", "ISO-8859-1", "ISO8859-15", "ISO-8859-15":
+		latin9 := strings.HasSuffix(enc, "15")
+		var sb strings.Builder
+		for _, c := range b {
+			if r, ok := iso885915[c]; ok && latin9 {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("unsupported dictionary encoding %s", enc)
+}
+
+func (d *Dictionary) parseAff(text string) error {
+	remaining := make(map[string]int) // affix entries still to come by flag
+	crosses := make(map[string]bool)
+	aliasCount := -1
+	for n, line := range strings.Split(text, "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		value := ""
+		if len(f) > 1 {
+			value = f[1]
+		}
+		switch f[0] {
+		case "FLAG":
+			d.flagType = value
+		case "AF":
+			// the first AF line gives the number of aliases
+			if aliasCount == -1 {
+				aliasCount, _ = strconv.Atoi(value)
+				continue
+			}
+			d.aliases = append(d.aliases, d.parseFlags(value))
+		case "TRY":
+			d.try = value
+		case "REP":
+			if len(f) > 2 {
+				d.rep = append(d.rep, [2]string{strings.ReplaceAll(f[1], "_", " "), strings.ReplaceAll(f[2], "_", " ")})
+			}
+		case "FORBIDDENWORD":
+			d.forbidden = value
+		case "NEEDAFFIX", "PSEUDOROOT":
+			d.needAffix = value
+		case "ONLYINCOMPOUND":
+			d.onlyInCompound = value
+		case "NOSUGGEST":
+			d.noSuggest = value
+		case "PFX", "SFX":
+			if len(f) < 4 {
+				return fmt.Errorf("line %d: malformed %s", n+1, f[0])
+			}
+			key := f[0] + " " + f[1]
+			if remaining[key] == 0 {
+				count, err := strconv.Atoi(f[3])
+				if err != nil {
+					return fmt.Errorf("line %d: malformed %s header", n+1, f[0])
+				}
+				remaining[key], crosses[key] = count, f[2] == "Y"
+				continue
+			}
+			remaining[key]--
+			a, err := d.parseAffix(f, crosses[key])
+			if err != nil {
+				return fmt.Errorf("line %d: %v", n+1, err)
+			}
+			if a.prefix {
+				d.prefixes[a.add] = append(d.prefixes[a.add], a)
+			} else {
+				d.suffixes[a.add] = append(d.suffixes[a.add], a)
+			}
+		}
+	}
+	return nil
+}
+
+// parseAffix parses an affix rule like "SFX D y ied/S [^aeiou]y"
+func (d *Dictionary) parseAffix(f []string, cross bool) (*affix, error) {
+	a := &affix{flag: f[1], cross: cross, prefix: f[0] == "PFX"}
+	if f[2] != "0" {
+		a.strip = f[2]
+	}
+	add, cont, _ := strings.Cut(f[3], "/")
+	if add != "0" {
+		a.add = add
+	}
+	if cont != "" {
+		a.cont = d.flagField(cont)
+	}
+	cond := "."
+	if len(f) > 4 {
+		cond = f[4]
+	}
+	var err error
+	if a.cond, err = parseCondition(cond); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (d *Dictionary) parseDic(text string) {
+	first := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		// the first line is the (approximate) number of words
+		if first {
+			first = false
+			if _, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+				continue
+			}
+		}
+		word, flags := splitEntry(line)
+		if word == "" {
+			continue
+		}
+		d.words[word] = append(d.words[word], d.flagField(flags))
+	}
+}
+
+// splitEntry splits a .dic line like "hello/MS po:noun" into the word and
+// its flags; a slash in the word is written \/
+func splitEntry(line string) (word, flags string) {
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '/':
+			sb.WriteByte('/')
+			i++
+		case c == '/':
+			flags = line[i+1:]
+			if j := strings.IndexAny(flags, " \t"); j != -1 {
+				flags = flags[:j]
+			}
+			return sb.String(), flags
+		case c == '\t', c == ' ' && morphField(line[i+1:]):
+			return sb.String(), ""
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), ""
+}
+
+// morphField reports whether s starts with a morphological field like po:noun
+func morphField(s string) bool {
+	return len(s) > 3 && s[2] == ':'
+}
+
+// flagField parses the flags of a word or affix, which are an alias number
+// if the affix file has AF lines
+func (d *Dictionary) flagField(s string) []string {
+	if len(d.aliases) > 0 {
+		if n, err := strconv.Atoi(s); err == nil {
+			if n >= 1 && n <= len(d.aliases) {
+				return d.aliases[n-1]
+			}
+			return nil
+		}
+	}
+	return d.parseFlags(s)
+}
+
+// parseFlags splits flags written in the affix file's FLAG format
+func (d *Dictionary) parseFlags(s string) []string {
+	var flags []string
+	switch d.flagType {
+	case "num":
+		for _, n := range strings.Split(s, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				flags = append(flags, n)
+			}
+		}
+	case "long":
+		r := []rune(s)
+		for i := 0; i+1 < len(r); i += 2 {
+			flags = append(flags, string(r[i:i+2]))
+		}
+	default:
+		for _, r := range s {
+			flags = append(flags, string(r))
+		}
+	}
+	return flags
+}
+
+func hasFlag(flags []string, flag string) bool {
+	if flag == "" {
+		return false
+	}
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// condition is an affix condition like [^aeiou]y, one element per character
+type condition []condElem
+
+type condElem struct {
+	any   bool
+	neg   bool
+	chars []rune
+}
+
+func parseCondition(s string) (condition, error) {
+	if s == "." {
+		return nil, nil
+	}
+	var c condition
+	r := []rune(s)
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case '.':
+			c = append(c, condElem{any: true})
+		case '[':
+			end := i + 1
+			for end < len(r) && r[end] != ']' {
+				end++
+			}
+			if end == len(r) {
+				return nil, fmt.Errorf("unterminated condition %s", s)
+			}
+			e := condElem{chars: r[i+1 : end]}
+			if len(e.chars) > 0 && e.chars[0] == '^' {
+				e.neg, e.chars = true, e.chars[1:]
+			}
+			c = append(c, e)
+			i = end
+		default:
+			c = append(c, condElem{chars: r[i : i+1]})
+		}
+	}
+	return c, nil
+}
+
+func (e condElem) match(r rune) bool {
+	if e.any {
+		return true
+	}
+	for _, c := range e.chars {
+		if c == r {
+			return !e.neg
+		}
+	}
+	return e.neg
+}
+
+// matchStart reports whether a prefix's condition matches the start of word
+func (c condition) matchStart(word string) bool {
+	for _, e := range c {
+		r, size := utf8.DecodeRuneInString(word)
+		if size == 0 || !e.match(r) {
+			return false
+		}
+		word = word[size:]
+	}
+	return true
+}
+
+// matchEnd reports whether a suffix's condition matches the end of word
+func (c condition) matchEnd(word string) bool {
+	for i := len(c) - 1; i >= 0; i-- {
+		r, size := utf8.DecodeLastRuneInString(word)
+		if size == 0 || !c[i].match(r) {
+			return false
+		}
+		word = word[:len(word)-size]
+	}
+	return true
+}
+
+// Spell reports whether word is spelled correctly. A capitalized word is
+// also looked up in lower case, a word in capitals in lower case and
+// capitalized, and a hyphenated word is correct if its parts are.
+func (d *Dictionary) Spell(word string) bool {
+	if word == "" || isNumber(word) {
+		return true
+	}
+	for i, w := range caseVariants(word) {
+		ok, forbidden := d.check(w)
+		if ok {
+			return true
+		}
+		if forbidden && i == 0 {
+			return false
+		}
+	}
+	if strings.Contains(word, "’") {
+		return d.Spell(strings.ReplaceAll(word, "’", "'"))
+	}
+	if strings.Contains(word, "-") {
+		for _, part := range strings.Split(word, "-") {
+			if part != "" && !d.Spell(part) {
+				return false
+			}
+		}
+		return strings.Trim(word, "-") != ""
+	}
+	return false
+}
+
+func isNumber(word string) bool {
+	digits := false
+	for _, r := range word {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case r == '.' || r == ',' || r == '-':
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+// caseVariants returns the forms of word to look up, word itself first
+func caseVariants(word string) []string {
+	upper, lower := 0, 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	switch {
+	case upper > 1 && lower == 0:
+		return []string{word, strings.ToLower(word), capitalize(strings.ToLower(word))}
+	case upper == 1 && unicode.IsUpper(first):
+		return []string{word, strings.ToLower(word)}
+	}
+	return []string{word}
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// check looks word up as a root and with its affixes removed; forbidden is
+// true if the word is marked forbidden
+func (d *Dictionary) check(word string) (ok, forbidden bool) {
+	for _, flags := range d.words[word] {
+		if hasFlag(flags, d.forbidden) {
+			return false, true
+		}
+	}
+	for _, flags := range d.words[word] {
+		if !hasFlag(flags, d.needAffix) && !hasFlag(flags, d.onlyInCompound) {
+			return true, false
+		}
+	}
+	if d.stripSuffixes(word, func(stem string, s *affix) bool {
+		if d.root(stem, s.flag) && !hasFlag(s.cont, d.needAffix) {
+			return true
+		}
+		// a suffix added to another suffix that allows it
+		return d.stripSuffixes(stem, func(root string, inner *affix) bool {
+			return hasFlag(inner.cont, s.flag) && d.root(root, inner.flag)
+		})
+	}) {
+		return true, false
+	}
+	return d.stripPrefixes(word, func(stem string, p *affix) bool {
+		if d.root(stem, p.flag) && !hasFlag(p.cont, d.needAffix) {
+			return true
+		}
+		return d.stripSuffixes(stem, func(root string, s *affix) bool {
+			return p.cross && s.cross && d.root(root, p.flag, s.flag) ||
+				hasFlag(s.cont, p.flag) && d.root(root, s.flag)
+		})
+	}), false
+}
+
+// root reports whether word is in the dictionary with all the flags
+func (d *Dictionary) root(word string, flags ...string) bool {
+	for _, entry := range d.words[word] {
+		if hasFlag(entry, d.forbidden) || hasFlag(entry, d.onlyInCompound) {
+			continue
+		}
+		all := true
+		for _, f := range flags {
+			all = all && hasFlag(entry, f)
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// stripSuffixes calls found with each stem left by removing a suffix whose
+// condition it meets, stopping when found returns true
+func (d *Dictionary) stripSuffixes(word string, found func(stem string, s *affix) bool) bool {
+	for i := len(word); i > 0; i-- {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		for _, s := range d.suffixes[word[i:]] {
+			stem := word[:i] + s.strip
+			if s.cond.matchEnd(stem) && found(stem, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripPrefixes is stripSuffixes for prefixes
+func (d *Dictionary) stripPrefixes(word string, found func(stem string, p *affix) bool) bool {
+	for i := 0; i < len(word); i++ {
+		if !utf8.RuneStart(word[i]) {
+			continue
+		}
+		for _, p := range d.prefixes[word[:i]] {
+			stem := p.strip + word[i:]
+			if p.cond.matchStart(stem) && found(stem, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package spell
+
+import (
+	"slices"
+	"testing"
+)
+
+func loadSmall(t *testing.T) *Dictionary {
+	t.Helper()
+	d, err := Open("testdata/small.aff", "testdata/small.dic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestSpell(t *testing.T) {
+	d := loadSmall(t)
+	tests := []struct {
+		word string
+		want bool
+	}{
+		{"hello", true},
+		{"hellos", true},
+		{"boxes", true},
+		{"boxs", false},
+		{"flies", true},
+		{"flied", true},
+		{"flying", true},
+		{"plays", true},
+		{"played", true},
+		{"baked", true},
+		{"baking", true},
+		{"bakeing", false},
+		{"walked", true},
+		{"rewalk", true},
+		{"rewalked", true}, // cross product of a prefix and a suffix
+		{"undo", true},
+		{"unlocked", true},
+		{"relocking", true},
+		{"friendly", true},
+		{"friendlies", true}, // a suffix added to a suffix
+		{"friendlyly", false},
+		{"kind", false}, // needs an affix
+		{"unkind", true},
+		{"irregardless", false},
+		{"Hello", true},
+		{"HELLO", true},
+		{"hELLO", false},
+		{"Paris", true},
+		{"PARIS", true},
+		{"paris", false},
+		{"NASA", true},
+		{"Nasa", false},
+		{"hello-world", true},
+		{"hello-wrold", false},
+		{"1,024", true},
+		{"wrold", false},
+	}
+	for _, tt := range tests {
+		if got := d.Spell(tt.word); got != tt.want {
+			t.Errorf("Spell(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	d := loadSmall(t)
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"wrold", "world"},  // swap
+		{"helo", "hello"},   // insert
+		{"helllo", "hello"}, // delete
+		{"hallo", "hello"},  // replace
+		{"Wrold", "World"},  // keeps the capital
+		{"paris", "Paris"},  // case
+		{"fone", "phone"},   // REP
+		{"recieve", "receive"},
+		{"alot", "a lot"}, // REP to two words
+		{"helloworld", "hello world"},
+		{"wrld", "world"},
+		{"wrlod", "world"}, // two edits away
+	}
+	for _, tt := range tests {
+		if got := d.Suggest(tt.word); !slices.Contains(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want it to include %q", tt.word, got, tt.want)
+		}
+	}
+	if got := d.Suggest("shti"); slices.Contains(got, "shit") {
+		t.Errorf("Suggest(%q) = %q, which includes a NOSUGGEST word", "shti", got)
+	}
+	if got := d.Suggest("irregardles"); slices.Contains(got, "irregardless") {
+		t.Errorf("Suggest(%q) = %q, which includes a forbidden word", "irregardles", got)
+	}
+}
+
+func TestFlagTypes(t *testing.T) {
+	tests := []struct {
+		name, aff, dic string
+	}{
+		{"long", "FLAG long\nSFX Aa Y 1\nSFX Aa 0 s .\n", "1\ncat/AaBb\n"},
+		{"num", "FLAG num\nSFX 101 Y 1\nSFX 101 0 s .\n", "1\ncat/7,101\n"},
+		{"alias", "AF 2\nAF AB\nAF S\nSFX S Y 1\nSFX S 0 s .\n", "1\ncat/2\n"},
+	}
+	for _, tt := range tests {
+		d, err := Load([]byte(tt.aff), []byte(tt.dic))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !d.Spell("cats") || d.Spell("catss") {
+			t.Errorf("%s: Spell(cats) = %v, Spell(catss) = %v", tt.name, d.Spell("cats"), d.Spell("catss"))
+		}
+	}
+}
+
+func TestLatin1(t *testing.T) {
+	aff := []byte("SET ISO8859-1\nSFX N Y 1\nSFX N 0 n e\n")
+	dic := []byte("1\nBl\xfcte/N\n")
+	d, err := Load(aff, dic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"Blüte", "Blüten"} {
+		if !d.Spell(w) {
+			t.Errorf("Spell(%q) = false", w)
+		}
+	}
+	if _, err := Load([]byte("SET KOI8-R\n"), nil); err == nil {
+		t.Error("Load with an unsupported encoding didn't fail")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"hello", "hello", 0},
+		{"hello", "hlelo", 1},
+		{"hello", "helo", 1},
+		{"hello", "jello", 1},
+		{"hello", "hlel", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package spell
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSuggestions is the most suggestions Suggest returns
+const maxSuggestions = 10
+
+// defaultTry is used for the letters to try when the affix file has no TRY
+const defaultTry = "esianrtolcdugmphbyfvkwzESIANRTOLCDUGMPHBYFVKWZ'"
+
+// Suggest returns corrections for a misspelled word: first the word in
+// another case and the results of the affix file's REP replacements, then
+// correct words one edit away (a letter swapped with its neighbor, replaced
+// by a TRY letter, added or removed, or the word split in two) and last
+// dictionary words two edits away.
+func (d *Dictionary) Suggest(word string) []string {
+	var suggestions []string
+	seen := map[string]bool{word: true}
+	add := func(s string) {
+		if seen[s] || len(suggestions) >= maxSuggestions {
+			return
+		}
+		seen[s] = true
+		if d.suggestible(s) {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	add(strings.ToLower(word))
+	add(capitalize(strings.ToLower(word)))
+	add(strings.ToUpper(word))
+	for _, s := range d.replacements(word) {
+		add(s)
+	}
+	for _, s := range d.edits(word) {
+		add(s)
+	}
+	if len(suggestions) < maxSuggestions {
+		for _, s := range d.nearWords(word) {
+			add(s)
+		}
+	}
+	return suggestions
+}
+
+// suggestible reports whether s, which may be several words, is spelled
+// correctly and none of its words are marked NOSUGGEST
+func (d *Dictionary) suggestible(s string) bool {
+	for _, w := range strings.Split(s, " ") {
+		if w == "" || !d.Spell(w) {
+			return false
+		}
+		for _, flags := range d.words[w] {
+			if hasFlag(flags, d.noSuggest) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// replacements applies each REP rule to each place in word it matches; a
+// rule's ^ and $ tie it to the start and end of the word
+func (d *Dictionary) replacements(word string) []string {
+	var out []string
+	for _, rep := range d.rep {
+		from, to := rep[0], rep[1]
+		atStart, atEnd := strings.HasPrefix(from, "^"), strings.HasSuffix(from, "$")
+		from = strings.TrimSuffix(strings.TrimPrefix(from, "^"), "$")
+		if from == "" {
+			continue
+		}
+		for i := 0; i+len(from) <= len(word); i++ {
+			if word[i:i+len(from)] != from || atStart && i != 0 || atEnd && i+len(from) != len(word) {
+				continue
+			}
+			out = append(out, word[:i]+to+word[i+len(from):])
+		}
+	}
+	return out
+}
+
+// edits returns the strings one edit away from word, most likely first
+func (d *Dictionary) edits(word string) []string {
+	try := d.try
+	if try == "" {
+		try = defaultTry
+	}
+	r := []rune(word)
+	var out []string
+	for i := 0; i+1 < len(r); i++ {
+		out = append(out, string(r[:i])+string(r[i+1])+string(r[i])+string(r[i+2:]))
+	}
+	for i := range r {
+		for _, c := range try {
+			if c != r[i] {
+				out = append(out, string(r[:i])+string(c)+string(r[i+1:]))
+			}
+		}
+	}
+	for i := 0; i <= len(r); i++ {
+		for _, c := range try {
+			out = append(out, string(r[:i])+string(c)+string(r[i:]))
+		}
+	}
+	for i := range r {
+		out = append(out, string(r[:i])+string(r[i+1:]))
+	}
+	for i := 1; i < len(r); i++ {
+		out = append(out, string(r[:i])+" "+string(r[i:]))
+	}
+	return out
+}
+
+// nearWords returns the dictionary words within two edits of word, nearest
+// first, in word's case
+func (d *Dictionary) nearWords(word string) []string {
+	type near struct {
+		word     string
+		distance int
+	}
+	lower := []rune(strings.ToLower(word))
+	var found []near
+	for w, entries := range d.words {
+		if abs(utf8.RuneCountInString(w)-len(lower)) > 2 || !d.usableRoot(entries) {
+			continue
+		}
+		if dist := editDistance(lower, []rune(strings.ToLower(w))); dist <= 2 {
+			found = append(found, near{w, dist})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].word < found[j].word
+	})
+	first, _ := utf8.DecodeRuneInString(word)
+	out := make([]string, len(found))
+	for i, n := range found {
+		out[i] = n.word
+		if unicode.IsUpper(first) {
+			out[i] = capitalize(n.word)
+		}
+	}
+	return out
+}
+
+// usableRoot reports whether a word's entries let it stand on its own
+func (d *Dictionary) usableRoot(entries [][]string) bool {
+	for _, flags := range entries {
+		if hasFlag(flags, d.forbidden) || hasFlag(flags, d.noSuggest) {
+			return false
+		}
+	}
+	for _, flags := range entries {
+		if !hasFlag(flags, d.needAffix) && !hasFlag(flags, d.onlyInCompound) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the number of insertions, deletions, substitutions and
+// swaps of neighboring letters that turn a into b
+func editDistance(a, b []rune) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
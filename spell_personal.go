@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The personal word list is kept in a note so it is synced like any other.
+// Each line is a word added with zg or, ending in /!, a word marked wrong
+// with zw (the format of vim's spell files); lines starting with # are
+// comments. The list is read when it is first needed and again after the
+// note is written in an editor or a sync. Each device creates the note the
+// first time a word is added, so after a sync there can be several; their
+// words are merged and the next change is saved to the one the server got
+// first, deleting the others. Every device picks the same note to keep
+// since they go by the server's id, not the local one.
+
+const personalDictionaryTitle = "vimango personal dictionary"
+
+type personalDictionary struct {
+	loaded bool
+	id     int             // id of the note, -1 until it is created
+	copies []int           // ids of the other notes with the same title
+	words  map[string]bool // word -> true if good (zg), false if wrong (zw)
+}
+
+var personalWords = &personalDictionary{id: -1}
+
+func (p *personalDictionary) load() {
+	if p.loaded || app == nil || app.Database == nil {
+		return
+	}
+	p.loaded = true
+	p.words = make(map[string]bool)
+	p.id, p.copies = -1, nil
+	ids, notes, err := app.Database.notesByTitle(personalDictionaryTitle)
+	if err != nil || len(ids) == 0 {
+		return
+	}
+	p.id, p.copies = ids[0], ids[1:]
+	for _, note := range notes {
+		p.parse(note)
+	}
+}
+
+// isNote reports whether id is one of the word list's notes
+func (p *personalDictionary) isNote(id int) bool {
+	return id != -1 && (id == p.id || slices.Contains(p.copies, id))
+}
+
+// parse adds the words of a word list note; a word in more than one note
+// takes its entry in the last one
+func (p *personalDictionary) parse(note string) {
+	for _, line := range strings.Split(note, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if word, wrong := strings.CutSuffix(line, "/!"); wrong {
+			p.words[word] = false
+		} else {
+			p.words[line] = true
+		}
+	}
+}
+
+// lookup reports whether word is in the list and, if it is, whether it
+// is good; a capitalized word also matches the word in lower case
+func (p *personalDictionary) lookup(word string) (good, found bool) {
+	p.load()
+	if good, found = p.words[word]; found {
+		return good, true
+	}
+	if r, _ := utf8.DecodeRuneInString(word); unicode.IsUpper(r) {
+		good, found = p.words[strings.ToLower(word)]
+	}
+	return good, found
+}
+
+// set adds word as good or wrong, replacing any earlier entry
+func (p *personalDictionary) set(word string, good bool) error {
+	p.load()
+	p.words[word] = good
+	return p.save()
+}
+
+// remove takes word out of the list (zug, zuw), reporting whether it was
+// there
+func (p *personalDictionary) remove(word string) (bool, error) {
+	p.load()
+	if _, found := p.words[word]; !found {
+		return false, nil
+	}
+	delete(p.words, word)
+	return true, p.save()
+}
+
+func (p *personalDictionary) text() string {
+	words := make([]string, 0, len(p.words))
+	for w, good := range p.words {
+		if !good {
+			w += "/!"
+		}
+		words = append(words, w)
+	}
+	slices.SortFunc(words, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return "# Words added with zg; words marked wrong with zw end in /!\n" + strings.Join(words, "\n")
+}
+
+// save writes the list to its note, creating the note the first time
+func (p *personalDictionary) save() error {
+	if app == nil || app.Database == nil {
+		return fmt.Errorf("no database")
+	}
+	if p.id == -1 {
+		row := &Row{id: -1, title: personalDictionaryTitle, dirty: true}
+		if err := app.Database.insertTitle(row, DefaultContextUUID, DefaultFolderUUID); err != nil {
+			return err
+		}
+		p.id = row.id
+	}
+	if err := app.Database.updateNote(p.id, p.text()); err != nil {
+		return err
+	}
+	// the other copies' words are in the kept note now
+	for _, id := range p.copies {
+		if err := app.Database.toggleDeleted(id, false, "task"); err != nil {
+			return err
+		}
+	}
+	p.copies = nil
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPersonalDictionaryRoundTrip(t *testing.T) {
+	p := &personalDictionary{loaded: true, id: -1, words: map[string]bool{
+		"vimango": true,
+		"Gopher":  true,
+		"teh":     false,
+	}}
+	text := p.text()
+	want := "# Words added with zg; words marked wrong with zw end in /!\nGopher\nteh/!\nvimango"
+	if text != want {
+		t.Errorf("text() = %q, want %q", text, want)
+	}
+
+	q := &personalDictionary{loaded: true, id: -1, words: map[string]bool{}}
+	q.parse(text)
+	if !reflect.DeepEqual(q.words, p.words) {
+		t.Errorf("parse(text()) = %v, want %v", q.words, p.words)
+	}
+
+	tests := []struct {
+		word        string
+		good, found bool
+	}{
+		{"vimango", true, true},
+		{"Vimango", true, true},
+		{"gopher", false, false},
+		{"teh", false, true},
+		{"other", false, false},
+	}
+	for _, tt := range tests {
+		if good, found := q.lookup(tt.word); good != tt.good || found != tt.found {
+			t.Errorf("lookup(%q) = %v, %v, want %v, %v", tt.word, good, found, tt.good, tt.found)
+		}
+	}
+}
+
+// newWordListDB returns a database with just enough of the task table for
+// the personal dictionary
+func newWordListDB(t *testing.T) *Database {
+	t.Helper()
+	open := func(schema string) *sql.DB {
+		db, err := sql.Open("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1) // every connection to :memory: is a new database
+		if _, err := db.Exec(schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	return &Database{
+		MainDB: open(`CREATE TABLE task (id INTEGER PRIMARY KEY, tid INTEGER, title TEXT, note TEXT,
+			folder_uuid TEXT, context_uuid TEXT, star BOOLEAN DEFAULT FALSE, added TEXT, modified TEXT,
+			deleted BOOLEAN DEFAULT FALSE);`),
+		FtsDB: open(`CREATE TABLE fts (title TEXT, note TEXT, tag TEXT, tid INTEGER);`),
+	}
+}
+
+func TestPersonalDictionaryMergesCopies(t *testing.T) {
+	saved := app
+	t.Cleanup(func() { app = saved })
+	db := newWordListDB(t)
+	app = &App{Database: db}
+
+	// the word list as created on two devices and brought together by sync
+	for _, note := range []string{"# list\nalpha\nbeta/!", "# list\nbeta\ngamma"} {
+		if _, err := db.MainDB.Exec("INSERT INTO task (title, note) VALUES (?, ?);", personalDictionaryTitle, note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &personalDictionary{id: -1}
+	p.load()
+	want := map[string]bool{"alpha": true, "beta": true, "gamma": true}
+	if !reflect.DeepEqual(p.words, want) {
+		t.Errorf("loaded words = %v, want %v", p.words, want)
+	}
+	if p.id != 1 || !reflect.DeepEqual(p.copies, []int{2}) {
+		t.Errorf("loaded id %d copies %v, want 1 [2]", p.id, p.copies)
+	}
+	if !p.isNote(2) || p.isNote(3) {
+		t.Errorf("isNote(2) = %v, isNote(3) = %v, want true, false", p.isNote(2), p.isNote(3))
+	}
+
+	if err := p.set("delta", true); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := p.remove("alpha"); !removed || err != nil {
+		t.Fatalf("remove(alpha) = %v, %v", removed, err)
+	}
+
+	// a fresh load, as after a restart or a sync, sees the merged list in
+	// a single note
+	p = &personalDictionary{id: -1}
+	p.load()
+	want = map[string]bool{"beta": true, "gamma": true, "delta": true}
+	if !reflect.DeepEqual(p.words, want) {
+		t.Errorf("reloaded words = %v, want %v", p.words, want)
+	}
+	if p.id != 1 || len(p.copies) != 0 {
+		t.Errorf("reloaded id %d copies %v, want 1 []", p.id, p.copies)
+	}
+}
+
+func TestPersonalDictionaryKeepsTheSameCopyOnEveryDevice(t *testing.T) {
+	saved := app
+	t.Cleanup(func() { app = saved })
+
+	tests := []struct {
+		name     string
+		tids     []any // server ids of the copies in local id order
+		wantKept int   // local id of the copy that survives
+	}{
+		{"local ids in server order", []any{3, 7}, 1},
+		{"local ids the other way round", []any{7, 3}, 2},
+		{"unsynced copy first", []any{nil, 7}, 2},
+		{"unsynced copy with tid 0 first", []any{0, 7}, 2},
+	}
+	for _, tt := range tests {
+		db := newWordListDB(t)
+		app = &App{Database: db}
+		for i, tid := range tt.tids {
+			if _, err := db.MainDB.Exec("INSERT INTO task (tid, title, note) VALUES (?, ?, ?);",
+				tid, personalDictionaryTitle, fmt.Sprintf("word%d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		p := &personalDictionary{id: -1}
+		if err := p.set("new", true); err != nil {
+			t.Fatal(err)
+		}
+		if p.id != tt.wantKept {
+			t.Errorf("%s: kept note %d, want %d", tt.name, p.id, tt.wantKept)
+		}
+		var left int
+		db.MainDB.QueryRow("SELECT count(*) FROM task WHERE deleted=False;").Scan(&left)
+		if left != 1 {
+			t.Errorf("%s: %d notes left, want 1", tt.name, left)
+		}
+		want := map[string]bool{"word0": true, "word1": true, "new": true}
+		if !reflect.DeepEqual(p.words, want) {
+			t.Errorf("%s: words = %v, want %v", tt.name, p.words, want)
+		}
+	}
+}
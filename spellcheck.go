@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
 
 // SpellChecker provides an interface for spell checking functionality
 type SpellChecker interface {
@@ -14,43 +18,85 @@ type SpellChecker interface {
 	Suggest(word string) []string
 }
 
-// Global spell checker instance
-var globalSpellChecker SpellChecker
+// defaultSpellLang is the dictionary used when neither the note, its
+// context nor the config choose one
+const defaultSpellLang = "en_US"
 
-// GetSpellChecker returns the global spell checker instance
-func GetSpellChecker() SpellChecker {
-	if globalSpellChecker == nil {
-		globalSpellChecker = createSpellChecker()
+// Spell checkers by dictionary name (e.g. en_US, de_DE)
+var spellCheckers = make(map[string]SpellChecker)
+
+// GetSpellChecker returns the spell checker for a dictionary
+func GetSpellChecker(lang string) SpellChecker {
+	checker, ok := spellCheckers[lang]
+	if !ok {
+		checker = createSpellChecker(lang)
+		spellCheckers[lang] = checker
 	}
-	return globalSpellChecker
+	return checker
 }
 
-// IsSpellCheckAvailable returns true if spell checking is available
-func IsSpellCheckAvailable() bool {
-	return GetSpellChecker().IsAvailable()
+// IsSpellCheckAvailable returns true if spell checking with the dictionary is available
+func IsSpellCheckAvailable(lang string) bool {
+	return GetSpellChecker(lang).IsAvailable()
 }
 
-// CheckSpelling checks if a word is spelled correctly
-func CheckSpelling(word string) bool {
-	checker := GetSpellChecker()
+// CheckSpelling checks if a word is spelled correctly; the personal word
+// list is consulted before the dictionary
+func CheckSpelling(lang, word string) bool {
+	if good, found := personalWords.lookup(word); found {
+		return good
+	}
+	checker := GetSpellChecker(lang)
 	if !checker.IsAvailable() {
 		return true // If spell check isn't available, assume words are correct
 	}
 	return checker.Spell(word)
 }
 
-// GetSpellingSuggestions returns spelling suggestions for a word
-func GetSpellingSuggestions(word string) []string {
-	checker := GetSpellChecker()
+// GetSpellingSuggestions returns spelling suggestions for a word, leaving
+// out words marked wrong in the personal word list
+func GetSpellingSuggestions(lang, word string) []string {
+	checker := GetSpellChecker(lang)
 	if !checker.IsAvailable() {
 		return []string{} // Return empty suggestions if not available
 	}
-	return checker.Suggest(word)
+	var suggestions []string
+	for _, s := range checker.Suggest(word) {
+		if good, found := personalWords.lookup(s); !found || good {
+			suggestions = append(suggestions, s)
+		}
+	}
+	return suggestions
+}
+
+// spellDictionaryDir is where the hunspell .aff and .dic files are
+func spellDictionaryDir() string {
+	if app != nil && app.Config != nil && app.Config.Spell.Dir != "" {
+		return app.Config.Spell.Dir
+	}
+	return "/usr/share/hunspell"
+}
+
+// spellDictionaryFiles returns the .aff and .dic files of a dictionary;
+// ok is false if they don't both exist
+func spellDictionaryFiles(lang string) (aff, dic string, ok bool) {
+	dir := spellDictionaryDir()
+	aff, dic = filepath.Join(dir, lang+".aff"), filepath.Join(dir, lang+".dic")
+	if _, err := os.Stat(aff); err != nil {
+		return aff, dic, false
+	}
+	if _, err := os.Stat(dic); err != nil {
+		return aff, dic, false
+	}
+	return aff, dic, true
 }
 
 // ShowSpellCheckNotAvailableMessage displays a user-friendly message
-func ShowSpellCheckNotAvailableMessage() string {
-	return fmt.Sprintf("%sSpell check not available on this platform%s", RED_BG, RESET)
+func ShowSpellCheckNotAvailableMessage(lang string) string {
+	if !isSpellCheckAvailableDefault {
+		return fmt.Sprintf("%sSpell check not available on this platform%s", RED_BG, RESET)
+	}
+	return fmt.Sprintf("%sNo usable %s dictionary in %s%s", RED_BG, lang, spellDictionaryDir(), RESET)
 }
 
 // Default implementation that will be overridden by build-specific files
@@ -58,14 +104,15 @@ var isSpellCheckAvailableDefault = false
 
 // createSpellChecker creates the appropriate spell checker implementation
 // This function will be overridden by build-specific files
-func createSpellChecker() SpellChecker {
+func createSpellChecker(lang string) SpellChecker {
 	if isSpellCheckAvailableDefault {
-		return createCGOSpellChecker()
+		return createDictionarySpellChecker(lang)
 	}
 	return createStubSpellChecker()
 }
 
-// createCGOSpellChecker will be implemented in build-specific files
+// createDictionarySpellChecker is implemented in build-specific files:
+// hunspell through cgo or the pure Go spell package
 
 func createStubSpellChecker() SpellChecker {
 	return &StubSpellChecker{}
@@ -14,10 +14,13 @@ type CGOSpellChecker struct {
 	hunspell *hunspell.Hunhandle
 }
 
-// createCGOSpellChecker creates a new CGO-based spell checker
-func createCGOSpellChecker() SpellChecker {
-	// Use the same paths as the original implementation
-	h := hunspell.Hunspell("/usr/share/hunspell/en_US.aff", "/usr/share/hunspell/en_US.dic")
+// createDictionarySpellChecker creates a new CGO-based spell checker for a dictionary
+func createDictionarySpellChecker(lang string) SpellChecker {
+	aff, dic, ok := spellDictionaryFiles(lang)
+	if !ok {
+		return &CGOSpellChecker{}
+	}
+	h := hunspell.Hunspell(aff, dic)
 	return &CGOSpellChecker{hunspell: h}
 }
 
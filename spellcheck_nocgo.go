@@ -2,10 +2,45 @@
 
 package main
 
-// No init function needed - isSpellCheckAvailableDefault remains false
+import "github.com/slzatz/vimango/spell"
 
-// createCGOSpellChecker provides a stub implementation when CGO is not available
-func createCGOSpellChecker() SpellChecker {
-	// CGO spell checker is not available, return stub implementation
-	return createStubSpellChecker()
-}
\ No newline at end of file
+func init() {
+	// The pure Go checker reads the same hunspell dictionaries
+	isSpellCheckAvailableDefault = true
+}
+
+// GoSpellChecker provides spell checking with hunspell dictionaries read in Go
+type GoSpellChecker struct {
+	dict *spell.Dictionary
+}
+
+// createDictionarySpellChecker creates a new pure Go spell checker for a dictionary
+func createDictionarySpellChecker(lang string) SpellChecker {
+	aff, dic, ok := spellDictionaryFiles(lang)
+	if !ok {
+		return &GoSpellChecker{}
+	}
+	d, err := spell.Open(aff, dic)
+	if err != nil {
+		return &GoSpellChecker{}
+	}
+	return &GoSpellChecker{dict: d}
+}
+
+func (g *GoSpellChecker) IsAvailable() bool {
+	return g.dict != nil
+}
+
+func (g *GoSpellChecker) Spell(word string) bool {
+	if g.dict == nil {
+		return true // Fallback: assume words are correct if the dictionary couldn't be read
+	}
+	return g.dict.Spell(word)
+}
+
+func (g *GoSpellChecker) Suggest(word string) []string {
+	if g.dict == nil {
+		return []string{}
+	}
+	return g.dict.Suggest(word)
+}
@@ -987,6 +987,81 @@ func (a *App) deleteKeywordFromBoth(c Container, isServerDeleted bool, lg io.Wri
 	}
 }
 
+// syncAttachments copies attachments created since the last sync to the
+// other side. Attachments never change, so the client's new ones are sent
+// first and then the server's new ones the client doesn't have are fetched.
+func (a *App) syncAttachments(serverTime, clientTime string, lg io.Writer) {
+	fmt.Fprint(lg, "## Attachments\n")
+
+	if _, err := a.Database.PG.Exec(pgAttachmentSchema); err != nil {
+		fmt.Fprintf(lg, "Error creating server attachment table: %v\n", err)
+		return
+	}
+
+	rows, err := a.Database.MainDB.Query("SELECT uuid, mime, bytes, hash FROM attachment WHERE substr(created, 1, 19) > ?;", clientTime)
+	if err != nil {
+		fmt.Fprintf(lg, "Error in SELECT for client attachments: %v\n", err)
+		return
+	}
+	var sent int
+	for rows.Next() {
+		var id, mime, hash string
+		var data []byte
+		if err := rows.Scan(&id, &mime, &data, &hash); err != nil {
+			fmt.Fprintf(lg, "Error reading client attachment: %v\n", err)
+			continue
+		}
+		_, err := a.Database.PG.Exec("INSERT INTO attachment (uuid, mime, bytes, hash) VALUES ($1, $2, $3, $4) ON CONFLICT (uuid) DO NOTHING;",
+			id, mime, data, hash)
+		if err != nil {
+			fmt.Fprintf(lg, "Error inserting attachment %s on server: %v\n", id, err)
+			continue
+		}
+		sent++
+	}
+	rows.Close()
+
+	rows, err = a.Database.PG.Query("SELECT uuid FROM attachment WHERE created > $1;", serverTime)
+	if err != nil {
+		fmt.Fprintf(lg, "Error in SELECT for server attachments: %v\n", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var received int
+	for _, id := range ids {
+		var exists bool
+		a.Database.MainDB.QueryRow("SELECT EXISTS(SELECT 1 FROM attachment WHERE uuid=?);", id).Scan(&exists)
+		if exists {
+			continue
+		}
+		var mime, hash string
+		var data []byte
+		var created time.Time
+		err := a.Database.PG.QueryRow("SELECT mime, bytes, hash, created FROM attachment WHERE uuid=$1;", id).Scan(&mime, &data, &hash, &created)
+		if err != nil {
+			fmt.Fprintf(lg, "Error fetching attachment %s from server: %v\n", id, err)
+			continue
+		}
+		_, err = a.Database.MainDB.Exec("INSERT INTO attachment (uuid, mime, bytes, hash, created) VALUES (?, ?, ?, ?, ?);",
+			id, mime, data, hash, created.Format("2006-01-02 15:04:05"))
+		if err != nil {
+			fmt.Fprintf(lg, "Error inserting attachment %s on client: %v\n", id, err)
+			continue
+		}
+		received++
+	}
+
+	fmt.Fprintf(lg, "- Attachments sent to server: %d\n", sent)
+	fmt.Fprintf(lg, "- Attachments received from server: %d\n", received)
+}
+
 // Synchronize synchronizes data between local and remote databases
 // reportOnly: if true, only reports changes without applying them
 func (a *App) Synchronize(reportOnly bool) (log string) {
@@ -1075,6 +1150,9 @@ func (a *App) Synchronize(reportOnly bool) (log string) {
 	// Sync entries: client -> server
 	a.syncEntriesToServer(changes.clientUpdatedEntries, serverUpdatedTids, &lg)
 
+	// Sync attachments: both ways
+	a.syncAttachments(serverTime, clientTime, &lg)
+
 	// Delete entries
 	a.deleteServerEntriesFromClient(changes.serverDeletedEntries, &lg)
 	a.deleteClientEntriesFromServer(changes.clientDeletedEntries, &lg)
@@ -62,7 +62,7 @@ func isValidGoogleDriveID(id string) bool {
 // decodeImageWithOrientation decodes an image and applies EXIF orientation correction.
 // Uses the imaging library's AutoOrientation feature to handle phone camera images
 // that store orientation in EXIF metadata. Falls back gracefully if no EXIF present.
-// Now supports HEIC format when CGO is available.
+// Supports HEIC and AVIF through their WASM decoders and WebP.
 func decodeImageWithOrientation(r io.Reader) (image.Image, string, error) {
 	// Buffer the input so we can read it multiple times
 	data, err := io.ReadAll(r)
@@ -82,7 +82,16 @@ func decodeImageWithOrientation(r io.Reader) (image.Image, string, error) {
 			return img, "heic", nil
 		}
 		// HEIC not available - return meaningful error
-		return nil, "", fmt.Errorf("HEIC format detected but not supported (requires CGO with libheif or .venv with pillow-heif)")
+		return nil, "", fmt.Errorf("HEIC format detected but not supported (the HEIC decoder failed to load)")
+	}
+
+	// AVIF is decoded directly; libavif applies its orientation
+	if IsAVIFData(data) {
+		img, err := decodeAVIF(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return img, "avif", nil
 	}
 
 	// Detect format using standard library for non-HEIC formats
@@ -0,0 +1,85 @@
+package terminal
+
+import "fmt"
+
+// Mouse buttons
+const (
+	MouseLeft = iota
+	MouseMiddle
+	MouseRight
+	MouseNone // motion without a button
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+// Mouse is a mouse event reported in the SGR (1006) format
+type Mouse struct {
+	Button  int // MouseLeft ... MouseWheelRight
+	X, Y    int // 1-based column and row
+	Release bool
+	Drag    bool // the pointer moved with Button held
+	Shift   bool
+	Alt     bool
+	Ctrl    bool
+}
+
+// EnableMouse asks the terminal to report button presses, releases,
+// drags and the wheel as SGR mouse events
+func EnableMouse() {
+	fmt.Print("\x1b[?1002h\x1b[?1006h")
+}
+
+// DisableMouse gives the mouse back to the terminal, e.g. for selecting
+// text
+func DisableMouse() {
+	fmt.Print("\x1b[?1006l\x1b[?1002l")
+}
+
+// readMouse reads the rest of an SGR mouse report, "b;x;yM" for a press
+// or "b;x;ym" for a release, after the \x1b[<
+func readMouse() (Key, error) {
+	var params [3]int
+	i := 0
+	for n := 0; n < 32; n++ {
+		b, err := bufr.ReadByte()
+		if err != nil {
+			return Key{}, err
+		}
+		switch {
+		case b >= '0' && b <= '9':
+			params[i] = params[i]*10 + int(b-'0')
+		case b == ';' && i < 2:
+			i++
+		case (b == 'M' || b == 'm') && i == 2:
+			return Key{Special: KeyMouse, Mouse: parseMouse(params[0], params[1], params[2], b == 'm')}, nil
+		default:
+			return Key{Regular: 27, Special: KeyNoSpl}, nil
+		}
+	}
+	return Key{Regular: 27, Special: KeyNoSpl}, nil
+}
+
+// parseMouse decodes the button code of an SGR mouse report
+func parseMouse(code, x, y int, release bool) Mouse {
+	m := Mouse{
+		X:       x,
+		Y:       y,
+		Release: release,
+		Shift:   code&4 != 0,
+		Alt:     code&8 != 0,
+		Ctrl:    code&16 != 0,
+		Drag:    code&32 != 0,
+	}
+	button := code &^ (4 | 8 | 16 | 32)
+	if button >= 64 {
+		m.Button = MouseWheelUp + button - 64
+		if m.Button > MouseWheelRight {
+			m.Button = MouseNone
+		}
+	} else {
+		m.Button = button
+	}
+	return m
+}
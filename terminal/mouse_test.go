@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseMouse(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		release bool
+		want    Mouse
+	}{
+		{"left press", 0, false, Mouse{Button: MouseLeft, X: 3, Y: 7}},
+		{"middle press", 1, false, Mouse{Button: MouseMiddle, X: 3, Y: 7}},
+		{"right release", 2, true, Mouse{Button: MouseRight, X: 3, Y: 7, Release: true}},
+		{"left drag", 32, false, Mouse{Button: MouseLeft, X: 3, Y: 7, Drag: true}},
+		{"motion without a button", 35, false, Mouse{Button: MouseNone, X: 3, Y: 7, Drag: true}},
+		{"wheel up", 64, false, Mouse{Button: MouseWheelUp, X: 3, Y: 7}},
+		{"wheel down", 65, false, Mouse{Button: MouseWheelDown, X: 3, Y: 7}},
+		{"wheel left", 66, false, Mouse{Button: MouseWheelLeft, X: 3, Y: 7}},
+		{"wheel right", 67, false, Mouse{Button: MouseWheelRight, X: 3, Y: 7}},
+		{"extra button", 128, false, Mouse{Button: MouseNone, X: 3, Y: 7}},
+		{"shift", 4, false, Mouse{Button: MouseLeft, X: 3, Y: 7, Shift: true}},
+		{"alt", 8, false, Mouse{Button: MouseLeft, X: 3, Y: 7, Alt: true}},
+		{"ctrl", 16, false, Mouse{Button: MouseLeft, X: 3, Y: 7, Ctrl: true}},
+		{"ctrl wheel down", 81, false, Mouse{Button: MouseWheelDown, X: 3, Y: 7, Ctrl: true}},
+		{"every modifier dragging right", 62, false, Mouse{Button: MouseRight, X: 3, Y: 7, Shift: true, Alt: true, Ctrl: true, Drag: true}},
+	}
+	for _, tt := range tests {
+		if got := parseMouse(tt.code, 3, 7, tt.release); got != tt.want {
+			t.Errorf("%s: parseMouse(%d) = %+v, want %+v", tt.name, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestReadMouse(t *testing.T) {
+	esc := Key{Regular: 27, Special: KeyNoSpl}
+	tests := []struct {
+		input string
+		want  Key
+	}{
+		{"\x1b[<0;12;5M", Key{Special: KeyMouse, Mouse: Mouse{Button: MouseLeft, X: 12, Y: 5}}},
+		{"\x1b[<0;12;5m", Key{Special: KeyMouse, Mouse: Mouse{Button: MouseLeft, X: 12, Y: 5, Release: true}}},
+		{"\x1b[<32;140;48M", Key{Special: KeyMouse, Mouse: Mouse{Button: MouseLeft, X: 140, Y: 48, Drag: true}}},
+		{"\x1b[<65;1;1M", Key{Special: KeyMouse, Mouse: Mouse{Button: MouseWheelDown, X: 1, Y: 1}}},
+		{"\x1b[<20;2;3M", Key{Special: KeyMouse, Mouse: Mouse{Button: MouseLeft, X: 2, Y: 3, Shift: true, Ctrl: true}}},
+		{"\x1b[<0;12M", esc},
+		{"\x1b[<0;1;2;3M", esc},
+		{"\x1b[<0;x;5M", esc},
+		{"\x1b[<" + strings.Repeat("1", 40) + "M", esc},
+	}
+	saved := bufr
+	defer func() { bufr = saved }()
+	for _, tt := range tests {
+		bufr = bufio.NewReader(strings.NewReader(tt.input))
+		got, err := ReadKey()
+		if err != nil {
+			t.Errorf("ReadKey(%q) returned error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ReadKey(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
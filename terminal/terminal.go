@@ -31,6 +31,8 @@ const (
 	KeyF11
 	KeyF12
 	KeyIns
+	KeyShiftTab
+	KeyMouse // Key.Mouse holds the mouse event
 )
 
 var specialKeys = map[[4]byte]int{
@@ -53,6 +55,7 @@ var specialKeys = map[[4]byte]int{
   [4]byte{91, 49, 57, 126} : KeyF8,
   [4]byte{91, 50, 48, 126} : KeyF9,
   [4]byte{91, 50, 126, 0}  : KeyIns,
+  [4]byte{91, 90, 0, 0}    : KeyShiftTab, // \x1b[Z
 }
 // ErrNoInput indicates that there is no input when reading from keyboard
 // in raw mode. This happens when timeout is set to a low number
@@ -62,6 +65,7 @@ var ErrNoInput = fmt.Errorf("no input")
 type Key struct {
 	Regular rune
 	Special int
+	Mouse   Mouse
 }
 
 var bufr = bufio.NewReader(os.Stdin)
@@ -87,12 +91,20 @@ func ReadKey() (Key, error) {
 
   //ascii escape is decimal 27
 	if r != 27 {
-		return Key{r, KeyNoSpl}, nil
+		return Key{Regular: r, Special: KeyNoSpl}, nil
 	}
 
 	// nothing has been buffered, probably plain escape
 	if bufr.Buffered() == 0 {
-		return Key{27, KeyNoSpl}, nil
+		return Key{Regular: 27, Special: KeyNoSpl}, nil
+	}
+
+	// SGR mouse reports start with \x1b[<
+	if bufr.Buffered() >= 2 {
+		if p, err := bufr.Peek(2); err == nil && p[0] == '[' && p[1] == '<' {
+			bufr.Discard(2)
+			return readMouse()
+		}
 	}
 
 	stack := [4]byte{}
@@ -106,11 +118,11 @@ func ReadKey() (Key, error) {
 
 		//if match, key := matchSplKeys(stack); match {
 		if key, found := specialKeys[stack]; found {
-			return Key{0, key}, nil
+			return Key{Special: key}, nil
 		}
 	}
 	// we couldn't make out the special key, let's just return escape
 	// this is probably wrong but unless we have a custom bufio.Reader,
 	// we can't do better
-	return Key{27, KeyNoSpl}, nil
+	return Key{Regular: 27, Special: KeyNoSpl}, nil
 }
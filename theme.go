@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+	glamourstyles "github.com/charmbracelet/glamour/styles"
+)
+
+// A theme sets the colors of the organizer, editor and status bars along
+// with the chroma styles used to highlight notes and the glamour style used
+// to render them. Themes are JSON files in themesDir, e.g. themes/light.json:
+//
+//	{
+//	  "ui": {
+//	    "status_bar": "reverse",
+//	    "selected_row": "bg=#3c3836",
+//	    "search_highlight": "fg=black bg=yellow"
+//	  },
+//	  "chroma": {"code": "gruvbox", "markdown": "gruvbox_mod.xml"},
+//	  "glamour": "darkslz.json"
+//	}
+//
+// A ui style is a list of bold, dim, italic, underline, reverse, fg=COLOR
+// and bg=COLOR, where COLOR is black, red, green, yellow, blue, magenta,
+// cyan, white, default, a 256-color number or #rrggbb. Anything a theme
+// leaves out comes from the default theme. When NO_COLOR is set vimango
+// starts with the mono theme.
+
+// themesDir holds the theme files
+var themesDir = "themes"
+
+// sgrParams returns the parameters of an SGR escape sequence ("1;36" for
+// "\x1b[1;36m"), as used by DECCARA
+func sgrParams(style string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(style, "\x1b["), "m")
+}
+
+var themeColorNames = map[string]int{
+	"black": 0, "red": 1, "green": 2, "yellow": 3, "blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+}
+
+var themeAttributes = map[string]string{
+	"bold": "1", "dim": "2", "italic": "3", "underline": "4", "reverse": "7",
+}
+
+// parseThemeStyle turns "bold fg=cyan bg=236" into an SGR escape sequence
+func parseThemeStyle(spec string) (string, error) {
+	var params []string
+	for _, field := range strings.Fields(spec) {
+		key, value, found := strings.Cut(strings.ToLower(field), "=")
+		if !found {
+			p, ok := themeAttributes[key]
+			if !ok {
+				return "", fmt.Errorf("unknown attribute %q", field)
+			}
+			params = append(params, p)
+			continue
+		}
+		var base int
+		switch key {
+		case "fg":
+			base = 30
+		case "bg":
+			base = 40
+		default:
+			return "", fmt.Errorf("unknown style %q", field)
+		}
+		p, err := themeColor(value, base)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, p)
+	}
+	if len(params) == 0 {
+		return "", nil
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m", nil
+}
+
+// themeColor returns the SGR parameters that set a color, base being 30
+// for the foreground and 40 for the background
+func themeColor(color string, base int) (string, error) {
+	if n, ok := themeColorNames[color]; ok {
+		return strconv.Itoa(base + n), nil
+	}
+	if color == "default" {
+		return strconv.Itoa(base + 9), nil
+	}
+	if n, err := strconv.Atoi(color); err == nil && n >= 0 && n <= 255 {
+		return fmt.Sprintf("%d;5;%d", base+8, n), nil
+	}
+	if hex, ok := strings.CutPrefix(color, "#"); ok && len(hex) == 6 {
+		if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return fmt.Sprintf("%d;2;%d;%d;%d", base+8, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+		}
+	}
+	return "", fmt.Errorf("unknown color %q", color)
+}
+
+// ThemeUI are the colors of the organizer, editor and status bars as SGR
+// escape sequences
+type ThemeUI struct {
+	StatusBar       string // organizer and editor status bars
+	StatusAccent    string // keywords in the organizer status bar
+	StatusMode      string // mode in the organizer status bar, drawn over StatusBar
+	SelectedRow     string // selected row of the contexts, folders and keywords list
+	TitleSelection  string // visual selection in an organizer title
+	VisualSelection string // visual selection and matching bracket in the editor
+	SearchHighlight string // search matches in the editor and preview
+	LineNumbers     string
+	NoticeBox       string // background of notices and the help box
+	Border          string // window frames and dividers
+	Row             string // organizer rows and sort column
+	TimeMarker      string // rows sorted by a time keyword
+	Archived        string
+	Deleted         string
+	ArchivedDeleted string
+	Dirty           string // rows with unsaved changes
+	Marked          string // rows marked for a batch operation
+	ImageMarker     string
+	Fold            string // summary of a closed fold and lines left out of a diff
+	DiffRemoved     string // lines a diff removes
+	DiffAdded       string // lines a diff adds
+	Starred         string // starred rows of the contexts, folders and keywords list
+	Menu            string // insert-mode completion menu
+	MenuSelected    string // selected item of the completion menu
+	DiagError       string // language server diagnostics by severity
+	DiagWarning     string
+	DiagInfo        string
+	DiagHint        string
+}
+
+// styles maps the style names used in theme files to their fields
+func (u *ThemeUI) styles() map[string]*string {
+	return map[string]*string{
+		"status_bar":       &u.StatusBar,
+		"status_accent":    &u.StatusAccent,
+		"status_mode":      &u.StatusMode,
+		"selected_row":     &u.SelectedRow,
+		"title_selection":  &u.TitleSelection,
+		"visual_selection": &u.VisualSelection,
+		"search_highlight": &u.SearchHighlight,
+		"line_numbers":     &u.LineNumbers,
+		"notice_box":       &u.NoticeBox,
+		"border":           &u.Border,
+		"row":              &u.Row,
+		"time_marker":      &u.TimeMarker,
+		"archived":         &u.Archived,
+		"deleted":          &u.Deleted,
+		"archived_deleted": &u.ArchivedDeleted,
+		"dirty":            &u.Dirty,
+		"marked":           &u.Marked,
+		"image_marker":     &u.ImageMarker,
+		"fold":             &u.Fold,
+		"diff_removed":     &u.DiffRemoved,
+		"diff_added":       &u.DiffAdded,
+		"starred":          &u.Starred,
+		"menu":             &u.Menu,
+		"menu_selected":    &u.MenuSelected,
+		"diag_error":       &u.DiagError,
+		"diag_warning":     &u.DiagWarning,
+		"diag_info":        &u.DiagInfo,
+		"diag_hint":        &u.DiagHint,
+	}
+}
+
+// UnmarshalJSON sets the styles a theme file gives, leaving the rest as
+// they are
+func (u *ThemeUI) UnmarshalJSON(data []byte) error {
+	var specs map[string]string
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return err
+	}
+	fields := u.styles()
+	for name, spec := range specs {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown ui style %q", name)
+		}
+		style, err := parseThemeStyle(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		*field = style
+	}
+	return nil
+}
+
+// Theme is a theme file
+type Theme struct {
+	Name   string  `json:"-"`
+	UI     ThemeUI `json:"ui"`
+	Chroma struct {
+		Code     string `json:"code"`     // chroma style of code notes; cycled by the editor's style key
+		Markdown string `json:"markdown"` // chroma style or XML style file of markdown notes; "" is chroma.style in config.json
+	} `json:"chroma"`
+	Glamour string `json:"glamour"` // glamour style or style file; "" is glamour.style in config.json
+}
+
+// defaultTheme is vimango's original colors
+func defaultTheme() *Theme {
+	t := &Theme{Name: "default"}
+	t.UI = ThemeUI{
+		StatusBar:       "\x1b[7m",
+		StatusAccent:    "\x1b[35;7m",
+		StatusMode:      "\x1b[1;42m",
+		SelectedRow:     "\x1b[48;5;236m",
+		TitleSelection:  LIGHT_GRAY_BG,
+		VisualSelection: "\x1b[48;5;237m",
+		SearchHighlight: "\x1b[48;5;31m",
+		LineNumbers:     "\x1b[48;5;235;38;5;245m",
+		NoticeBox:       "\x1b[48;5;235m",
+		Border:          "\x1b[37;1m",
+		Row:             WHITE,
+		TimeMarker:      CYAN,
+		Archived:        YELLOW,
+		Deleted:         RED,
+		ArchivedDeleted: GREEN,
+		Dirty:           BLACK + WHITE_BG,
+		Marked:          BLACK + YELLOW_BG,
+		ImageMarker:     WHITE,
+		Fold:            "\x1b[38;5;245m",
+		DiffRemoved:     RED,
+		DiffAdded:       GREEN,
+		Starred:         "\x1b[1;36m",
+		Menu:            DARK_GRAY_BG + WHITE,
+		MenuSelected:    LIGHT_GRAY_BG + WHITE_BOLD,
+		DiagError:       RED,
+		DiagWarning:     YELLOW,
+		DiagInfo:        BLUE,
+		DiagHint:        CYAN,
+	}
+	t.Chroma.Code = "gruvbox"
+	return t
+}
+
+// monoTheme uses attributes instead of colors, for NO_COLOR
+func monoTheme() *Theme {
+	t := &Theme{Name: "mono"}
+	t.UI = ThemeUI{
+		StatusBar:       "\x1b[7m",
+		StatusAccent:    "\x1b[7m",
+		StatusMode:      "\x1b[1m",
+		SelectedRow:     "\x1b[7m",
+		TitleSelection:  "\x1b[7m",
+		VisualSelection: "\x1b[7m",
+		SearchHighlight: "\x1b[4m",
+		LineNumbers:     "\x1b[2m",
+		Border:          "\x1b[1m",
+		Archived:        "\x1b[3m",
+		Deleted:         "\x1b[2m",
+		ArchivedDeleted: "\x1b[2;3m",
+		Dirty:           "\x1b[4m",
+		Marked:          "\x1b[7m",
+		Fold:            "\x1b[2m",
+		DiffRemoved:     "\x1b[2m",
+		DiffAdded:       "\x1b[1m",
+		Starred:         "\x1b[1m",
+		Menu:            "\x1b[7m",
+		MenuSelected:    "\x1b[1m",
+		DiagError:       "\x1b[1m",
+		DiagWarning:     "\x1b[4m",
+		DiagInfo:        "\x1b[3m",
+		DiagHint:        "\x1b[2m",
+	}
+	t.Chroma.Code = "bw"
+	t.Chroma.Markdown = "bw"
+	t.Glamour = glamourstyles.NoTTYStyle
+	return t
+}
+
+// builtinThemes are the themes that don't need a file
+var builtinThemes = map[string]func() *Theme{
+	"default": defaultTheme,
+	"mono":    monoTheme,
+}
+
+// loadTheme returns the built-in theme or the theme in themesDir named name
+func loadTheme(name string) (*Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	path := filepath.Join(themesDir, name+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if builtin, ok := builtinThemes[name]; ok {
+			return builtin(), nil
+		}
+		return nil, fmt.Errorf("no theme %q (looked for %s)", name, path)
+	}
+	t := defaultTheme()
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	t.Name = name
+	if t.Chroma.Code != "" && styles.Registry[t.Chroma.Code] == nil {
+		return nil, fmt.Errorf("%s: unknown chroma style %q", path, t.Chroma.Code)
+	}
+	if t.Chroma.Markdown != "" {
+		if _, err := themeChromaStyle(t.Chroma.Markdown); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if t.Glamour != "" && glamourstyles.DefaultStyles[t.Glamour] == nil {
+		if _, err := os.Stat(t.Glamour); err != nil {
+			return nil, fmt.Errorf("%s: no glamour style %q", path, t.Glamour)
+		}
+	}
+	return t, nil
+}
+
+// themeNames lists the built-in themes and the theme files
+func themeNames() []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	files, _ := filepath.Glob(filepath.Join(themesDir, "*.json"))
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".json")
+		if _, ok := builtinThemes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeChromaStyle returns a chroma style by name or from an XML style file
+func themeChromaStyle(style string) (*chroma.Style, error) {
+	if s, ok := styles.Registry[style]; ok {
+		return s, nil
+	}
+	return selectMDStyle(style)
+}
+
+// setTheme makes the theme named name the current theme; the screen is
+// redrawn by the caller
+func (a *App) setTheme(name string) error {
+	t, err := loadTheme(name)
+	if err != nil {
+		return err
+	}
+	markdown := t.Chroma.Markdown
+	if markdown == "" && a.Config != nil {
+		markdown = a.Config.Chroma.Style
+	}
+	if s, err := themeChromaStyle(markdown); err == nil {
+		a.Session.markdown_style = s
+	}
+	if t.Chroma.Code != "" {
+		i := a.Session.styleIndex
+		for j, s := range a.Session.style {
+			if s == t.Chroma.Code {
+				i = j
+			}
+		}
+		a.Session.style[i] = t.Chroma.Code
+		a.Session.styleIndex = i
+	}
+	a.theme = t
+	return nil
+}
+
+// startupTheme is the theme vimango starts with: mono if NO_COLOR is set,
+// otherwise the theme last chosen with :theme
+func startupTheme(saved string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return "mono"
+	}
+	return saved
+}
+
+// chooseTheme implements :theme for the organizer and the editor
+func (a *App) chooseTheme(args string, show func(format string, a ...interface{})) {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		show("Theme: %s (available: %s)", a.theme.Name, strings.Join(themeNames(), ", "))
+		return
+	}
+	if err := a.setTheme(name); err != nil {
+		show("%sCould not load theme: %v%s", RED_BG, err, RESET)
+		return
+	}
+	a.themeChoice = name
+	if err := a.SavePreferences(); err != nil {
+		// Silently ignore save errors (preferences not critical)
+	}
+	a.moveDividerPct(a.Screen.edPct) // redraws everything
+	show("Theme: %s", name)
+}
+
+// setTheme switches themes (:theme [name])
+func (o *Organizer) setTheme(pos int) {
+	var name string
+	if pos != -1 {
+		name = o.command_line[pos+1:]
+	}
+	o.mode = NORMAL
+	o.command_line = ""
+	app.chooseTheme(name, func(format string, a ...interface{}) { o.ShowMessage(BL, format, a...) })
+}
+
+// setTheme switches themes (:theme [name])
+func (e *Editor) setTheme() {
+	_, name, _ := strings.Cut(e.command_line, " ")
+	app.chooseTheme(name, func(format string, a ...interface{}) { e.ShowMessage(BR, format, a...) })
+}
@@ -158,3 +158,26 @@ func (b *CGOBufferWrapper) GetLastChangedTick() int {
 
 // SetLines is a no-op on Windows.
 func (b *CGOBufferWrapper) SetLines(start, end int, lines []string) {}
+
+// UndoStates is a no-op on Windows.
+func (e *CGOEngineWrapper) UndoStates() []interfaces.UndoState {
+	return nil
+}
+
+// UndoJump is a no-op on Windows.
+func (e *CGOEngineWrapper) UndoJump(seq int) {}
+
+// SearchMatches is a no-op on Windows.
+func (e *CGOEngineWrapper) SearchMatches(pattern string) []interfaces.SearchMatch {
+	return nil
+}
+
+// UndoHistory is a no-op on Windows.
+func (b *CGOBufferWrapper) UndoHistory() ([]byte, error) {
+	return nil, nil
+}
+
+// RestoreUndoHistory is a no-op on Windows.
+func (b *CGOBufferWrapper) RestoreUndoHistory(data []byte) error {
+	return nil
+}
@@ -136,12 +136,16 @@ func (e *GoEngineWrapper) Execute(s string) {
 // GetMode gets the current mode
 func (e *GoEngineWrapper) GetMode() int {
 	mode := e.engine.GetMode()
+	// libvim reports / and ? as command-line mode, which is what the editor expects
+	if mode == govim.ModeSearch {
+		return govim.ModeCommand
+	}
 	return mode
 }
 
 // GetCurrentMode gets the current mode with application-compatible mappings
 func (e *GoEngineWrapper) GetCurrentMode() int {
-	return e.engine.GetMode()
+	return e.GetMode()
 }
 
 // GetCurrentMode gets the current mode with application-compatible mappings
@@ -266,3 +270,40 @@ func (b *GoBufferWrapper) SetLines(start, end int, lines []string) {
 	// Update the buffer
 	b.buf.SetLines(start, end, safeLines)
 }
+
+// UndoStates returns the undo history of the current buffer
+func (e *GoEngineWrapper) UndoStates() []interfaces.UndoState {
+	var states []interfaces.UndoState
+	for _, s := range e.engine.UndoStates() {
+		states = append(states, interfaces.UndoState{
+			Seq:     s.Seq,
+			Time:    s.Time,
+			Current: s.Current,
+		})
+	}
+	return states
+}
+
+// UndoJump moves the current buffer to undo state seq
+func (e *GoEngineWrapper) UndoJump(seq int) {
+	e.engine.UndoJump(seq)
+}
+
+// SearchMatches returns every match of pattern in the current buffer
+func (e *GoEngineWrapper) SearchMatches(pattern string) []interfaces.SearchMatch {
+	var matches []interfaces.SearchMatch
+	for _, m := range e.engine.SearchMatches(pattern) {
+		matches = append(matches, interfaces.SearchMatch{Line: m.Row, Start: m.Start, End: m.End})
+	}
+	return matches
+}
+
+// UndoHistory serializes the buffer's undo and redo stacks
+func (b *GoBufferWrapper) UndoHistory() ([]byte, error) {
+	return b.buf.MarshalUndo()
+}
+
+// RestoreUndoHistory replaces the buffer's undo and redo stacks
+func (b *GoBufferWrapper) RestoreUndoHistory(data []byte) error {
+	return b.buf.UnmarshalUndo(data)
+}
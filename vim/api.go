@@ -164,6 +164,21 @@ func GetMatchingPair() [2]int {
 	return Engine.SearchGetMatchingPair()
 }
 
+// GetUndoStates returns the undo history of the current buffer
+func GetUndoStates() []interfaces.UndoState {
+	return Engine.UndoStates()
+}
+
+// UndoJump moves the current buffer to undo state seq
+func UndoJump(seq int) {
+	Engine.UndoJump(seq)
+}
+
+// GetSearchMatches returns every match of a search pattern in the current buffer
+func GetSearchMatches(pattern string) []interfaces.SearchMatch {
+	return Engine.SearchMatches(pattern)
+}
+
 // IsUsingGoImplementation checks if we're using the Go implementation
 func IsUsingGoImplementation() bool {
 	return GetActiveImplementation() == ImplGo
@@ -0,0 +1,328 @@
+// Package compare replays keystroke sessions through two vim engines and
+// reports where their buffer contents, cursor position or mode diverge.
+// It is used to check the pure Go engine (govim) against libvim (cvim).
+package compare
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/slzatz/vimango/vim/interfaces"
+)
+
+// specialKeys are sent through VimEngine.Key, the same way the editor
+// forwards the entries of its termcodes map
+var specialKeys = map[string]struct{}{
+	"<esc>":      {},
+	"<up>":       {},
+	"<down>":     {},
+	"<left>":     {},
+	"<right>":    {},
+	"<bs>":       {},
+	"<home>":     {},
+	"<del>":      {},
+	"<pageup>":   {},
+	"<pagedown>": {},
+}
+
+// State is a snapshot of an engine after a step
+type State struct {
+	Lines  []string
+	Cursor [2]int
+	Mode   int
+}
+
+func (s State) String() string {
+	return fmt.Sprintf("mode=%d cursor=%v lines=%q", s.Mode, s.Cursor, s.Lines)
+}
+
+// Session is a recorded sequence of keystrokes applied to an initial buffer
+type Session struct {
+	Name  string
+	Lines []string
+	Steps []string // each step is a key sequence, e.g. "dw" or "ihello<esc>"
+}
+
+// Divergence describes the first step at which two engines disagree
+type Divergence struct {
+	Session string
+	Step    int // 0 is the state before any keys are sent
+	Keys    string
+	A, B    State
+	Fields  []string // which of lines/cursor/mode differ
+	Panic   string   // set if an engine panicked while handling Keys
+}
+
+func (d *Divergence) Error() string {
+	if d.Panic != "" {
+		return fmt.Sprintf("%s: step %d %q: %s", d.Session, d.Step, d.Keys, d.Panic)
+	}
+	return fmt.Sprintf("%s: step %d %q: %s differ\n  A: %v\n  B: %v",
+		d.Session, d.Step, d.Keys, strings.Join(d.Fields, ", "), d.A, d.B)
+}
+
+// Capture returns the current state of an engine
+func Capture(eng interfaces.VimEngine) State {
+	lines := append([]string(nil), eng.BufferGetCurrent().Lines()...)
+	return State{
+		Lines:  lines,
+		Cursor: eng.CursorGetPosition(),
+		Mode:   eng.GetMode(),
+	}
+}
+
+// Diff returns the names of the fields that differ between two states
+func Diff(a, b State) []string {
+	var fields []string
+	if !reflect.DeepEqual(a.Lines, b.Lines) {
+		fields = append(fields, "lines")
+	}
+	if a.Cursor != b.Cursor {
+		fields = append(fields, "cursor")
+	}
+	if a.Mode != b.Mode {
+		fields = append(fields, "mode")
+	}
+	return fields
+}
+
+// Tokenize splits a key sequence into the units the editor would send.
+// Angle-bracket names (<esc>, <cr>, <c-v>, <lt> ...) are single tokens;
+// everything else is one token per rune.
+func Tokenize(keys string) []string {
+	var tokens []string
+	for len(keys) > 0 {
+		if keys[0] == '<' {
+			if end := strings.IndexByte(keys, '>'); end > 1 {
+				name := strings.ToLower(keys[:end+1])
+				if isKeyName(name) {
+					tokens = append(tokens, name)
+					keys = keys[end+1:]
+					continue
+				}
+			}
+		}
+		r := []rune(keys)[0]
+		tokens = append(tokens, string(r))
+		keys = keys[len(string(r)):]
+	}
+	return tokens
+}
+
+func isKeyName(name string) bool {
+	if _, ok := specialKeys[name]; ok {
+		return true
+	}
+	switch name {
+	case "<cr>", "<tab>", "<lt>":
+		return true
+	}
+	return len(name) == 5 && strings.HasPrefix(name, "<c-") && name[3] >= 'a' && name[3] <= 'z'
+}
+
+// Send delivers a key sequence to an engine one token at a time
+func Send(eng interfaces.VimEngine, keys string) {
+	for _, tok := range Tokenize(keys) {
+		if _, ok := specialKeys[tok]; ok {
+			eng.Key(tok)
+			continue
+		}
+		switch {
+		case tok == "<cr>":
+			eng.Input("\r")
+		case tok == "<tab>":
+			eng.Input("\t")
+		case tok == "<lt>":
+			eng.Input("<")
+		case strings.HasPrefix(tok, "<c-"):
+			eng.Input(string(rune(tok[3] - 'a' + 1)))
+		default:
+			eng.Input(tok)
+		}
+	}
+}
+
+// Load gives the engine a fresh buffer holding lines with the cursor at 1,0 in NORMAL mode
+func Load(eng interfaces.VimEngine, lines []string) {
+	buf := eng.BufferNew(0)
+	eng.BufferSetCurrent(buf)
+	buf.SetLines(0, -1, lines)
+	eng.Key("<esc>")
+	eng.CursorSetPosition(1, 0)
+}
+
+// Run replays a session through both engines and returns the first
+// divergence, or nil if the engines agree after every step
+func Run(a, b interfaces.VimEngine, s Session) *Divergence {
+	Load(a, s.Lines)
+	Load(b, s.Lines)
+	if d := check(a, b, s.Name, 0, ""); d != nil {
+		return d
+	}
+	for i, keys := range s.Steps {
+		for _, eng := range []struct {
+			name string
+			eng  interfaces.VimEngine
+		}{{"A", a}, {"B", b}} {
+			if msg := sendRecover(eng.eng, keys); msg != "" {
+				return &Divergence{Session: s.Name, Step: i + 1, Keys: keys, Panic: eng.name + " panicked: " + msg}
+			}
+		}
+		if d := check(a, b, s.Name, i+1, keys); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// sendRecover turns an engine panic into a reportable divergence
+// instead of aborting the whole test binary
+func sendRecover(eng interfaces.VimEngine, keys string) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	Send(eng, keys)
+	return ""
+}
+
+func check(a, b interfaces.VimEngine, name string, step int, keys string) *Divergence {
+	sa, sb := Capture(a), Capture(b)
+	fields := Diff(sa, sb)
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Divergence{Session: name, Step: step, Keys: keys, A: sa, B: sb, Fields: fields}
+}
+
+// ParseSession reads a session in the corpus format:
+//
+//	# comment
+//	> first buffer line
+//	> second buffer line
+//	keys dw
+//	keys ihello<esc>
+//
+// A bare ">" is an empty buffer line. Each "keys" line is one step.
+func ParseSession(name string, r io.Reader) (Session, error) {
+	s := Session{Name: name}
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == ">":
+			s.Lines = append(s.Lines, "")
+		case strings.HasPrefix(line, "> "):
+			s.Lines = append(s.Lines, line[2:])
+		case strings.HasPrefix(line, "keys "):
+			s.Steps = append(s.Steps, line[5:])
+		default:
+			return s, fmt.Errorf("%s:%d: unrecognized line %q", name, n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return s, err
+	}
+	if len(s.Lines) == 0 {
+		s.Lines = []string{""}
+	}
+	return s, nil
+}
+
+// LoadCorpus parses every *.session file in dir
+func LoadCorpus(dir string) ([]Session, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.session"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var sessions []Session
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := ParseSession(filepath.Base(path), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Format writes a session in the corpus format so a divergence found by
+// the random generator can be saved as a new corpus file
+func Format(s Session) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", s.Name)
+	for _, line := range s.Lines {
+		if line == "" {
+			sb.WriteString(">\n")
+		} else {
+			fmt.Fprintf(&sb, "> %s\n", line)
+		}
+	}
+	for _, keys := range s.Steps {
+		fmt.Fprintf(&sb, "keys %s\n", keys)
+	}
+	return sb.String()
+}
+
+// normalSteps are the building blocks for random sessions. Every entry
+// leaves the engine in NORMAL mode so steps can be combined freely.
+var normalSteps = []string{
+	"h", "j", "k", "l", "w", "b", "e", "0", "$", "^", "gg", "G", "%",
+	"2j", "3w", "2b", "2l",
+	"x", "3x", "dd", "2dd", "dw", "2dw", "d$", "D", "db", "de",
+	"cwfoo<esc>", "ccbar<esc>", "c$baz<esc>", "Cqux<esc>", "sz<esc>",
+	"yy", "yw", "y$", "p", "P",
+	"J", "~", "rx", "u", "<c-r>", ".",
+	"ihello<esc>", "aworld<esc>", "I# <esc>", "A;<esc>",
+	"onew line<esc>", "Oabove<esc>",
+	"vlld", "vey", "Vd", "Vjd", "vjy",
+	"<c-v>jld", "<c-v>jy", "<c-v>jIx<esc>", "<c-v>j$A;<esc>", "<c-v>jr-",
+	">>", "<lt><lt>",
+}
+
+// RandomSteps returns n steps drawn from the normal-mode vocabulary
+func RandomSteps(r *rand.Rand, n int) []string {
+	steps := make([]string, n)
+	for i := range steps {
+		steps[i] = normalSteps[r.Intn(len(normalSteps))]
+	}
+	return steps
+}
+
+// StepsFromBytes maps fuzzer input onto the normal-mode vocabulary, one step per byte
+func StepsFromBytes(data []byte) []string {
+	steps := make([]string, len(data))
+	for i, b := range data {
+		steps[i] = normalSteps[int(b)%len(normalSteps)]
+	}
+	return steps
+}
+
+// SampleText is the buffer used for random sessions
+var SampleText = []string{
+	"package main",
+	"",
+	"func main() {",
+	"    fmt.Println(\"hello, world\")",
+	"    x := (a + b) * c",
+	"}",
+	"the quick brown fox jumps over the lazy dog",
+}
@@ -0,0 +1,81 @@
+package compare
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/slzatz/vimango/vim"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		keys string
+		want []string
+	}{
+		{"dw", []string{"d", "w"}},
+		{"ihi<esc>", []string{"i", "h", "i", "<esc>"}},
+		{"<C-V>j<lt>", []string{"<c-v>", "j", "<lt>"}},
+		{"a<b>", []string{"a", "<", "b", ">"}},
+		{"x<", []string{"x", "<"}},
+		{"é<cr>", []string{"é", "<cr>"}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.keys); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestParseAndFormat(t *testing.T) {
+	src := "# sample\n> one\n>\n> three\nkeys dw\nkeys ihi<esc>\n"
+	s, err := ParseSession("sample", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "", "three"}; !reflect.DeepEqual(s.Lines, want) {
+		t.Errorf("lines = %q, want %q", s.Lines, want)
+	}
+	if want := []string{"dw", "ihi<esc>"}; !reflect.DeepEqual(s.Steps, want) {
+		t.Errorf("steps = %q, want %q", s.Steps, want)
+	}
+	if got := Format(s); got != src {
+		t.Errorf("Format round trip:\ngot  %q\nwant %q", got, src)
+	}
+
+	if _, err := ParseSession("bad", strings.NewReader("dw\n")); err == nil {
+		t.Error("expected an error for a line without a prefix")
+	}
+}
+
+func TestCorpusParses(t *testing.T) {
+	sessions, err := LoadCorpus("testdata/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) == 0 {
+		t.Fatal("no sessions found in testdata/sessions")
+	}
+}
+
+// TestRunDetectsDivergence checks the harness itself using two Go engines,
+// so it runs in builds without libvim
+func TestRunDetectsDivergence(t *testing.T) {
+	a := (&vim.GoImplementation{}).GetEngineWrapper()
+	b := (&vim.GoImplementation{}).GetEngineWrapper()
+	s := Session{Name: "same", Lines: SampleText, Steps: []string{"w", "dw", "ihello<esc>"}}
+	if d := Run(a, b, s); d != nil {
+		t.Fatalf("identical engines diverged: %v", d)
+	}
+
+	Load(a, SampleText)
+	Load(b, SampleText)
+	Send(a, "x")
+	d := check(a, b, "different", 1, "x")
+	if d == nil {
+		t.Fatal("expected a divergence after editing only one engine")
+	}
+	if !reflect.DeepEqual(d.Fields, []string{"lines"}) {
+		t.Errorf("fields = %v, want [lines]", d.Fields)
+	}
+}
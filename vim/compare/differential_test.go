@@ -0,0 +1,94 @@
+//go:build cgo && !windows
+
+package compare
+
+import (
+	"flag"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slzatz/vimango/vim"
+	"github.com/slzatz/vimango/vim/interfaces"
+)
+
+var (
+	randomSessions = flag.Int("compare.random", 0, "number of random normal-mode sessions to replay")
+	randomSteps    = flag.Int("compare.steps", 20, "steps per random session")
+	randomSeed     = flag.Int64("compare.seed", 0, "seed for random sessions (0 uses the current time)")
+)
+
+var initOnce sync.Once
+
+// engines returns the libvim engine and a fresh Go engine. libvim is a
+// process-wide singleton so it is only initialized once.
+func engines() (interfaces.VimEngine, interfaces.VimEngine) {
+	c := (&vim.CGOImplementation{}).GetEngineWrapper()
+	initOnce.Do(func() {
+		c.Init(0)
+		c.Execute("set iskeyword+=*")
+		c.Execute("set iskeyword+=`")
+	})
+	g := (&vim.GoImplementation{}).GetEngineWrapper()
+	g.Init(0)
+	return c, g
+}
+
+func TestCorpus(t *testing.T) {
+	sessions, err := LoadCorpus("testdata/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) == 0 {
+		t.Fatal("no sessions found in testdata/sessions")
+	}
+	for _, s := range sessions {
+		t.Run(s.Name, func(t *testing.T) {
+			c, g := engines()
+			if d := Run(c, g, s); d != nil {
+				t.Errorf("cvim (A) and govim (B) diverge\n%v", d)
+			}
+		})
+	}
+}
+
+// TestRandom replays generated sessions when -compare.random is set, e.g.
+//
+//	go test ./vim/compare -run TestRandom -compare.random 500
+//
+// A divergence is reported along with the session in corpus format so it
+// can be saved to testdata/sessions.
+func TestRandom(t *testing.T) {
+	if *randomSessions == 0 {
+		t.Skip("set -compare.random to replay random sessions")
+	}
+	seed := *randomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", seed)
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < *randomSessions; i++ {
+		s := Session{Name: "random", Lines: SampleText, Steps: RandomSteps(r, *randomSteps)}
+		c, g := engines()
+		if d := Run(c, g, s); d != nil {
+			s.Steps = s.Steps[:d.Step]
+			t.Fatalf("cvim (A) and govim (B) diverge\n%v\n\n%s", d, Format(s))
+		}
+	}
+}
+
+func FuzzNormalMode(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3})
+	f.Add([]byte{20, 4, 36, 40})
+	f.Add([]byte{45, 4, 4, 39, 40})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := Session{Name: "fuzz", Lines: SampleText, Steps: StepsFromBytes(data)}
+		c, g := engines()
+		if d := Run(c, g, s); d != nil {
+			s.Steps = s.Steps[:d.Step]
+			t.Fatalf("cvim (A) and govim (B) diverge\n%v\n\n%s", d, Format(s))
+		}
+	})
+}
@@ -3,6 +3,7 @@ package govim
 import (
 	"io/ioutil"
 	"strings"
+	"time"
 )
 
 // UndoRecord represents a single undoable change
@@ -12,6 +13,7 @@ type UndoRecord struct {
 	Description   string         // Optional description of the change
 	CommandType   string         // The type of command that created this undo record (e.g., "o", "O", "general")
 	LineOperation bool           // Whether this operation added or removed entire lines
+	Time          time.Time      // When the change was made (used by :earlier and :later)
 }
 
 // GoBuffer is the Go implementation of the VimBuffer interface in vim/interfaces.go
@@ -1,6 +1,11 @@
 package govim
 
-import "github.com/slzatz/vimango/vim/cvim"
+import (
+	"strconv"
+	"time"
+
+	"github.com/slzatz/vimango/vim/cvim"
+)
 
 // ModeNormal is the normal mode constant
 const ModeNormal = 1
@@ -17,6 +22,9 @@ const ModeInsert = 16
 // ModeSearch is the search mode constant
 const ModeSearch = 32
 
+// MaxCol is vim's MAXCOL, the curswant value after $
+const MaxCol = 2147483647
+
 // GoEngine implements the vim engine in pure Go
 type GoEngine struct {
 	buffers       map[int]*GoBuffer
@@ -24,17 +32,19 @@ type GoEngine struct {
 	currentBuffer *GoBuffer
 	//cursorRow      int
 	//cursorCol      int
-	mode             int
-	visualStart      [2]int
-	visualEnd        [2]int
-	visualType       int
-	commandCount     int    // For motion counts like 5j, 3w, etc.
-	awaitingMotion   bool   // True when waiting for a motion after d, c, y, etc.
-	currentCommand   string // Current command (d, c, y) waiting for motion
-	buildingCount    bool   // True when we're in the process of entering a numeric prefix
-	yankRegister     string // Content of the "unnamed" register for yank/put
-	yankRegisterType int    // Type of yanked content: 0=char, 1=line, 2=block
-	awaitingReplace  bool   // True when we're waiting for a character to replace (after 'r')
+	mode              int
+	visualStart       [2]int
+	visualEnd         [2]int
+	visualType        int
+	visualBlockDollar bool        // $ was used in blockwise visual mode: the block extends to the end of every line
+	blockInsert       blockInsert // pending I, A or c from blockwise visual mode
+	commandCount      int         // For motion counts like 5j, 3w, etc.
+	awaitingMotion    bool        // True when waiting for a motion after d, c, y, etc.
+	currentCommand    string      // Current command (d, c, y) waiting for motion
+	buildingCount     bool        // True when we're in the process of entering a numeric prefix
+	yankRegister      string      // Content of the "unnamed" register for yank/put
+	yankRegisterType  int         // Type of yanked content: 0=char, 1=line, 2=block
+	awaitingReplace   bool        // True when we're waiting for a character to replace (after 'r')
 
 	// Undo state
 	inInsertUndoGroup bool // True when in insert mode to group all changes as one undo operation
@@ -377,6 +387,9 @@ func (e *GoEngine) Execute(cmd string) {
 			// Update the lastSavedState to the current position in the undo stack
 			e.currentBuffer.lastSavedState = len(e.currentBuffer.undoStack)
 		}
+	default:
+		// :earlier, :later and :undo N
+		e.executeUndoCommand(cmd)
 	}
 }
 
@@ -402,6 +415,22 @@ func (e *GoEngine) Eval(expr string) string {
 		}
 	}
 
+	// The last search pattern, used by the editor for hlsearch
+	if expr == "@/" {
+		return e.searchPattern
+	}
+
+	// The editor uses curswant to tell whether a block was extended with $
+	if expr == "winsaveview().curswant" {
+		if e.visualBlockDollar {
+			return strconv.Itoa(MaxCol)
+		}
+		if e.currentBuffer != nil {
+			return strconv.Itoa(e.currentBuffer.cursorCol)
+		}
+		return "0"
+	}
+
 	// Default empty result for unsupported expressions
 	return ""
 }
@@ -503,15 +532,6 @@ func (e *GoEngine) GetSearchResults() [][2]int {
 	return e.searchResults
 }
 
-// startSearch begins a search operation
-// direction: 1 for forward search (/), -1 for backward search (?)
-func (e *GoEngine) startSearch(direction int) {
-	e.mode = ModeSearch
-	e.searching = true
-	e.searchDirection = direction
-	e.searchBuffer = ""
-}
-
 // UndoSaveCursor saves just the cursor position for undo
 // Returns true if successful, false otherwise
 func (e *GoEngine) UndoSaveCursor() bool {
@@ -535,6 +555,7 @@ func (e *GoEngine) UndoSaveCursor() bool {
 		Changes:     make(map[int]string),
 		CursorPos:   [2]int{e.currentBuffer.cursorRow, e.currentBuffer.cursorCol},
 		Description: "cursor movement",
+		Time:        time.Now(),
 	}
 
 	// Add to undo stack
@@ -560,6 +581,7 @@ func (e *GoEngine) UndoSaveRegion(startLine, endLine int) bool {
 		Changes:     make(map[int]string),
 		CursorPos:   [2]int{e.currentBuffer.cursorRow, e.currentBuffer.cursorCol},
 		Description: "text change",
+		Time:        time.Now(),
 	}
 
 	// Save the specified lines (1-based indexing)
@@ -569,6 +591,14 @@ func (e *GoEngine) UndoSaveRegion(startLine, endLine int) bool {
 		}
 	}
 
+	// A command and the buffer method it calls can both save the same
+	// region; a second identical record would make undo states (and
+	// :earlier N) count one change twice
+	if n := len(e.currentBuffer.undoStack); n > 0 && sameUndoRecord(e.currentBuffer.undoStack[n-1], record) {
+		e.currentBuffer.redoStack = nil
+		return true
+	}
+
 	// Add to undo stack
 	e.currentBuffer.undoStack = append(e.currentBuffer.undoStack, record)
 
@@ -608,6 +638,7 @@ func (e *GoEngine) Undo() bool {
 		Description:   "redo " + record.Description,
 		CommandType:   record.CommandType,
 		LineOperation: record.LineOperation,
+		Time:          record.Time,
 	}
 
 	// Special handling for 'o' and 'O' commands which add a line
@@ -753,6 +784,7 @@ func (e *GoEngine) Redo() bool {
 		Description:   "undo " + record.Description,
 		CommandType:   record.CommandType,
 		LineOperation: record.LineOperation,
+		Time:          record.Time,
 	}
 
 	// Special handling for 'o' and 'O' commands - they need special treatment during redo
@@ -920,6 +952,7 @@ func (e *GoEngine) startInsertUndoGroup(commandType string) {
 		Description:   "insert mode",
 		CommandType:   commandType,
 		LineOperation: commandType == "o" || commandType == "O", // These commands operate on whole lines
+		Time:          time.Now(),
 	}
 
 	// For 'o' and 'O' commands, we need to save the state BEFORE the line is added
@@ -29,6 +29,7 @@ func (e *GoEngine) enterVisualMode(visualType int) {
 	e.visualStart = [2]int{e.currentBuffer.cursorRow, e.currentBuffer.cursorCol} // Set start of selection to cursor position
 	e.visualEnd = [2]int{e.currentBuffer.cursorRow, e.currentBuffer.cursorCol}   // Set end of selection to cursor position
 	e.visualType = visualType                                                    // Visual type (0 = char, 1 = line, 2 = block)
+	e.visualBlockDollar = false
 }
 
 // exitVisualMode cleans up state when exiting visual mode
@@ -97,6 +98,12 @@ func (e *GoEngine) Input(s string) {
 		e.awaitingReplace = false
 
 		if prevMode == ModeInsert {
+			// Copy text typed in a block insert to the other lines of the block
+			// while the insert undo group is still open
+			if e.blockInsert.active {
+				e.finishBlockInsert()
+			}
+
 			// Reset the insert undo group flag
 			e.inInsertUndoGroup = false
 
@@ -260,8 +267,35 @@ func (e *GoEngine) Input(s string) {
 		return
 	}
 
+	// Keys typed after / or ? build the search pattern
+	if e.mode == ModeSearch {
+		e.searchInput(s)
+		return
+	}
+
 	// Handle visual mode commands
 	if e.mode == ModeVisual {
+		if s == "\x16" { // Ctrl-V toggles blockwise visual mode
+			if e.visualType == 2 {
+				e.exitVisualMode()
+			} else {
+				e.visualType = 2
+				e.visualBlockDollar = false
+			}
+			return
+		}
+
+		// Blockwise visual mode has its own operators
+		if e.visualType == 2 {
+			if e.awaitingReplace {
+				e.replaceVisualBlock(s)
+				return
+			}
+			if e.visualBlockOperation(s) {
+				return
+			}
+		}
+
 		// First check for operations on the visual selection
 		switch s {
 		case "y": // yank selection
@@ -288,6 +322,18 @@ func (e *GoEngine) Input(s string) {
 			return
 		}
 
+		// A count before a motion works as it does in normal mode
+		if isDigit(s) && (e.buildingCount || s != "0") {
+			digit := int(s[0] - '0')
+			if e.buildingCount {
+				e.commandCount = e.commandCount*10 + digit
+			} else {
+				e.commandCount = digit
+				e.buildingCount = true
+			}
+			return
+		}
+
 		// Motion commands in visual mode - use the normal mode handlers
 		if handler, exists := motionHandlers[s]; exists {
 			handler(e, e.commandCount) // Execute the motion command with count
@@ -297,6 +343,12 @@ func (e *GoEngine) Input(s string) {
 
 			// Ensure visualEnd is updated after any motion
 			e.updateVisualSelection()
+
+			// In a block, $ extends every line to its end until a
+			// horizontal motion is used
+			if e.visualType == 2 {
+				e.visualBlockDollar = s == "$" || (e.visualBlockDollar && (s == "j" || s == "k"))
+			}
 			return
 		}
 	}
@@ -620,6 +672,15 @@ func (e *GoEngine) Input(s string) {
 		case "V": // line-wise visual mode
 			e.enterVisualMode(1) // 1 for line-wise visual mode
 			return
+		case "\x16": // Ctrl-V block-wise visual mode
+			e.enterVisualMode(2) // 2 for block-wise visual mode
+			return
+		case "/": // forward search
+			e.startSearch(1)
+			return
+		case "?": // backward search
+			e.startSearch(-1)
+			return
 		case "a": // append (insert after cursor)
 			if e.currentBuffer != nil {
 				line := e.currentBuffer.GetLine(e.currentBuffer.cursorRow)
@@ -853,6 +914,11 @@ func (e *GoEngine) Input(s string) {
 		// Handle new line commands
 // Handle paste with p
 		if s == "p" && e.currentBuffer != nil && e.yankRegister != "" {
+			if e.yankRegisterType == 2 {
+				e.putBlock(true)
+				return
+			}
+
 			// Paste after cursor position
 			line := e.currentBuffer.GetLine(e.currentBuffer.cursorRow)
 
@@ -2813,6 +2879,13 @@ func (e *GoEngine) Key(s string) {
 		return
 	}
 
+	if e.mode == ModeSearch {
+		if s == "<bs>" || s == "<backspace>" {
+			e.searchInput("\x7f")
+		}
+		return
+	}
+
 	// Handle special key commands in normal mode first
 	if e.mode == ModeNormal {
 		// Special handling for 'g' commands
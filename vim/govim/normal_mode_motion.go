@@ -21,6 +21,10 @@ var motionHandlers = map[string]motionCommand{
 	"u":     performUndo,
 	"<C-r>": performRedo,
 	".":     repeatLastEdit,
+	"n":     searchForward,
+	"N":     searchBackward,
+	"*":     searchWordForward,
+	"#":     searchWordBackward,
 }
 
 // moveLeft moves the cursor one or more characters to the left
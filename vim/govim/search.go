@@ -0,0 +1,331 @@
+package govim
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchMatch is one match of a search pattern; columns are byte offsets
+// and End is exclusive
+type SearchMatch struct {
+	Row   int // 1-based
+	Start int
+	End   int
+}
+
+// compileSearchPattern translates a vim search pattern into a Go regexp.
+// It covers the parts of vim's "magic" syntax that show up in notes:
+// \< \>, \( \) \|, \+ \? \= \{n,m}, character classes, \c and \C, plus
+// \v (very magic) and \V (very nomagic). A pattern that can't be
+// translated is searched for literally.
+func compileSearchPattern(pat string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	ignoreCase := false
+	veryMagic, noMagic := false, false
+
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		if c != '\\' || i == len(pat)-1 {
+			switch {
+			case noMagic:
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			case veryMagic:
+				switch c {
+				case '{':
+					if q, n, ok := braceQuantifier(pat[i+1:]); ok {
+						sb.WriteString(q)
+						i += n
+					} else {
+						sb.WriteString(`\{`)
+					}
+				case '<', '>':
+					sb.WriteString(`\b`)
+				case '=':
+					sb.WriteByte('?')
+				default:
+					sb.WriteByte(c)
+				}
+			default:
+				switch c {
+				case '(', ')', '|', '+', '?', '{', '}':
+					sb.WriteByte('\\')
+					sb.WriteByte(c)
+				default:
+					sb.WriteByte(c)
+				}
+			}
+			continue
+		}
+
+		i++
+		c = pat[i]
+		switch c {
+		case 'c':
+			ignoreCase = true
+		case 'C':
+			ignoreCase = false
+		case 'v':
+			veryMagic, noMagic = true, false
+		case 'V':
+			veryMagic, noMagic = false, true
+		case 'm', 'M':
+			veryMagic, noMagic = false, false
+		case '<', '>':
+			if veryMagic {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			} else {
+				sb.WriteString(`\b`)
+			}
+		case '{':
+			if veryMagic {
+				sb.WriteString(`\{`)
+			} else if q, n, ok := braceQuantifier(pat[i+1:]); ok {
+				sb.WriteString(q)
+				i += n
+			} else {
+				sb.WriteString(`\{`)
+			}
+		case '(', ')', '|', '+', '?', '}':
+			if veryMagic {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			} else {
+				sb.WriteByte(c)
+			}
+		case '=':
+			sb.WriteByte('?')
+		case 's', 'S', 'd', 'D', 'w', 'W':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case 'a':
+			sb.WriteString(`[A-Za-z]`)
+		case 'l':
+			sb.WriteString(`[a-z]`)
+		case 'u':
+			sb.WriteString(`[A-Z]`)
+		case 'x':
+			sb.WriteString(`[0-9A-Fa-f]`)
+		case 't':
+			sb.WriteString(`\t`)
+		case '.', '*', '[', ']', '^', '$', '~', '/', '\\':
+			if noMagic && (c == '.' || c == '*' || c == '[' || c == '^' || c == '$') {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	expr := sb.String()
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return regexp.Compile(regexp.QuoteMeta(pat))
+	}
+	return re, nil
+}
+
+// braceQuantifier translates the rest of a \{n,m} multi - rest starts just
+// after the opening brace - into a Go quantifier, returning it and how many
+// bytes of rest it used. \{-...} is the non-greedy form and the closing
+// brace may be written \}.
+func braceQuantifier(rest string) (string, int, bool) {
+	end := strings.IndexByte(rest, '}')
+	if end < 0 {
+		return "", 0, false
+	}
+	body := strings.TrimSuffix(rest[:end], `\`)
+	lazy := strings.HasPrefix(body, "-")
+	body = strings.TrimPrefix(body, "-")
+	lo, hi, comma := strings.Cut(body, ",")
+	if !allDigits(lo) || !allDigits(hi) {
+		return "", 0, false
+	}
+	var q string
+	switch {
+	case lo == "" && hi == "":
+		q = "*"
+	case !comma:
+		q = "{" + lo + "}"
+	case lo == "":
+		q = "{0," + hi + "}"
+	default:
+		q = "{" + lo + "," + hi + "}"
+	}
+	if lazy {
+		q += "?"
+	}
+	return q, end + 1, true
+}
+
+// allDigits reports whether s is made of ASCII digits (an empty s is)
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchMatches returns every match of pattern in the current buffer
+func (e *GoEngine) SearchMatches(pattern string) []SearchMatch {
+	if e.currentBuffer == nil || pattern == "" {
+		return nil
+	}
+	re, err := compileSearchPattern(pattern)
+	if err != nil {
+		return nil
+	}
+	var matches []SearchMatch
+	for i, line := range e.currentBuffer.lines {
+		for _, m := range re.FindAllStringIndex(line, -1) {
+			matches = append(matches, SearchMatch{Row: i + 1, Start: m[0], End: m[1]})
+		}
+	}
+	return matches
+}
+
+// startSearch begins a search operation
+// direction: 1 for forward search (/), -1 for backward search (?)
+func (e *GoEngine) startSearch(direction int) {
+	e.mode = ModeSearch
+	e.searching = true
+	e.searchDirection = direction
+	e.searchBuffer = ""
+}
+
+// searchInput handles a key typed on the / or ? command line
+func (e *GoEngine) searchInput(s string) {
+	switch s {
+	case "\r":
+		pattern := e.searchBuffer
+		e.searching = false
+		e.searchBuffer = ""
+		e.mode = ModeNormal
+		// an empty pattern repeats the last search
+		if pattern != "" {
+			e.searchPattern = pattern
+		}
+		e.searchNext(1, e.searchDirection)
+	case "\x7f", "\x08":
+		if e.searchBuffer == "" {
+			// backspacing over the / leaves search like vim
+			e.searching = false
+			e.mode = ModeNormal
+			return
+		}
+		_, size := utf8.DecodeLastRuneInString(e.searchBuffer)
+		e.searchBuffer = e.searchBuffer[:len(e.searchBuffer)-size]
+	default:
+		e.searchBuffer += s
+	}
+}
+
+// searchNext moves count matches from the cursor in direction (1 forward,
+// -1 backward), wrapping around the buffer
+func (e *GoEngine) searchNext(count, direction int) bool {
+	if e.currentBuffer == nil || e.searchPattern == "" {
+		return false
+	}
+	matches := e.SearchMatches(e.searchPattern)
+	e.searchResults = make([][2]int, len(matches))
+	for i, m := range matches {
+		e.searchResults[i] = [2]int{m.Row, m.Start}
+	}
+	if len(matches) == 0 {
+		e.currentSearchIdx = -1
+		return false
+	}
+	if count <= 0 {
+		count = 1
+	}
+
+	row, col := e.currentBuffer.cursorRow, e.currentBuffer.cursorCol
+	idx := -1
+	for n := 0; n < count; n++ {
+		idx = -1
+		if direction > 0 {
+			for i, m := range e.searchResults {
+				if m[0] > row || (m[0] == row && m[1] > col) {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				idx = 0
+			}
+		} else {
+			for i := len(e.searchResults) - 1; i >= 0; i-- {
+				m := e.searchResults[i]
+				if m[0] < row || (m[0] == row && m[1] < col) {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				idx = len(e.searchResults) - 1
+			}
+		}
+		row, col = e.searchResults[idx][0], e.searchResults[idx][1]
+	}
+
+	e.UndoSaveCursor()
+	e.currentSearchIdx = idx
+	e.currentBuffer.cursorRow = row
+	e.currentBuffer.cursorCol = col
+	if e.mode == ModeVisual {
+		e.visualEnd = [2]int{row, col}
+	}
+	return true
+}
+
+// searchWordUnderCursor implements * and #
+func (e *GoEngine) searchWordUnderCursor(count, direction int) bool {
+	if e.currentBuffer == nil {
+		return false
+	}
+	line := e.currentBuffer.GetLine(e.currentBuffer.cursorRow)
+	col := e.currentBuffer.cursorCol
+	isWord := func(b byte) bool {
+		return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+	}
+	// like vim, use the first keyword at or after the cursor
+	for col < len(line) && !isWord(line[col]) {
+		col++
+	}
+	if col >= len(line) {
+		return false
+	}
+	start, end := col, col
+	for start > 0 && isWord(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWord(line[end]) {
+		end++
+	}
+	e.searchPattern = `\<` + line[start:end] + `\>`
+	e.searchDirection = direction
+	e.currentBuffer.cursorCol = start
+	return e.searchNext(count, direction)
+}
+
+func searchForward(e *GoEngine, count int) bool {
+	return e.searchNext(count, e.searchDirection)
+}
+
+func searchBackward(e *GoEngine, count int) bool {
+	return e.searchNext(count, -e.searchDirection)
+}
+
+func searchWordForward(e *GoEngine, count int) bool {
+	return e.searchWordUnderCursor(count, 1)
+}
+
+func searchWordBackward(e *GoEngine, count int) bool {
+	return e.searchWordUnderCursor(count, -1)
+}
@@ -1,6 +1,7 @@
 package govim
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -255,4 +256,47 @@ func TestBackwardSearch(t *testing.T) {
 				engine.currentBuffer.cursorRow, engine.currentBuffer.cursorCol)
 		}
 	})
-}
\ No newline at end of file
+}
+func TestSearchMatches(t *testing.T) {
+	engine, _ := newUndoTestEngine("the cat scattered", "Cat and dog", "dogs")
+
+	tests := []struct {
+		pattern string
+		want    []SearchMatch
+	}{
+		{"cat", []SearchMatch{{1, 4, 7}, {1, 9, 12}}},
+		{`\<cat\>`, []SearchMatch{{1, 4, 7}}},
+		{`\ccat`, []SearchMatch{{1, 4, 7}, {1, 9, 12}, {2, 0, 3}}},
+		{`dog\(s\)\=`, []SearchMatch{{2, 8, 11}, {3, 0, 4}}},
+		{`\v<dogs?>`, []SearchMatch{{2, 8, 11}, {3, 0, 4}}},
+		{`\V(`, nil},
+		{`t\{2}`, []SearchMatch{{1, 11, 13}}},
+		{`t\{1,3}`, []SearchMatch{{1, 0, 1}, {1, 6, 7}, {1, 11, 13}, {2, 2, 3}}},
+		{`t\{1,3\}`, []SearchMatch{{1, 0, 1}, {1, 6, 7}, {1, 11, 13}, {2, 2, 3}}},
+		{`s.\{-}t`, []SearchMatch{{1, 8, 12}}},
+		{`s.\{-1,}e`, []SearchMatch{{1, 8, 14}}},
+		{`\vt{2}`, []SearchMatch{{1, 11, 13}}},
+		{`d\{2,}`, nil},
+	}
+	for _, tt := range tests {
+		got := engine.SearchMatches(tt.pattern)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchMatches(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestSearchWordUnderCursor(t *testing.T) {
+	engine, buf := newUndoTestEngine("cat scattered", "a cat")
+	typeKeys(engine, "*")
+	if buf.cursorRow != 2 || buf.cursorCol != 2 {
+		t.Errorf("after * cursor at [%d,%d], want [2,2]", buf.cursorRow, buf.cursorCol)
+	}
+	if got := engine.Eval("@/"); got != `\<cat\>` {
+		t.Errorf("@/ = %q after *", got)
+	}
+	typeKeys(engine, "#")
+	if buf.cursorRow != 1 || buf.cursorCol != 0 {
+		t.Errorf("after # cursor at [%d,%d], want [1,0]", buf.cursorRow, buf.cursorCol)
+	}
+}
@@ -0,0 +1,212 @@
+package govim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// undoHistory is the serialized form of a buffer's undo and redo stacks
+type undoHistory struct {
+	Hash string        // sha256 of the buffer text the history applies to
+	Undo []*UndoRecord // oldest first
+	Redo []*UndoRecord // next redo last
+}
+
+// UndoState describes one position in a buffer's (linear) undo history
+type UndoState struct {
+	Seq     int // number of changes applied; 0 is the original text
+	Time    time.Time
+	Current bool
+}
+
+func hashLines(lines []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarshalUndo serializes the undo and redo stacks together with a hash of
+// the current text so the history can be restored when the same text is
+// loaded again. It returns nil if there is nothing to undo or redo.
+func (b *GoBuffer) MarshalUndo() ([]byte, error) {
+	if len(b.undoStack) == 0 && len(b.redoStack) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(undoHistory{
+		Hash: hashLines(b.lines),
+		Undo: b.undoStack,
+		Redo: b.redoStack,
+	})
+}
+
+// UnmarshalUndo replaces the buffer's undo and redo stacks with a history
+// produced by MarshalUndo. The history is rejected if the buffer text no
+// longer matches the text it was saved with.
+func (b *GoBuffer) UnmarshalUndo(data []byte) error {
+	var h undoHistory
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+	if h.Hash != hashLines(b.lines) {
+		return fmt.Errorf("buffer text changed since undo history was saved")
+	}
+	b.undoStack = h.Undo
+	b.redoStack = h.Redo
+	if b.undoStack == nil {
+		b.undoStack = make([]*UndoRecord, 0)
+	}
+	// the restored text is the saved text
+	b.lastSavedState = len(b.undoStack)
+	b.modified = false
+	return nil
+}
+
+// UndoStates lists every position in the current buffer's undo history
+func (e *GoEngine) UndoStates() []UndoState {
+	if e.currentBuffer == nil {
+		return nil
+	}
+	undo := e.currentBuffer.undoStack
+	redo := e.currentBuffer.redoStack
+	states := []UndoState{{Seq: 0, Current: len(undo) == 0}}
+	for i, r := range undo {
+		seq := i + 1
+		states = append(states, UndoState{Seq: seq, Time: r.Time, Current: seq == len(undo)})
+	}
+	for i := len(redo) - 1; i >= 0; i-- {
+		seq := len(undo) + len(redo) - i
+		states = append(states, UndoState{Seq: seq, Time: redo[i].Time})
+	}
+	return states
+}
+
+// UndoJump undoes or redoes until the buffer is at state seq
+func (e *GoEngine) UndoJump(seq int) {
+	if e.currentBuffer == nil {
+		return
+	}
+	for len(e.currentBuffer.undoStack) > seq && e.Undo() {
+	}
+	for len(e.currentBuffer.undoStack) < seq && e.Redo() {
+	}
+}
+
+// Earlier undoes every change made within d of now
+func (e *GoEngine) Earlier(d time.Duration) {
+	if e.currentBuffer == nil {
+		return
+	}
+	cutoff := time.Now().Add(-d)
+	for {
+		undo := e.currentBuffer.undoStack
+		if len(undo) == 0 || !undo[len(undo)-1].Time.After(cutoff) || !e.Undo() {
+			return
+		}
+	}
+}
+
+// Later redoes every change made within d after the current state
+func (e *GoEngine) Later(d time.Duration) {
+	if e.currentBuffer == nil || len(e.currentBuffer.redoStack) == 0 {
+		return
+	}
+	var base time.Time
+	if undo := e.currentBuffer.undoStack; len(undo) > 0 {
+		base = undo[len(undo)-1].Time
+	} else {
+		base = e.currentBuffer.redoStack[len(e.currentBuffer.redoStack)-1].Time
+	}
+	target := base.Add(d)
+	for {
+		redo := e.currentBuffer.redoStack
+		if len(redo) == 0 || redo[len(redo)-1].Time.After(target) || !e.Redo() {
+			return
+		}
+	}
+}
+
+// executeUndoCommand handles :earlier {N}, :earlier {N}{s,m,h,d},
+// the matching :later forms and :undo {N}
+func (e *GoEngine) executeUndoCommand(cmd string) {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return
+	}
+	arg := "1"
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+
+	switch fields[0] {
+	case "earlier", "ea", "later", "lat":
+		count, d, err := parseUndoTime(arg)
+		if err != nil {
+			return
+		}
+		earlier := strings.HasPrefix(fields[0], "e")
+		switch {
+		case d > 0 && earlier:
+			e.Earlier(d)
+		case d > 0:
+			e.Later(d)
+		case earlier:
+			for i := 0; i < count && e.Undo(); i++ {
+			}
+		default:
+			for i := 0; i < count && e.Redo(); i++ {
+			}
+		}
+	case "undo", "u":
+		if len(fields) > 1 {
+			if seq, err := strconv.Atoi(arg); err == nil {
+				e.UndoJump(seq)
+			}
+		}
+	}
+}
+
+// parseUndoTime parses the argument of :earlier and :later, returning
+// either a step count or a duration
+func parseUndoTime(arg string) (int, time.Duration, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		return n, 0, nil
+	}
+	if len(arg) < 2 {
+		return 0, 0, fmt.Errorf("invalid argument %q", arg)
+	}
+	n, err := strconv.Atoi(arg[:len(arg)-1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid argument %q", arg)
+	}
+	var unit time.Duration
+	switch arg[len(arg)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	default:
+		return 0, 0, fmt.Errorf("unsupported unit in %q", arg)
+	}
+	return 0, time.Duration(n) * unit, nil
+}
+
+// sameUndoRecord reports whether two records would restore the same text and cursor
+func sameUndoRecord(a, b *UndoRecord) bool {
+	if a.CursorPos != b.CursorPos || a.CommandType != b.CommandType || len(a.Changes) != len(b.Changes) {
+		return false
+	}
+	for line, text := range a.Changes {
+		if t, ok := b.Changes[line]; !ok || t != text {
+			return false
+		}
+	}
+	return true
+}
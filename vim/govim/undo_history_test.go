@@ -0,0 +1,153 @@
+package govim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newUndoTestEngine(lines ...string) (*GoEngine, *GoBuffer) {
+	engine := NewEngine()
+	buf := engine.BufferNew(0)
+	engine.BufferSetCurrent(buf)
+	// set the text directly so loading it isn't itself an undoable change
+	buf.lines = lines
+	return engine, buf
+}
+
+func typeKeys(e *GoEngine, keys ...string) {
+	for _, k := range keys {
+		if k == "<esc>" {
+			e.Key(k)
+			continue
+		}
+		for _, r := range k {
+			e.Input(string(r))
+		}
+	}
+}
+
+func TestUndoHistoryRoundTrip(t *testing.T) {
+	engine, buf := newUndoTestEngine("one two three")
+	typeKeys(engine, "dw", "dw")
+	if got := buf.Lines()[0]; got != "three" {
+		t.Fatalf("after dw dw got %q", got)
+	}
+
+	data, err := buf.MarshalUndo()
+	if err != nil || data == nil {
+		t.Fatalf("MarshalUndo returned %v, %v", data, err)
+	}
+
+	// reload the same text into a fresh engine and restore the history
+	engine2, buf2 := newUndoTestEngine("three")
+	if err := buf2.UnmarshalUndo(data); err != nil {
+		t.Fatalf("UnmarshalUndo: %v", err)
+	}
+	if buf2.IsModified() {
+		t.Errorf("restored buffer should not be modified")
+	}
+	engine2.Undo()
+	engine2.Undo()
+	if got := buf2.Lines()[0]; got != "one two three" {
+		t.Errorf("after restoring and undoing twice got %q", got)
+	}
+}
+
+func TestUndoHistoryRejectsChangedText(t *testing.T) {
+	engine, buf := newUndoTestEngine("one two")
+	typeKeys(engine, "x")
+	data, err := buf.MarshalUndo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, buf2 := newUndoTestEngine("something else")
+	if err := buf2.UnmarshalUndo(data); err == nil {
+		t.Errorf("expected an error restoring history for different text")
+	}
+}
+
+func TestMarshalUndoEmpty(t *testing.T) {
+	_, buf := newUndoTestEngine("unchanged")
+	data, err := buf.MarshalUndo()
+	if err != nil || data != nil {
+		t.Errorf("MarshalUndo on a buffer without changes returned %q, %v", data, err)
+	}
+}
+
+func TestEarlierLater(t *testing.T) {
+	engine, buf := newUndoTestEngine("abcd")
+	typeKeys(engine, "x", "x", "x")
+	if got := buf.Lines()[0]; got != "d" {
+		t.Fatalf("after xxx got %q", got)
+	}
+
+	engine.Execute("earlier 2")
+	if got := buf.Lines()[0]; got != "bcd" {
+		t.Errorf(":earlier 2 got %q", got)
+	}
+	engine.Execute("later")
+	if got := buf.Lines()[0]; got != "cd" {
+		t.Errorf(":later got %q", got)
+	}
+
+	// every change was made within the last hour
+	engine.Execute("earlier 1h")
+	if got := buf.Lines()[0]; got != "abcd" {
+		t.Errorf(":earlier 1h got %q", got)
+	}
+	engine.Execute("later 1h")
+	if got := buf.Lines()[0]; got != "d" {
+		t.Errorf(":later 1h got %q", got)
+	}
+}
+
+func TestUndoStatesAndJump(t *testing.T) {
+	engine, buf := newUndoTestEngine("abc")
+	typeKeys(engine, "x", "x")
+	engine.Undo()
+
+	var seqs []int
+	current := -1
+	for _, s := range engine.UndoStates() {
+		seqs = append(seqs, s.Seq)
+		if s.Current {
+			current = s.Seq
+		}
+	}
+	if !reflect.DeepEqual(seqs, []int{0, 1, 2}) || current != 1 {
+		t.Errorf("UndoStates seqs %v current %d", seqs, current)
+	}
+
+	engine.UndoJump(0)
+	if got := buf.Lines()[0]; got != "abc" {
+		t.Errorf("UndoJump(0) got %q", got)
+	}
+	engine.Execute("undo 2")
+	if got := buf.Lines()[0]; got != "c" {
+		t.Errorf(":undo 2 got %q", got)
+	}
+}
+
+func TestParseUndoTime(t *testing.T) {
+	tests := []struct {
+		arg   string
+		count int
+		d     time.Duration
+		err   bool
+	}{
+		{"3", 3, 0, false},
+		{"10s", 0, 10 * time.Second, false},
+		{"5m", 0, 5 * time.Minute, false},
+		{"2h", 0, 2 * time.Hour, false},
+		{"1d", 0, 24 * time.Hour, false},
+		{"1f", 0, 0, true},
+		{"x", 0, 0, true},
+	}
+	for _, tt := range tests {
+		count, d, err := parseUndoTime(tt.arg)
+		if (err != nil) != tt.err || count != tt.count || d != tt.d {
+			t.Errorf("parseUndoTime(%q) = %d, %v, %v", tt.arg, count, d, err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package govim
+
+import (
+	"strings"
+)
+
+// Blockwise visual mode (Ctrl-V). Columns are byte offsets like the rest
+// of the engine, so a block over tabs or multibyte text covers the same
+// bytes on every line rather than the same screen columns.
+
+// blockInsert tracks an I, A or c started from a visual block; the text
+// typed on the first line is copied to the other lines when insert mode ends
+type blockInsert struct {
+	active   bool
+	startRow int
+	endRow   int
+	col      int  // column the text is inserted at
+	appendTo bool // A: pad short lines out to col
+	dollar   bool // $-extended block: append at the end of every line
+	origLen  int  // length of the first line when insert mode started
+}
+
+// visualBlockBounds returns the rows and the inclusive columns of the block
+func (e *GoEngine) visualBlockBounds() (startRow, endRow, left, right int) {
+	startRow, endRow = e.visualStart[0], e.visualEnd[0]
+	if startRow > endRow {
+		startRow, endRow = endRow, startRow
+	}
+	left, right = e.visualStart[1], e.visualEnd[1]
+	if left > right {
+		left, right = right, left
+	}
+	return
+}
+
+// blockSegment returns the byte range [start, end) of line inside the block
+func (e *GoEngine) blockSegment(line string, left, right int) (start, end int) {
+	start = min(left, len(line))
+	end = len(line)
+	if !e.visualBlockDollar {
+		end = min(right+1, len(line))
+	}
+	return start, end
+}
+
+// replaceBlockLines writes lines back starting at row as one undo step
+func (e *GoEngine) replaceBlockLines(row int, lines []string) {
+	e.UndoSaveRegion(row, row+len(lines)-1)
+	oldUndoGroupState := e.inInsertUndoGroup
+	e.inInsertUndoGroup = true
+	for i, line := range lines {
+		e.currentBuffer.SetLines(row-1+i, row+i, []string{line})
+	}
+	e.inInsertUndoGroup = oldUndoGroupState
+}
+
+// visualBlockOperation handles the operators that act on a block and
+// reports whether s was one of them
+func (e *GoEngine) visualBlockOperation(s string) bool {
+	if e.currentBuffer == nil {
+		return false
+	}
+	startRow, endRow, left, right := e.visualBlockBounds()
+
+	switch s {
+	case "y":
+		e.yankBlock(startRow, endRow, left, right)
+	case "d", "x":
+		e.yankBlock(startRow, endRow, left, right)
+		e.deleteBlock(startRow, endRow, left, right)
+	case "c":
+		e.yankBlock(startRow, endRow, left, right)
+		e.deleteBlock(startRow, endRow, left, right)
+		e.startBlockInsert(startRow, endRow, left, false)
+		return true
+	case "I":
+		e.currentBuffer.cursorRow = startRow
+		e.currentBuffer.cursorCol = min(left, len(e.currentBuffer.GetLine(startRow)))
+		e.startBlockInsert(startRow, endRow, left, false)
+		return true
+	case "A":
+		col := right + 1
+		if e.visualBlockDollar {
+			col = len(e.currentBuffer.GetLine(startRow))
+		}
+		e.startBlockInsert(startRow, endRow, col, true)
+		return true
+	case "r":
+		e.awaitingReplace = true
+		return true
+	case "~":
+		e.mapBlock(startRow, endRow, func(line string) string {
+			start, end := e.blockSegment(line, left, right)
+			return line[:start] + toggleCaseString(line[start:end]) + line[end:]
+		})
+	case ">":
+		e.mapBlock(startRow, endRow, func(line string) string {
+			if len(line) <= left {
+				return line
+			}
+			return line[:left] + e.indentLine(line[left:])
+		})
+	case "<":
+		e.mapBlock(startRow, endRow, func(line string) string {
+			if len(line) <= left {
+				return line
+			}
+			return line[:left] + e.dedentLine(line[left:])
+		})
+	default:
+		return false
+	}
+
+	e.mode = ModeNormal
+	e.visualBlockDollar = false
+	e.currentBuffer.cursorRow = startRow
+	e.currentBuffer.cursorCol = left
+	e.validateCursorPosition()
+	return true
+}
+
+// mapBlock replaces each line of the block with fn(line)
+func (e *GoEngine) mapBlock(startRow, endRow int, fn func(string) string) {
+	lines := make([]string, 0, endRow-startRow+1)
+	for row := startRow; row <= endRow; row++ {
+		lines = append(lines, fn(e.currentBuffer.GetLine(row)))
+	}
+	e.replaceBlockLines(startRow, lines)
+}
+
+func (e *GoEngine) yankBlock(startRow, endRow, left, right int) {
+	parts := make([]string, 0, endRow-startRow+1)
+	for row := startRow; row <= endRow; row++ {
+		line := e.currentBuffer.GetLine(row)
+		start, end := e.blockSegment(line, left, right)
+		parts = append(parts, line[start:end])
+	}
+	e.yankRegister = strings.Join(parts, "\n")
+	e.yankRegisterType = 2
+}
+
+func (e *GoEngine) deleteBlock(startRow, endRow, left, right int) {
+	e.mapBlock(startRow, endRow, func(line string) string {
+		start, end := e.blockSegment(line, left, right)
+		return line[:start] + line[end:]
+	})
+	e.currentBuffer.cursorRow = startRow
+	e.currentBuffer.cursorCol = left
+}
+
+// replaceVisualBlock replaces every character in the block with r
+func (e *GoEngine) replaceVisualBlock(r string) {
+	e.awaitingReplace = false
+	startRow, endRow, left, right := e.visualBlockBounds()
+	e.mapBlock(startRow, endRow, func(line string) string {
+		start, end := e.blockSegment(line, left, right)
+		return line[:start] + strings.Repeat(r, end-start) + line[end:]
+	})
+	e.mode = ModeNormal
+	e.visualBlockDollar = false
+	e.currentBuffer.cursorRow = startRow
+	e.currentBuffer.cursorCol = left
+	e.validateCursorPosition()
+}
+
+// startBlockInsert enters insert mode on the first line of the block
+func (e *GoEngine) startBlockInsert(startRow, endRow, col int, appendTo bool) {
+	e.startInsertUndoGroup("")
+	line := e.currentBuffer.GetLine(startRow)
+	if appendTo && len(line) < col {
+		line += strings.Repeat(" ", col-len(line))
+		e.currentBuffer.SetLines(startRow-1, startRow, []string{line})
+	}
+	e.blockInsert = blockInsert{
+		active:   true,
+		startRow: startRow,
+		endRow:   endRow,
+		col:      min(col, len(line)),
+		appendTo: appendTo,
+		dollar:   e.visualBlockDollar,
+		origLen:  len(line),
+	}
+	e.currentBuffer.cursorRow = startRow
+	e.currentBuffer.cursorCol = e.blockInsert.col
+	e.visualBlockDollar = false
+	e.mode = ModeInsert
+}
+
+// finishBlockInsert copies the text typed on the first line of a block
+// insert to the remaining lines. It is called while the insert undo group
+// is still open so the whole block insert undoes in one step.
+func (e *GoEngine) finishBlockInsert() {
+	bi := e.blockInsert
+	e.blockInsert = blockInsert{}
+	if !bi.active || e.currentBuffer == nil || e.currentBuffer.cursorRow != bi.startRow {
+		return
+	}
+	line := e.currentBuffer.GetLine(bi.startRow)
+	added := len(line) - bi.origLen
+	if added <= 0 || bi.col+added > len(line) {
+		return
+	}
+	text := line[bi.col : bi.col+added]
+
+	for row := bi.startRow + 1; row <= bi.endRow; row++ {
+		l := e.currentBuffer.GetLine(row)
+		col := bi.col
+		switch {
+		case bi.dollar:
+			col = len(l)
+		case len(l) < col && bi.appendTo:
+			l += strings.Repeat(" ", col-len(l))
+		case len(l) < col:
+			// like vim, I and c skip lines that end before the block
+			continue
+		}
+		e.currentBuffer.SetLines(row-1, row, []string{l[:col] + text + l[col:]})
+	}
+}
+
+// putBlock pastes a blockwise register after (or before) the cursor,
+// one register line per buffer line
+func (e *GoEngine) putBlock(after bool) {
+	parts := strings.Split(e.yankRegister, "\n")
+	width := 0
+	for _, p := range parts {
+		width = max(width, len(p))
+	}
+
+	row := e.currentBuffer.cursorRow
+	col := e.currentBuffer.cursorCol
+	if after && len(e.currentBuffer.GetLine(row)) > 0 {
+		col++
+	}
+
+	// the block may extend past the end of the buffer
+	if missing := row + len(parts) - 1 - e.currentBuffer.GetLineCount(); missing > 0 {
+		count := e.currentBuffer.GetLineCount()
+		e.currentBuffer.SetLines(count, count, make([]string, missing))
+	}
+
+	lines := make([]string, len(parts))
+	for i, p := range parts {
+		l := e.currentBuffer.GetLine(row + i)
+		if len(l) < col {
+			l += strings.Repeat(" ", col-len(l))
+		}
+		// pad each piece to the block width unless nothing follows it
+		if col < len(l) {
+			p += strings.Repeat(" ", width-len(p))
+		}
+		lines[i] = l[:col] + p + l[col:]
+	}
+	e.replaceBlockLines(row, lines)
+	e.currentBuffer.cursorRow = row
+	e.currentBuffer.cursorCol = col
+}
+
+func toggleCaseString(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return string(b)
+}
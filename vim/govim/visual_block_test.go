@@ -0,0 +1,93 @@
+package govim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVisualBlock(t *testing.T) {
+	table := []string{
+		"| a | b |",
+		"| c | d |",
+		"| e | f |",
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		keys  []string
+		want  []string
+	}{
+		{"delete", table, []string{"l", "\x16", "jjl", "d"}, []string{"| | b |", "| | d |", "| | f |"}},
+		{"change", table, []string{"ll", "\x16", "jj", "cX", "<esc>"}, []string{"| X | b |", "| X | d |", "| X | f |"}},
+		{"insert", table, []string{"\x16", "jj", "I> ", "<esc>"}, []string{"> | a | b |", "> | c | d |", "> | e | f |"}},
+		{"append", []string{"ab", "a", "abc"}, []string{"l", "\x16", "jj", "A|", "<esc>"}, []string{"ab|", "a |", "ab|c"}},
+		{"append dollar", []string{"ab", "a", "abc"}, []string{"\x16", "jj$", "A;", "<esc>"}, []string{"ab;", "a;", "abc;"}},
+		{"replace", table, []string{"ll", "\x16", "jj", "r", "x"}, []string{"| x | b |", "| x | d |", "| x | f |"}},
+		{"toggle case", []string{"abc", "def"}, []string{"l", "\x16", "jl", "~"}, []string{"aBC", "dEF"}},
+		{"indent", []string{"a b", "c d"}, []string{"ll", "\x16", "j", ">"}, []string{"a     b", "c     d"}},
+		{"dedent", []string{"a     b", "c     d"}, []string{"ll", "\x16", "j", "<"}, []string{"a b", "c d"}},
+		{"yank and put", []string{"ab", "cd", "ef"}, []string{"\x16", "j", "y", "jj", "p"}, []string{"ab", "cd", "eaf", " c"}},
+		{"put pads pieces", []string{"x", "abc", "12", "34"}, []string{"\x16", "j$", "y", "jj", "p"}, []string{"x", "abc", "1x  2", "3abc4"}},
+		{"delete dollar", []string{"a123", "b1", "c12345"}, []string{"l", "\x16", "jj$", "d"}, []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, buf := newUndoTestEngine(append([]string(nil), tt.lines...)...)
+			typeKeys(engine, tt.keys...)
+			if got := buf.Lines(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if engine.GetMode() != ModeNormal {
+				t.Errorf("mode = %d, want normal", engine.GetMode())
+			}
+		})
+	}
+}
+
+func TestVisualBlockUndo(t *testing.T) {
+	engine, buf := newUndoTestEngine("abc", "def", "ghi")
+	typeKeys(engine, "\x16", "jj", "I--", "<esc>")
+	if got := buf.Lines(); !reflect.DeepEqual(got, []string{"--abc", "--def", "--ghi"}) {
+		t.Fatalf("block insert got %q", got)
+	}
+	typeKeys(engine, "u")
+	if got := buf.Lines(); !reflect.DeepEqual(got, []string{"abc", "def", "ghi"}) {
+		t.Errorf("undo of block insert got %q", got)
+	}
+
+	typeKeys(engine, "\x16", "jl", "d")
+	typeKeys(engine, "u")
+	if got := buf.Lines(); !reflect.DeepEqual(got, []string{"abc", "def", "ghi"}) {
+		t.Errorf("undo of block delete got %q", got)
+	}
+}
+
+func TestVisualBlockType(t *testing.T) {
+	engine, _ := newUndoTestEngine("abc")
+	typeKeys(engine, "\x16")
+	if engine.VisualGetType() != 22 {
+		t.Errorf("VisualGetType = %d, want 22", engine.VisualGetType())
+	}
+	typeKeys(engine, "$")
+	if got := engine.Eval("winsaveview().curswant"); got != "2147483647" {
+		t.Errorf("curswant after $ = %s", got)
+	}
+	typeKeys(engine, "h")
+	if got := engine.Eval("winsaveview().curswant"); got == "2147483647" {
+		t.Errorf("curswant still MAXCOL after h")
+	}
+}
+
+func TestVisualCount(t *testing.T) {
+	engine, buf := newUndoTestEngine("a", "b", "c", "d", "e")
+	typeKeys(engine, "V", "2j")
+	if got := engine.VisualGetRange(); got[0][0] != 1 || got[1][0] != 3 {
+		t.Errorf("V2j selected rows %d to %d, want 1 to 3", got[0][0], got[1][0])
+	}
+	typeKeys(engine, "d")
+	if got := buf.Lines(); !reflect.DeepEqual(got, []string{"d", "e"}) {
+		t.Errorf("V2jd got %q", got)
+	}
+}
@@ -1,6 +1,10 @@
 package interfaces
 
-import "github.com/slzatz/vimango/vim/cvim"
+import (
+	"time"
+
+	"github.com/slzatz/vimango/vim/cvim"
+)
 
 // VimBuffer mirros the buf_T functionality from C
 type VimBuffer interface {
@@ -14,6 +18,25 @@ type VimBuffer interface {
 	IsModified() bool
 	GetLastChangedTick() int
 	SetLines(start, end int, lines []string)
+
+	// Persistent undo: the history format is implementation specific
+	UndoHistory() ([]byte, error)
+	RestoreUndoHistory(data []byte) error
+}
+
+// UndoState is one entry in a buffer's undo history
+type UndoState struct {
+	Seq     int // change number; 0 is the original text
+	Time    time.Time
+	Current bool // the buffer is at this state
+	Depth   int  // 0 for the main line of changes, >0 for alternate branches
+}
+
+// SearchMatch is one match of a search pattern; columns are byte offsets
+type SearchMatch struct {
+	Line  int // 1-based
+	Start int
+	End   int // exclusive
 }
 
 // VimEngine represents the interface for the vim engine
@@ -46,6 +69,11 @@ type VimEngine interface {
 	// Misc
 	Eval(expr string) string
 	SearchGetMatchingPair() [2]int
+	SearchMatches(pattern string) []SearchMatch // every match of pattern in the current buffer
+
+	// Undo history
+	UndoStates() []UndoState
+	UndoJump(seq int)
 }
 
 // VimImplementation allows switching between C and Go implementations
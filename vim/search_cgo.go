@@ -0,0 +1,58 @@
+//go:build cgo && !windows
+
+package vim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/slzatz/vimango/vim/cvim"
+	"github.com/slzatz/vimango/vim/interfaces"
+)
+
+// SearchMatches returns every match of pattern in the current buffer using
+// vim's own regexp engine via matchstrpos(), so highlighted matches are
+// exactly what / and n find
+func (e *CGOEngineWrapper) SearchMatches(pattern string) []interfaces.SearchMatch {
+	if pattern == "" {
+		return nil
+	}
+	// single-quoted vim strings only need ' doubled
+	pat := "'" + strings.ReplaceAll(pattern, "'", "''") + "'"
+	lineCount := cvim.BufferGetLineCount(cvim.BufferGetCurrent())
+
+	var matches []interfaces.SearchMatch
+	for lnum := 1; lnum <= lineCount; lnum++ {
+		start := 0
+		for {
+			result := cvim.Eval(fmt.Sprintf("join(matchstrpos(getline(%d), %s, %d)[1:2], ',')", lnum, pat, start))
+			s, end, ok := parseMatchPos(result)
+			if !ok {
+				break
+			}
+			matches = append(matches, interfaces.SearchMatch{Line: lnum, Start: s, End: end})
+			// step past empty matches so ^ or \< don't loop forever
+			if end > s {
+				start = end
+			} else {
+				start = end + 1
+			}
+		}
+	}
+	return matches
+}
+
+// parseMatchPos parses "start,end" as produced by matchstrpos; -1 means no match
+func parseMatchPos(s string) (start, end int, ok bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start < 0 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,113 @@
+//go:build cgo && !windows
+
+package vim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/slzatz/vimango/vim/cvim"
+	"github.com/slzatz/vimango/vim/interfaces"
+)
+
+// libvim has persistent undo built in (:wundo/:rundo) but only reads and
+// writes undo files, so the history is passed through a temp file
+
+// undoTreeEntry mirrors an entry in the dictionary returned by undotree()
+type undoTreeEntry struct {
+	Seq  int             `json:"seq"`
+	Time int64           `json:"time"`
+	Alt  []undoTreeEntry `json:"alt"`
+}
+
+type undoTree struct {
+	SeqCur  int             `json:"seq_cur"`
+	Entries []undoTreeEntry `json:"entries"`
+}
+
+// UndoStates returns the undo tree of the current buffer flattened into a
+// list; entries on alternate branches have Depth > 0
+func (e *CGOEngineWrapper) UndoStates() []interfaces.UndoState {
+	var tree undoTree
+	if err := json.Unmarshal([]byte(cvim.Eval("json_encode(undotree())")), &tree); err != nil {
+		return nil
+	}
+	states := []interfaces.UndoState{{Seq: 0, Current: tree.SeqCur == 0}}
+	var walk func(entries []undoTreeEntry, depth int)
+	walk = func(entries []undoTreeEntry, depth int) {
+		for _, en := range entries {
+			// alternate branches split off before the entry they are attached to
+			walk(en.Alt, depth+1)
+			states = append(states, interfaces.UndoState{
+				Seq:     en.Seq,
+				Time:    time.Unix(en.Time, 0),
+				Current: en.Seq == tree.SeqCur,
+				Depth:   depth,
+			})
+		}
+	}
+	walk(tree.Entries, 0)
+	return states
+}
+
+// UndoJump moves the current buffer to undo state seq
+func (e *CGOEngineWrapper) UndoJump(seq int) {
+	cvim.Execute(fmt.Sprintf("undo %d", seq))
+}
+
+// UndoHistory returns the contents of a vim undo file for the buffer
+func (b *CGOBufferWrapper) UndoHistory() ([]byte, error) {
+	f, err := os.CreateTemp("", "vimango-undo-*")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	b.withCurrent(func() {
+		cvim.Execute("silent! wundo! " + path)
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// vim leaves the file empty when there is nothing to undo
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// RestoreUndoHistory loads an undo file produced by UndoHistory. vim
+// ignores the file if the buffer text doesn't match the text it was written for.
+func (b *CGOBufferWrapper) RestoreUndoHistory(data []byte) error {
+	f, err := os.CreateTemp("", "vimango-undo-*")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	b.withCurrent(func() {
+		cvim.Execute("silent! rundo " + path)
+	})
+	return nil
+}
+
+// withCurrent runs fn with b as libvim's current buffer
+func (b *CGOBufferWrapper) withCurrent(fn func()) {
+	prev := cvim.BufferGetCurrent()
+	if prev != b.buf {
+		cvim.CBufferSetCurrent(b.buf)
+		defer cvim.CBufferSetCurrent(prev)
+	}
+	fn()
+}
@@ -174,11 +174,14 @@ func detectImageFormat(base64Data string) string {
 	return "png"
 }
 
-// preprocessMarkdownImages processes Google Drive images in markdown before HTML conversion
+// preprocessMarkdownImages processes attachments and Google Drive images in markdown before HTML conversion
 func preprocessMarkdownImages(markdown string) (string, error) {
 	// Initialize cache if needed
 	initImageCache()
 
+	// Attachments are in the database so they are just embedded
+	markdown = embedAttachments(markdown)
+
 	// Regular expression to find Google Drive URLs in markdown image syntax
 	// Matches both full URLs and gdrive: format
 	// ![alt text](https://drive.google.com/...) or ![alt text](gdrive:ID)
@@ -239,6 +242,9 @@ func preprocessMarkdownImages(markdown string) (string, error) {
 		processedMarkdown = strings.Replace(processedMarkdown, fullMatch, newImageTag, 1)
 	}
 
+	// Images with size or alignment attributes become styled img tags
+	processedMarkdown = imageAttrsToHTML(processedMarkdown)
+
 	return processedMarkdown, nil
 }
 
@@ -258,6 +264,10 @@ func convertGoogleDriveImageToDataURI(googleURL string) (string, error) {
 		err = png.Encode(&buf, img)
 	case "jpeg", "jpg":
 		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "heic", "avif", "webp":
+		// Not every webview shows these, so they are sent as PNG
+		err = png.Encode(&buf, img)
+		imgFmt = "png"
 	default:
 		// Default to PNG for unknown formats
 		//err = png.Encode(&buf, img)